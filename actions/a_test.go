@@ -1,9 +1,14 @@
 package actions
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/channel"
 	"github.com/hacash/core/coinbase"
 	"github.com/hacash/core/fields"
+	"github.com/hacash/core/memstate"
+	"github.com/hacash/core/stores"
 	"testing"
 	"time"
 )
@@ -52,3 +57,109 @@ func Test3(t *testing.T) {
 	fmt.Println(moveBtcLockWeekByIdx(2048))
 
 }
+
+func Test_PreviewChannelSettlement(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	channelId := fields.ChannelId(bytes.Repeat([]byte{1}, 16))
+
+	var createChannel = func() *stores.Channel {
+		paychan := stores.CreateEmptyChannel()
+		paychan.BelongHeight = 300000
+		paychan.LeftAddress = acc1.Address
+		paychan.RightAddress = acc2.Address
+		paychan.LeftAmount = *fields.NewAmountByUnit(100, 248)
+		paychan.RightAmount = *fields.NewAmountByUnit(50, 248)
+		paychan.LeftSatoshi = fields.NewSatoshiVariation(3000)
+		paychan.RightSatoshi = fields.NewSatoshiVariation(1000)
+		return paychan
+	}
+	// Close with consensus and compare the balances with the preview
+	var checkPreview = func(res *ChannelSettlementPreview, paychan *stores.Channel, lamt, ramt *fields.Amount, lsat, rsat fields.Satoshi, final bool) {
+		state := memstate.NewMemoryChainState(res.CloseHeight)
+		e := closePaymentChannelWriteinChainStateV3(state, channelId, paychan, lamt, ramt, lsat, rsat, final)
+		if e != nil {
+			t.Fatal(e)
+		}
+		bls1, _ := state.Balance(acc1.Address)
+		bls2, _ := state.Balance(acc2.Address)
+		if !bls1.Hacash.Equal(res.LeftAmount) || !bls2.Hacash.Equal(res.RightAmount) {
+			t.Fatal("preview amount not match consensus", res.LeftAmount.ToFinString(), bls1.Hacash.ToFinString())
+		}
+		if bls1.Satoshi != res.LeftSatoshi || bls2.Satoshi != res.RightSatoshi {
+			t.Fatal("preview satoshi not match consensus")
+		}
+		if lamt == nil {
+			lamt, ramt = &paychan.LeftAmount, &paychan.RightAmount
+		}
+		linterest, _ := bls1.Hacash.Sub(lamt)
+		rinterest, _ := bls2.Hacash.Sub(ramt)
+		if !linterest.Equal(res.LeftInterest) || !rinterest.Equal(res.RightInterest) {
+			t.Fatal("preview interest not match consensus")
+		}
+	}
+	closeHeight := uint64(300000 + 10000*12 + 123)
+
+	// Distribution (Action_12)
+	lamt := fields.NewAmountByUnit(120, 248)
+	ramt := fields.NewAmountByUnit(30, 248)
+	res, e := PreviewChannelSettlement(createChannel(), lamt, ramt, 1000, 3000, closeHeight)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !res.HaveInterest || !res.LeftInterest.IsPositive() || !res.RightInterest.IsPositive() {
+		t.Fatal("preview interest error")
+	}
+	checkPreview(res, createChannel(), lamt, ramt, 1000, 3000, false)
+
+	// Deposited amounts (Action_3)
+	res, e = PreviewChannelSettlementByBill(createChannel(), nil, closeHeight)
+	if e != nil {
+		t.Fatal(e)
+	}
+	checkPreview(res, createChannel(), nil, nil, 3000, 1000, false)
+
+	// Bill (Action_21)
+	bill := &channel.OffChainFormPaymentChannelRealtimeReconciliation{
+		ChannelId:    channelId,
+		ReuseVersion: 1,
+		LeftBalance:  *fields.NewAmountByUnit(70, 248),
+		RightBalance: *fields.NewAmountByUnit(80, 248),
+		LeftSatoshi:  fields.NewSatoshiVariation(4000),
+		RightSatoshi: fields.NewEmptySatoshiVariation(),
+		LeftAddress:  acc1.Address,
+		RightAddress: acc2.Address,
+	}
+	res, e = PreviewChannelSettlementByBill(createChannel(), bill, closeHeight)
+	if e != nil {
+		t.Fatal(e)
+	}
+	checkPreview(res, createChannel(), &bill.LeftBalance, &bill.RightBalance, 4000, 0, true)
+
+	// Seize all by the right
+	ttamt := fields.NewAmountByUnit(150, 248)
+	res, e = PreviewChannelSettlementBySeizeAll(createChannel(), false, closeHeight)
+	if e != nil {
+		t.Fatal(e)
+	}
+	checkPreview(res, createChannel(), fields.NewEmptyAmount(), ttamt, 0, 4000, true)
+
+	// Claim distribution after challenge expired
+	challenging := func() *stores.Channel {
+		paychan := createChannel()
+		paychan.ArbitrationLockBlock = 100
+		paychan.SetChallenging(closeHeight-200, true, fields.NewAmountByUnit(110, 248), 2500, 1)
+		return paychan
+	}
+	res, e = PreviewChannelSettlementByClaimDistribution(challenging(), closeHeight)
+	if e != nil {
+		t.Fatal(e)
+	}
+	checkPreview(res, challenging(), fields.NewAmountByUnit(110, 248), fields.NewAmountByUnit(40, 248), 2500, 1500, true)
+
+	_, e = PreviewChannelSettlement(createChannel(), lamt, lamt, 0, 0, 300000)
+	if e == nil {
+		t.Fatal("distribution amount must equal with lock in")
+	}
+}
@@ -79,6 +79,48 @@ func NewActionByKind(kind uint16) (interfaces.Action, error) {
 		return new(Action_33_DiamondsEngravedRecovery), nil
 	case 34:
 		return new(Action_34_SatoshiGenesis), nil
+	case 35:
+		return new(Action_35_PaymentChannelSpliceIn), nil
+	case 36:
+		return new(Action_36_PaymentChannelSpliceOut), nil
+	case 37:
+		return new(Action_37_UsersLendingPartialRepay), nil
+	case 38:
+		return new(Action_38_UsersLendingAdjust), nil
+	case 39:
+		return new(Action_39_GrantLockblsCreate), nil
+	case 40:
+		return new(Action_40_GrantLockblsRelease), nil
+	case 41:
+		return new(Action_41_GrantLockblsRevoke), nil
+	case 42:
+		return new(Action_42_RecurringPaymentCreate), nil
+	case 43:
+		return new(Action_43_RecurringPaymentPull), nil
+	case 44:
+		return new(Action_44_RecurringPaymentCancel), nil
+	case 45:
+		return new(Action_45_EscrowCreate), nil
+	case 46:
+		return new(Action_46_EscrowRelease), nil
+	case 47:
+		return new(Action_47_EscrowRefund), nil
+	case 48:
+		return new(Action_48_DiamondSwap), nil
+	case 49:
+		return new(Action_49_DiamondSwapCancel), nil
+	case 50:
+		return new(Action_50_TimeLockTransferCreate), nil
+	case 51:
+		return new(Action_51_TimeLockTransferClaim), nil
+	case 52:
+		return new(Action_52_DiamondVaultCreate), nil
+	case 53:
+		return new(Action_53_DiamondVaultShareTransfer), nil
+	case 54:
+		return new(Action_54_DiamondVaultRedeem), nil
+	case 55:
+		return new(Action_55_DiamondVaultBuyout), nil
 	}
 	////////////////////    END      ////////////////////
 	return nil, fmt.Errorf("Cannot find Action kind of %d.", +kind)
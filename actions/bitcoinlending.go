@@ -20,7 +20,10 @@ import (
 
 */
 
-const ()
+const (
+	// Number of blocks in redemption period, 十万个区块约一年
+	BitcoinsSystemLendingRansomBlockNumberBase uint64 = 100000
+)
 
 /*
 
@@ -610,7 +613,7 @@ func (act *Action_18_BitcoinsSystemLendingRansom) WriteInChainState(state interf
 	}
 
 	// Number of blocks in redemption period
-	ransomBlockNumberBase := uint64(100000) // 十万个区块约一年
+	ransomBlockNumberBase := BitcoinsSystemLendingRansomBlockNumberBase
 	if sys.TestDebugLocalDevelopmentMark {
 		ransomBlockNumberBase = 10 // Test environment 10 blocks as cycle
 	}
@@ -714,7 +717,7 @@ func (act *Action_18_BitcoinsSystemLendingRansom) WriteinChainState(state interf
 	}
 
 	// Number of blocks in redemption period
-	ransomBlockNumberBase := uint64(100000) // 十万个区块约一年
+	ransomBlockNumberBase := BitcoinsSystemLendingRansomBlockNumberBase
 	if sys.TestDebugLocalDevelopmentMark {
 		ransomBlockNumberBase = 10 // Test environment 10 blocks as cycle
 	}
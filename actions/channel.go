@@ -1527,18 +1527,25 @@ func closePaymentChannelRecoverChainState_deprecated(state interfacev2.ChainStat
 	return nil
 }
 
+// Block number of one compound interest period, by the open height of the channel
+func channelInterestPeriodBlocks(openBelongHeight uint64) uint64 {
+	if openBelongHeight > 200000 {
+		return 10000
+	}
+	return 2500
+}
+
 // Calculate channel interest
 // Whether bool has interest
 // Interestgiveto whom interest is allocated
 func calculateChannelInterest(curheight uint64, openBelongHeight uint64, leftAmount *fields.Amount, rightAmount *fields.Amount, interestgiveto fields.VarUint1) (*fields.Amount, *fields.Amount, bool, error) {
 	// Increase interest calculation, compound interest times: about 2500 blocks will increase compound interest by one ten thousandth every 8.68 days, less than 8 days will be ignored, and the annual compound interest is about 0.42%
 	//a1, a2 := DoAppendCompoundInterest1Of10000By2500Height(&leftAmount, &rightAmount, insnum)
-	var insnum = (curheight - openBelongHeight) / 2500
+	var insnum = (curheight - openBelongHeight) / channelInterestPeriodBlocks(openBelongHeight)
 	var wfzn uint64 = 1 // 万分之一 1/10000
 	// Modify the proportion of one-time additional issuance by opening the block height of the channel
 	if openBelongHeight > 200000 {
 		// Increase interest calculation, compounding times: about 10000 blocks will be compounded once every 34 days, less than 34 days will be ignored, and the annual compound interest is about 1.06%
-		insnum = (curheight - openBelongHeight) / 10000
 		wfzn = 10 // 千分之一 10/10000
 	}
 	if insnum > 0 {
@@ -0,0 +1,165 @@
+package actions
+
+import (
+	"fmt"
+	"github.com/hacash/core/channel"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+)
+
+/**
+
+通道关闭结算预览
+
+*/
+
+// The final amounts both parties will receive when the channel is closed
+type ChannelSettlementPreview struct {
+	CloseHeight   uint64
+	LeftAmount    *fields.Amount // HAC received by the left, including interest
+	RightAmount   *fields.Amount // HAC received by the right, including interest
+	LeftSatoshi   fields.Satoshi
+	RightSatoshi  fields.Satoshi
+	LeftInterest  *fields.Amount // Interest portion included in LeftAmount
+	RightInterest *fields.Amount // Interest portion included in RightAmount
+	HaveInterest  bool
+}
+
+// Preview the settlement of closing the channel with a bill at the target height
+// If bill is nil, the deposited amounts are used (Action_3)
+func PreviewChannelSettlementByBill(paychan *stores.Channel, bill channel.ReconciliationBalanceBill, closeHeight uint64) (*ChannelSettlementPreview, error) {
+	if paychan == nil {
+		return nil, fmt.Errorf("Payment Channel not be nil.")
+	}
+	if bill == nil {
+		return PreviewChannelSettlement(paychan, nil, nil,
+			paychan.LeftSatoshi.GetRealSatoshi(), paychan.RightSatoshi.GetRealSatoshi(), closeHeight)
+	}
+	// Check reuse version
+	if bill.GetReuseVersion() != uint32(paychan.ReuseVersion) {
+		return nil, fmt.Errorf("Payment Channel ReuseVersion is not match, need <%d> but got <%d>.",
+			paychan.ReuseVersion, bill.GetReuseVersion())
+	}
+	// Check address
+	if paychan.LeftAddress.NotEqual(bill.GetLeftAddress()) ||
+		paychan.RightAddress.NotEqual(bill.GetRightAddress()) {
+		return nil, fmt.Errorf("Payment Channel address not match.")
+	}
+	lamt := bill.GetLeftBalance()
+	ramt := bill.GetRightBalance()
+	return PreviewChannelSettlement(paychan, &lamt, &ramt,
+		bill.GetLeftSatoshi(), bill.GetRightSatoshi(), closeHeight)
+}
+
+// Preview the settlement of closing the channel with the given distribution at the target height
+// The checks and interest calculation are the same as closePaymentChannelWriteinChainStateV3
+func PreviewChannelSettlement(paychan *stores.Channel, newLeftAmt *fields.Amount, newRightAmt *fields.Amount, leftNewSAT fields.Satoshi, rightNewSAT fields.Satoshi, closeHeight uint64) (*ChannelSettlementPreview, error) {
+	if paychan == nil {
+		return nil, fmt.Errorf("Payment Channel not be nil.")
+	}
+	if paychan.IsClosed() {
+		return nil, fmt.Errorf("Payment Channel is be closed.")
+	}
+	if closeHeight < uint64(paychan.BelongHeight) {
+		return nil, fmt.Errorf("Close height %d cannot less than channel open height %d.",
+			closeHeight, paychan.BelongHeight)
+	}
+	if newLeftAmt == nil || newRightAmt == nil {
+		// Automatically use the deposited amount to calculate interest
+		newLeftAmt = &paychan.LeftAmount
+		newRightAmt = &paychan.RightAmount
+	}
+	// Allocation amount can be zero but not negative
+	if newLeftAmt.IsNegative() || newRightAmt.IsNegative() {
+		return nil, fmt.Errorf("Payment channel distribution amount cannot be negative.")
+	}
+	// Check whether the allocated amount is equal to the deposited amount
+	tt1, e1 := newLeftAmt.Add(newRightAmt)
+	if e1 != nil {
+		return nil, e1
+	}
+	tt2, e2 := paychan.LeftAmount.Add(&paychan.RightAmount)
+	if e2 != nil {
+		return nil, e2
+	}
+	if tt1.NotEqual(tt2) {
+		return nil, fmt.Errorf("HAC distribution amount must equal with lock in.")
+	}
+	// Check whether the total sat matches
+	totalOldSAT := paychan.LeftSatoshi.GetRealSatoshi() + paychan.RightSatoshi.GetRealSatoshi()
+	totalNewSAT := leftNewSAT + rightNewSAT
+	if totalOldSAT != totalNewSAT {
+		return nil, fmt.Errorf("SAT distribution error: need total %d SAT but got %d (left: %d, right: %d).",
+			totalOldSAT, totalNewSAT, leftNewSAT, rightNewSAT)
+	}
+	// Calculate interest
+	leftAmount, rightAmount, haveinterest, e3 := calculateChannelInterest(
+		closeHeight, uint64(paychan.BelongHeight), newLeftAmt, newRightAmt, paychan.InterestAttribution)
+	if e3 != nil {
+		return nil, e3
+	}
+	leftInterest, e4 := leftAmount.Sub(newLeftAmt)
+	if e4 != nil {
+		return nil, e4
+	}
+	rightInterest, e5 := rightAmount.Sub(newRightAmt)
+	if e5 != nil {
+		return nil, e5
+	}
+	// ok
+	return &ChannelSettlementPreview{
+		CloseHeight:   closeHeight,
+		LeftAmount:    leftAmount,
+		RightAmount:   rightAmount,
+		LeftSatoshi:   leftNewSAT,
+		RightSatoshi:  rightNewSAT,
+		LeftInterest:  leftInterest,
+		RightInterest: rightInterest,
+		HaveInterest:  haveinterest,
+	}, nil
+}
+
+// Preview the final arbitration: the responder with a higher bill number seizes all funds
+func PreviewChannelSettlementBySeizeAll(paychan *stores.Channel, seizeByLeft bool, closeHeight uint64) (*ChannelSettlementPreview, error) {
+	if paychan == nil {
+		return nil, fmt.Errorf("Payment Channel not be nil.")
+	}
+	ttamt, e := paychan.LeftAmount.Add(&paychan.RightAmount)
+	if e != nil {
+		return nil, e
+	}
+	ttsat := paychan.LeftSatoshi.GetRealSatoshi() + paychan.RightSatoshi.GetRealSatoshi()
+	if seizeByLeft {
+		return PreviewChannelSettlement(paychan, ttamt, fields.NewEmptyAmount(), ttsat, 0, closeHeight)
+	}
+	return PreviewChannelSettlement(paychan, fields.NewEmptyAmount(), ttamt, 0, ttsat, closeHeight)
+}
+
+// Preview the claim distribution after the challenge period expires (Action_27)
+func PreviewChannelSettlementByClaimDistribution(paychan *stores.Channel, closeHeight uint64) (*ChannelSettlementPreview, error) {
+	if paychan == nil {
+		return nil, fmt.Errorf("Payment Channel not be nil.")
+	}
+	if paychan.IsChallenging() == false {
+		return nil, fmt.Errorf("Payment Channel status is not on challenging.")
+	}
+	expireHei := uint64(paychan.ChallengeLaunchHeight) + uint64(paychan.ArbitrationLockBlock)
+	if closeHeight <= expireHei {
+		return nil, fmt.Errorf("Payment Channel Challenging expire is %d.", expireHei)
+	}
+	ttamt, e := paychan.LeftAmount.Add(&paychan.RightAmount)
+	if e != nil {
+		return nil, e
+	}
+	ttsat := paychan.LeftSatoshi.GetRealSatoshi() + paychan.RightSatoshi.GetRealSatoshi()
+	assertAmt := &paychan.AssertAmount
+	assertSat := paychan.AssertSatoshi.GetRealSatoshi()
+	otherAmt, e := ttamt.Sub(assertAmt)
+	if e != nil {
+		return nil, e
+	}
+	if paychan.AssertAddressIsLeftOrRight.Check() {
+		return PreviewChannelSettlement(paychan, assertAmt, otherAmt, assertSat, ttsat-assertSat, closeHeight)
+	}
+	return PreviewChannelSettlement(paychan, otherAmt, assertAmt, ttsat-assertSat, assertSat, closeHeight)
+}
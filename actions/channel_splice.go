@@ -0,0 +1,516 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/**
+ * 通道增资与部分提取
+ */
+
+// Deposit additional HAC / SAT into an opening channel (splice-in)
+// The accrued interest is settled, the reuse version is increased and all older bills become invalid
+type Action_35_PaymentChannelSpliceIn struct {
+	ChannelId fields.ChannelId // Channel ID
+
+	// Current distribution agreed by both parties
+	LeftAmount   fields.Amount
+	LeftSatoshi  fields.SatoshiVariation
+	RightAmount  fields.Amount
+	RightSatoshi fields.SatoshiVariation
+
+	// Additional deposit of each party
+	LeftSpliceAmount   fields.Amount
+	LeftSpliceSatoshi  fields.SatoshiVariation
+	RightSpliceAmount  fields.Amount
+	RightSpliceSatoshi fields.SatoshiVariation
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_35_PaymentChannelSpliceIn) Kind() uint16 {
+	return 35
+}
+
+func (elm *Action_35_PaymentChannelSpliceIn) Size() uint32 {
+	return 2 + elm.ChannelId.Size() +
+		elm.LeftAmount.Size() +
+		elm.LeftSatoshi.Size() +
+		elm.RightAmount.Size() +
+		elm.RightSatoshi.Size() +
+		elm.LeftSpliceAmount.Size() +
+		elm.LeftSpliceSatoshi.Size() +
+		elm.RightSpliceAmount.Size() +
+		elm.RightSpliceSatoshi.Size()
+}
+
+// json api
+func (elm *Action_35_PaymentChannelSpliceIn) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_35_PaymentChannelSpliceIn) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var bt, _ = elm.ChannelId.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftSatoshi.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightSatoshi.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftSpliceAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftSpliceSatoshi.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightSpliceAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightSpliceSatoshi.Serialize()
+	buffer.Write(bt)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_35_PaymentChannelSpliceIn) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.ChannelId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftSpliceAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftSpliceSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightSpliceAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightSpliceSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_35_PaymentChannelSpliceIn) RequestSignAddresses() []fields.Address {
+	// The signatures of both parties are checked in WriteInChainState
+	return []fields.Address{}
+}
+
+func (act *Action_35_PaymentChannelSpliceIn) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+	return spliceChannelWriteinChainState(state, act.belong_trs_v3, act.ChannelId,
+		&act.LeftAmount, act.LeftSatoshi.GetRealSatoshi(),
+		&act.RightAmount, act.RightSatoshi.GetRealSatoshi(),
+		&act.LeftSpliceAmount, act.LeftSpliceSatoshi.GetRealSatoshi(),
+		&act.RightSpliceAmount, act.RightSpliceSatoshi.GetRealSatoshi(),
+		true)
+}
+
+func (act *Action_35_PaymentChannelSpliceIn) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_35_PaymentChannelSpliceIn) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_35_PaymentChannelSpliceIn) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_35_PaymentChannelSpliceIn) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_35_PaymentChannelSpliceIn) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Withdraw part of HAC / SAT from an opening channel (splice-out)
+// The accrued interest is settled, the reuse version is increased and all older bills become invalid
+type Action_36_PaymentChannelSpliceOut struct {
+	ChannelId fields.ChannelId // Channel ID
+
+	// Current distribution agreed by both parties
+	LeftAmount   fields.Amount
+	LeftSatoshi  fields.SatoshiVariation
+	RightAmount  fields.Amount
+	RightSatoshi fields.SatoshiVariation
+
+	// Withdrawal of each party
+	LeftSpliceAmount   fields.Amount
+	LeftSpliceSatoshi  fields.SatoshiVariation
+	RightSpliceAmount  fields.Amount
+	RightSpliceSatoshi fields.SatoshiVariation
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_36_PaymentChannelSpliceOut) Kind() uint16 {
+	return 36
+}
+
+func (elm *Action_36_PaymentChannelSpliceOut) Size() uint32 {
+	return 2 + elm.ChannelId.Size() +
+		elm.LeftAmount.Size() +
+		elm.LeftSatoshi.Size() +
+		elm.RightAmount.Size() +
+		elm.RightSatoshi.Size() +
+		elm.LeftSpliceAmount.Size() +
+		elm.LeftSpliceSatoshi.Size() +
+		elm.RightSpliceAmount.Size() +
+		elm.RightSpliceSatoshi.Size()
+}
+
+// json api
+func (elm *Action_36_PaymentChannelSpliceOut) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_36_PaymentChannelSpliceOut) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var bt, _ = elm.ChannelId.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftSatoshi.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightSatoshi.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftSpliceAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LeftSpliceSatoshi.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightSpliceAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.RightSpliceSatoshi.Serialize()
+	buffer.Write(bt)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_36_PaymentChannelSpliceOut) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.ChannelId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftSpliceAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LeftSpliceSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightSpliceAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RightSpliceSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_36_PaymentChannelSpliceOut) RequestSignAddresses() []fields.Address {
+	// The signatures of both parties are checked in WriteInChainState
+	return []fields.Address{}
+}
+
+func (act *Action_36_PaymentChannelSpliceOut) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+	return spliceChannelWriteinChainState(state, act.belong_trs_v3, act.ChannelId,
+		&act.LeftAmount, act.LeftSatoshi.GetRealSatoshi(),
+		&act.RightAmount, act.RightSatoshi.GetRealSatoshi(),
+		&act.LeftSpliceAmount, act.LeftSpliceSatoshi.GetRealSatoshi(),
+		&act.RightSpliceAmount, act.RightSpliceSatoshi.GetRealSatoshi(),
+		false)
+}
+
+func (act *Action_36_PaymentChannelSpliceOut) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_36_PaymentChannelSpliceOut) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_36_PaymentChannelSpliceOut) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_36_PaymentChannelSpliceOut) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_36_PaymentChannelSpliceOut) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Splice in or out the channel
+// 1. check the current distribution agreed by both parties
+// 2. settle the interest until now and restart the interest calculation from current height
+// 3. deposit or withdraw, then increase the reuse version to invalidate all older bills
+func spliceChannelWriteinChainState(state interfaces.ChainStateOperation, trs interfaces.Transaction, channelId fields.ChannelId,
+	leftAmt *fields.Amount, leftSAT fields.Satoshi, rightAmt *fields.Amount, rightSAT fields.Satoshi,
+	leftSpliceAmt *fields.Amount, leftSpliceSAT fields.Satoshi, rightSpliceAmt *fields.Amount, rightSpliceSAT fields.Satoshi,
+	isSpliceIn bool) error {
+
+	var e error
+
+	// Query channel
+	paychan, e := state.Channel(channelId)
+	if e != nil {
+		return e
+	}
+	if paychan == nil {
+		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(channelId))
+	}
+	if paychan.IsOpening() == false {
+		return fmt.Errorf("Payment Channel status is not on opening.")
+	}
+	// Check the signatures of both parties
+	signok, e := trs.VerifyTargetSigns([]fields.Address{paychan.LeftAddress, paychan.RightAddress})
+	if e != nil {
+		return e
+	}
+	if !signok {
+		return fmt.Errorf("Payment Channel <%s> address signature verify fail.", hex.EncodeToString(channelId))
+	}
+	// Amount can be zero but not negative
+	if leftAmt.IsNegative() || rightAmt.IsNegative() ||
+		leftSpliceAmt.IsNegative() || rightSpliceAmt.IsNegative() {
+		return fmt.Errorf("Payment channel splice amount cannot be negative.")
+	}
+	if leftSpliceAmt.IsEmpty() && rightSpliceAmt.IsEmpty() && leftSpliceSAT == 0 && rightSpliceSAT == 0 {
+		return fmt.Errorf("Payment channel splice amount cannot be empty.")
+	}
+	// Check whether the current distribution is equal to the locked amount
+	tt1, e := leftAmt.Add(rightAmt)
+	if e != nil {
+		return e
+	}
+	tt2, e := paychan.LeftAmount.Add(&paychan.RightAmount)
+	if e != nil {
+		return e
+	}
+	if tt1.NotEqual(tt2) {
+		return fmt.Errorf("HAC distribution amount must equal with lock in.")
+	}
+	totalOldSAT := paychan.LeftSatoshi.GetRealSatoshi() + paychan.RightSatoshi.GetRealSatoshi()
+	if leftSAT+rightSAT != totalOldSAT {
+		return fmt.Errorf("SAT distribution error: need total %d SAT but got %d (left: %d, right: %d).",
+			totalOldSAT, leftSAT+rightSAT, leftSAT, rightSAT)
+	}
+	// Settle interest until now
+	curheight := state.GetPendingBlockHeight()
+	leftNewAmt, rightNewAmt, haveinterest, e := calculateChannelInterest(
+		curheight, uint64(paychan.BelongHeight), leftAmt, rightAmt, paychan.InterestAttribution)
+	if e != nil {
+		return e
+	}
+	totalWithInterest, e := leftNewAmt.Add(rightNewAmt)
+	if e != nil {
+		return e
+	}
+	// Deposit or withdraw
+	leftNewSAT, rightNewSAT := leftSAT, rightSAT
+	if isSpliceIn {
+		leftNewAmt, e = leftNewAmt.Add(leftSpliceAmt)
+		if e != nil {
+			return e
+		}
+		rightNewAmt, e = rightNewAmt.Add(rightSpliceAmt)
+		if e != nil {
+			return e
+		}
+		leftNewSAT += leftSpliceSAT
+		rightNewSAT += rightSpliceSAT
+	} else {
+		if leftNewAmt.LessThan(leftSpliceAmt) || rightNewAmt.LessThan(rightSpliceAmt) {
+			return fmt.Errorf("Payment channel splice out amount cannot more than the balance.")
+		}
+		if leftNewSAT < leftSpliceSAT || rightNewSAT < rightSpliceSAT {
+			return fmt.Errorf("Payment channel splice out satoshi cannot more than the balance.")
+		}
+		leftNewAmt, e = leftNewAmt.Sub(leftSpliceAmt)
+		if e != nil {
+			return e
+		}
+		rightNewAmt, e = rightNewAmt.Sub(rightSpliceAmt)
+		if e != nil {
+			return e
+		}
+		leftNewSAT -= leftSpliceSAT
+		rightNewSAT -= rightSpliceSAT
+		if leftNewAmt.IsEmpty() && rightNewAmt.IsEmpty() && leftNewSAT == 0 && rightNewSAT == 0 {
+			return fmt.Errorf("Payment channel cannot be empty after splice out, please close it.")
+		}
+	}
+	// Check the number of digits stored in the amount
+	labt, _ := leftNewAmt.Serialize()
+	rabt, _ := rightNewAmt.Serialize()
+	if len(labt) > 6 || len(rabt) > 6 {
+		return fmt.Errorf("Payment Channel splice error: left or right Amount bytes too long.")
+	}
+	// Transfer balance
+	if isSpliceIn {
+		if leftSpliceAmt.IsPositive() {
+			e = DoSubBalanceFromChainState(state, paychan.LeftAddress, *leftSpliceAmt)
+			if e != nil {
+				return e
+			}
+		}
+		if rightSpliceAmt.IsPositive() {
+			e = DoSubBalanceFromChainState(state, paychan.RightAddress, *rightSpliceAmt)
+			if e != nil {
+				return e
+			}
+		}
+		if leftSpliceSAT > 0 {
+			e = DoSubSatoshiFromChainStateV3(state, paychan.LeftAddress, leftSpliceSAT)
+			if e != nil {
+				return e
+			}
+		}
+		if rightSpliceSAT > 0 {
+			e = DoSubSatoshiFromChainStateV3(state, paychan.RightAddress, rightSpliceSAT)
+			if e != nil {
+				return e
+			}
+		}
+	} else {
+		if leftSpliceAmt.IsPositive() {
+			e = DoAddBalanceFromChainState(state, paychan.LeftAddress, *leftSpliceAmt)
+			if e != nil {
+				return e
+			}
+		}
+		if rightSpliceAmt.IsPositive() {
+			e = DoAddBalanceFromChainState(state, paychan.RightAddress, *rightSpliceAmt)
+			if e != nil {
+				return e
+			}
+		}
+		if leftSpliceSAT > 0 {
+			e = DoAddSatoshiFromChainStateV3(state, paychan.LeftAddress, leftSpliceSAT)
+			if e != nil {
+				return e
+			}
+		}
+		if rightSpliceSAT > 0 {
+			e = DoAddSatoshiFromChainStateV3(state, paychan.RightAddress, rightSpliceSAT)
+			if e != nil {
+				return e
+			}
+		}
+	}
+	// Total supply statistics
+	totalsupply, e := state.ReadTotalSupply()
+	if e != nil {
+		return e
+	}
+	if haveinterest {
+		interest, e := totalWithInterest.Sub(tt2)
+		if e != nil {
+			return e
+		}
+		totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfChannelInterest, interest.ToMei())
+	}
+	totalsupply.DoSub(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, tt2.ToMei())
+	totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, leftNewAmt.ToMei()+rightNewAmt.ToMei())
+	totalsupply.DoSubUint(stores.TotalSupplyStoreTypeOfLocatedSATInChannel, uint64(totalOldSAT))
+	totalsupply.DoAddUint(stores.TotalSupplyStoreTypeOfLocatedSATInChannel, uint64(leftNewSAT+rightNewSAT))
+	e = state.UpdateSetTotalSupply(totalsupply)
+	if e != nil {
+		return e
+	}
+	// Update channel and invalidate older bills
+	// Interest restarts from the end of the last settled period, the partial period carries over
+	oldBelongHeight := uint64(paychan.BelongHeight)
+	period := channelInterestPeriodBlocks(oldBelongHeight)
+	paychan.BelongHeight = fields.BlockHeight(oldBelongHeight + (curheight-oldBelongHeight)/period*period)
+	paychan.LeftAmount = *leftNewAmt
+	paychan.RightAmount = *rightNewAmt
+	paychan.LeftSatoshi = leftNewSAT.GetSatoshiVariation()
+	paychan.RightSatoshi = rightNewSAT.GetSatoshiVariation()
+	paychan.ReuseVersion += 1
+	return state.ChannelUpdate(channelId, paychan)
+}
@@ -0,0 +1,413 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+钻石与 HAC 原子交换
+
+. 卖方离线签名报价（钻石列表、价格、可选买方、过期高度）
+. 任何买方（或指定买方）提交报价并支付，钻石与 HAC 原子交换
+. 卖方可提交取消，已成交或已取消的报价按哈希记录，不可重放
+
+*/
+
+// Offer signed by the seller off-chain
+type DiamondSwapOffer struct {
+	SellerAddress     fields.Address
+	DiamondList       fields.DiamondListMaxLen200
+	Price             fields.Amount          // HAC paid to the seller
+	BuyerAddress      fields.OptionalAddress // Anyone can buy if not exist
+	ExpireBlockHeight fields.BlockHeight     // Cannot be filled at and after it
+	Nonce             fields.VarUint8        // Distinguish the same offers
+
+	SellerSign fields.Sign
+}
+
+func (elm *DiamondSwapOffer) Size() uint32 {
+	return elm.SellerAddress.Size() +
+		elm.DiamondList.Size() +
+		elm.Price.Size() +
+		elm.BuyerAddress.Size() +
+		elm.ExpireBlockHeight.Size() +
+		elm.Nonce.Size() +
+		elm.SellerSign.Size()
+}
+
+func (elm *DiamondSwapOffer) SerializeNoSign() ([]byte, error) {
+	var buffer bytes.Buffer
+	var bt1, _ = elm.SellerAddress.Serialize()
+	var bt2, e1 = elm.DiamondList.Serialize()
+	if e1 != nil {
+		return nil, e1
+	}
+	var bt3, e2 = elm.Price.Serialize()
+	if e2 != nil {
+		return nil, e2
+	}
+	var bt4, _ = elm.BuyerAddress.Serialize()
+	var bt5, _ = elm.ExpireBlockHeight.Serialize()
+	var bt6, _ = elm.Nonce.Serialize()
+	buffer.Write(bt1)
+	buffer.Write(bt2)
+	buffer.Write(bt3)
+	buffer.Write(bt4)
+	buffer.Write(bt5)
+	buffer.Write(bt6)
+	return buffer.Bytes(), nil
+}
+
+// Signed hash, also the offer id
+func (elm *DiamondSwapOffer) SignStuffHash() (fields.Hash, error) {
+	var conbt, e = elm.SerializeNoSign() // Data body
+	if e != nil {
+		return nil, e
+	}
+	return fields.CalculateHash(conbt), nil
+}
+
+func (elm *DiamondSwapOffer) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var bt1, e = elm.SerializeNoSign() // Data body
+	if e != nil {
+		return nil, e
+	}
+	var bt2, _ = elm.SellerSign.Serialize()
+	buffer.Write(bt1)
+	buffer.Write(bt2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *DiamondSwapOffer) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Price.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Nonce.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerSign.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// Sign by the seller
+func (elm *DiamondSwapOffer) FillSign(acc *account.Account) error {
+	if elm.SellerAddress.NotEqual(acc.Address) {
+		return fmt.Errorf("Account %s is not the seller %s.", acc.AddressReadable, elm.SellerAddress.ToReadable())
+	}
+	conhx, e := elm.SignStuffHash()
+	if e != nil {
+		return e
+	}
+	signdata, e := acc.Private.Sign(conhx)
+	if e != nil {
+		return e // Signature error
+	}
+	elm.SellerSign = fields.Sign{
+		PublicKey: acc.PublicKey,
+		Signature: signdata.Serialize64(),
+	}
+	return nil
+}
+
+// Check the seller signature
+func (elm *DiamondSwapOffer) CheckSellerSign() error {
+	conhx, e := elm.SignStuffHash()
+	if e != nil {
+		return e
+	}
+	sgaddr := fields.Address(account.NewAddressFromPublicKeyV0(elm.SellerSign.PublicKey))
+	if sgaddr.NotEqual(elm.SellerAddress) {
+		return fmt.Errorf("Address not match, need %s but got %s.", elm.SellerAddress.ToReadable(), sgaddr.ToReadable())
+	}
+	ok, _ := account.CheckSignByHash32(conhx, elm.SellerSign.PublicKey, elm.SellerSign.Signature)
+	if !ok {
+		return fmt.Errorf("Seller %s verify signature fail.", elm.SellerAddress.ToReadable())
+	}
+	return nil
+}
+
+// Check the offer is not filled or cancelled
+func checkDiamondSwapOfferNotClosed(state interfaces.ChainStateOperation, offerhx fields.Hash) error {
+	record, e := state.DiamondSwapRecord(offerhx)
+	if e != nil {
+		return e
+	}
+	if record != nil {
+		if record.Status == stores.DiamondSwapOfferStatusCancelled {
+			return fmt.Errorf("Diamond swap offer <%s> has been cancelled.", offerhx.ToHex())
+		}
+		return fmt.Errorf("Diamond swap offer <%s> has been filled.", offerhx.ToHex())
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////
+
+// The buyer submits the signed offer with payment
+type Action_48_DiamondSwap struct {
+	Offer        DiamondSwapOffer
+	BuyerAddress fields.Address // Pay HAC and receive diamonds
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_48_DiamondSwap) Kind() uint16 {
+	return 48
+}
+
+// json api
+func (elm *Action_48_DiamondSwap) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_48_DiamondSwap) Size() uint32 {
+	return 2 + elm.Offer.Size() + elm.BuyerAddress.Size()
+}
+
+func (elm *Action_48_DiamondSwap) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, e = elm.Offer.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	var b2, _ = elm.BuyerAddress.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_48_DiamondSwap) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Offer.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_48_DiamondSwap) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.BuyerAddress, // The buyer pay
+	}
+}
+
+func (act *Action_48_DiamondSwap) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	offer := &act.Offer
+	// Check the seller signature
+	e := offer.CheckSellerSign()
+	if e != nil {
+		return e
+	}
+	offerhx, e := offer.SignStuffHash()
+	if e != nil {
+		return e
+	}
+	// Check expire
+	paddingHeight := state.GetPendingBlockHeight()
+	if paddingHeight >= uint64(offer.ExpireBlockHeight) {
+		return fmt.Errorf("Diamond swap offer <%s> expired at height %d.", offerhx.ToHex(), offer.ExpireBlockHeight)
+	}
+	// Check replay
+	e = checkDiamondSwapOfferNotClosed(state, offerhx)
+	if e != nil {
+		return e
+	}
+	// Check buyer
+	if offer.BuyerAddress.Exist.Check() && offer.BuyerAddress.Addr.NotEqual(act.BuyerAddress) {
+		return fmt.Errorf("Diamond swap offer only for buyer %s.", offer.BuyerAddress.Addr.ToReadable())
+	}
+	if act.BuyerAddress.Equal(offer.SellerAddress) {
+		return fmt.Errorf("Buyer and seller address cannot be the same.")
+	}
+	if offer.Price.IsNegative() {
+		return fmt.Errorf("Diamond swap price cannot be negative.")
+	}
+	// Move diamonds, check status and belong
+	e = DoMultipleDiamondTransfer(state, offer.SellerAddress, act.BuyerAddress, offer.DiamondList)
+	if e != nil {
+		return e
+	}
+	// Pay
+	if offer.Price.IsPositive() {
+		e = DoSimpleTransferFromChainState(state, act.BuyerAddress, offer.SellerAddress, offer.Price)
+		if e != nil {
+			return e
+		}
+	}
+	// Record filled
+	return state.DiamondSwapRecordCreate(offerhx, &stores.DiamondSwapRecord{
+		Status:        stores.DiamondSwapOfferStatusFilled,
+		SellerAddress: offer.SellerAddress,
+		BlockHeight:   fields.BlockHeight(paddingHeight),
+	})
+}
+
+func (act *Action_48_DiamondSwap) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_48_DiamondSwap) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_48_DiamondSwap) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_48_DiamondSwap) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_48_DiamondSwap) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+////////////////////////////////////////////////////////
+
+// The seller cancels the offer before it is filled
+type Action_49_DiamondSwapCancel struct {
+	Offer DiamondSwapOffer
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_49_DiamondSwapCancel) Kind() uint16 {
+	return 49
+}
+
+// json api
+func (elm *Action_49_DiamondSwapCancel) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_49_DiamondSwapCancel) Size() uint32 {
+	return 2 + elm.Offer.Size()
+}
+
+func (elm *Action_49_DiamondSwapCancel) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, e = elm.Offer.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_49_DiamondSwapCancel) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Offer.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_49_DiamondSwapCancel) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.Offer.SellerAddress, // Only the seller can cancel
+	}
+}
+
+func (act *Action_49_DiamondSwapCancel) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	offerhx, e := act.Offer.SignStuffHash()
+	if e != nil {
+		return e
+	}
+	e = checkDiamondSwapOfferNotClosed(state, offerhx)
+	if e != nil {
+		return e
+	}
+	// Record cancelled
+	return state.DiamondSwapRecordCreate(offerhx, &stores.DiamondSwapRecord{
+		Status:        stores.DiamondSwapOfferStatusCancelled,
+		SellerAddress: act.Offer.SellerAddress,
+		BlockHeight:   fields.BlockHeight(state.GetPendingBlockHeight()),
+	})
+}
+
+func (act *Action_49_DiamondSwapCancel) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_49_DiamondSwapCancel) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_49_DiamondSwapCancel) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_49_DiamondSwapCancel) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_49_DiamondSwapCancel) IsBurning90PersentTxFees() bool {
+	return false
+}
@@ -0,0 +1,605 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+	"math/big"
+)
+
+/*
+
+钻石份额金库
+
+. 创建：所有者将一枚或多枚钻石锁入金库，得到 N 份可转让份额，并设定每份收购价
+. 转让：份额持有人转让份额
+. 赎回：持有全部份额的地址取回钻石
+. 收购：任何人按每份收购价向其他持有人支付全部份额的价款，取得钻石
+
+锁定中的钻石标记为 DiamondStatusVault，不可转账
+
+*/
+
+const (
+	DiamondVaultTotalSharesMin = 2
+	DiamondVaultTotalSharesMax = 10000 * 10000
+)
+
+// The owner locks diamonds into the vault and gets all shares
+type Action_52_DiamondVaultCreate struct {
+	VaultId             fields.DiamondVaultId
+	CreatorAddress      fields.Address
+	DiamondList         fields.DiamondListMaxLen200
+	TotalShares         fields.VarUint4
+	BuyoutPricePerShare fields.Amount
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_52_DiamondVaultCreate) Kind() uint16 {
+	return 52
+}
+
+// json api
+func (elm *Action_52_DiamondVaultCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_52_DiamondVaultCreate) Size() uint32 {
+	return 2 + elm.VaultId.Size() +
+		elm.CreatorAddress.Size() +
+		elm.DiamondList.Size() +
+		elm.TotalShares.Size() +
+		elm.BuyoutPricePerShare.Size()
+}
+
+func (elm *Action_52_DiamondVaultCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.VaultId.Serialize()
+	var b2, _ = elm.CreatorAddress.Serialize()
+	var b3, e1 = elm.DiamondList.Serialize()
+	if e1 != nil {
+		return nil, e1
+	}
+	var b4, _ = elm.TotalShares.Serialize()
+	var b5, e2 = elm.BuyoutPricePerShare.Serialize()
+	if e2 != nil {
+		return nil, e2
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_52_DiamondVaultCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.VaultId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CreatorAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TotalShares.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyoutPricePerShare.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_52_DiamondVaultCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.CreatorAddress, // The creator lock diamonds
+	}
+}
+
+func (act *Action_52_DiamondVaultCreate) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	// Check the validity of ID value
+	if len(act.VaultId) != stores.DiamondVaultIdLength || act.VaultId[0] == 0 || act.VaultId[stores.DiamondVaultIdLength-1] == 0 {
+		return fmt.Errorf("Diamond vault id format error.")
+	}
+	hasvault, e := state.DiamondVault(act.VaultId)
+	if e != nil {
+		return e
+	}
+	if hasvault != nil {
+		return fmt.Errorf("Diamond vault id<%s> already.", act.VaultId.ToHex())
+	}
+	if !act.CreatorAddress.IsValid() {
+		return fmt.Errorf("Creator address is invalid.")
+	}
+	// Check shares and price
+	if act.TotalShares < DiamondVaultTotalSharesMin || act.TotalShares > DiamondVaultTotalSharesMax {
+		return fmt.Errorf("TotalShares must between %d and %d.", DiamondVaultTotalSharesMin, DiamondVaultTotalSharesMax)
+	}
+	if !act.BuyoutPricePerShare.IsPositive() {
+		return fmt.Errorf("BuyoutPricePerShare must be positive.")
+	}
+	// Check diamonds
+	dianum := int(act.DiamondList.Count)
+	if dianum != len(act.DiamondList.Diamonds) {
+		return fmt.Errorf("Diamonds quantity error")
+	}
+	if dianum == 0 || dianum > 200 {
+		return fmt.Errorf("Diamonds quantity must between 1 and 200")
+	}
+	// Lock diamonds
+	for i := 0; i < dianum; i++ {
+		diamond := act.DiamondList.Diamonds[i]
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		ckerr := CheckDiamondStatusNormalAndBelong(&diamond, diaitem, &act.CreatorAddress)
+		if ckerr != nil {
+			return ckerr
+		}
+		diaitem.Status = stores.DiamondStatusVault // Mark locked in vault
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	e9 := DoSubDiamondFromChainStateV3(state, act.CreatorAddress, fields.DiamondNumber(dianum))
+	if e9 != nil {
+		return e9
+	}
+	// Save, the creator holds all shares
+	vault := &stores.DiamondVault{
+		Status:              stores.DiamondVaultStatusActive,
+		CreatorAddress:      act.CreatorAddress,
+		DiamondList:         act.DiamondList,
+		TotalShares:         act.TotalShares,
+		BuyoutPricePerShare: act.BuyoutPricePerShare,
+		HolderCount:         0,
+		Holders:             []*stores.DiamondVaultHolder{},
+		CloseBlockHeight:    0,
+	}
+	e = vault.AddShares(act.CreatorAddress, uint32(act.TotalShares))
+	if e != nil {
+		return e
+	}
+	return state.DiamondVaultCreate(act.VaultId, vault)
+}
+
+func (act *Action_52_DiamondVaultCreate) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_52_DiamondVaultCreate) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_52_DiamondVaultCreate) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_52_DiamondVaultCreate) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_52_DiamondVaultCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Transfer shares of the vault
+type Action_53_DiamondVaultShareTransfer struct {
+	VaultId     fields.DiamondVaultId
+	FromAddress fields.Address
+	ToAddress   fields.Address
+	Shares      fields.VarUint4
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_53_DiamondVaultShareTransfer) Kind() uint16 {
+	return 53
+}
+
+// json api
+func (elm *Action_53_DiamondVaultShareTransfer) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_53_DiamondVaultShareTransfer) Size() uint32 {
+	return 2 + elm.VaultId.Size() +
+		elm.FromAddress.Size() +
+		elm.ToAddress.Size() +
+		elm.Shares.Size()
+}
+
+func (elm *Action_53_DiamondVaultShareTransfer) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.VaultId.Serialize()
+	var b2, _ = elm.FromAddress.Serialize()
+	var b3, _ = elm.ToAddress.Serialize()
+	var b4, _ = elm.Shares.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_53_DiamondVaultShareTransfer) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.VaultId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.FromAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ToAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Shares.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_53_DiamondVaultShareTransfer) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.FromAddress, // The holder transfer shares
+	}
+}
+
+func (act *Action_53_DiamondVaultShareTransfer) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	vault, e := loadActiveDiamondVault(state, act.VaultId)
+	if e != nil {
+		return e
+	}
+	if act.Shares == 0 {
+		return fmt.Errorf("Shares cannot be zero.")
+	}
+	if !act.ToAddress.IsValid() {
+		return fmt.Errorf("To address is invalid.")
+	}
+	if act.FromAddress.Equal(act.ToAddress) {
+		return fmt.Errorf("Cannot transfer shares to self.")
+	}
+	e = vault.SubShares(act.FromAddress, uint32(act.Shares))
+	if e != nil {
+		return e
+	}
+	e = vault.AddShares(act.ToAddress, uint32(act.Shares))
+	if e != nil {
+		return e
+	}
+	return state.DiamondVaultUpdate(act.VaultId, vault)
+}
+
+func (act *Action_53_DiamondVaultShareTransfer) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_53_DiamondVaultShareTransfer) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_53_DiamondVaultShareTransfer) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_53_DiamondVaultShareTransfer) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_53_DiamondVaultShareTransfer) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// The holder of all shares takes the diamonds out
+type Action_54_DiamondVaultRedeem struct {
+	VaultId       fields.DiamondVaultId
+	RedeemAddress fields.Address
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_54_DiamondVaultRedeem) Kind() uint16 {
+	return 54
+}
+
+// json api
+func (elm *Action_54_DiamondVaultRedeem) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_54_DiamondVaultRedeem) Size() uint32 {
+	return 2 + elm.VaultId.Size() +
+		elm.RedeemAddress.Size()
+}
+
+func (elm *Action_54_DiamondVaultRedeem) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.VaultId.Serialize()
+	var b2, _ = elm.RedeemAddress.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_54_DiamondVaultRedeem) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.VaultId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RedeemAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_54_DiamondVaultRedeem) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.RedeemAddress, // The holder of all shares
+	}
+}
+
+func (act *Action_54_DiamondVaultRedeem) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	vault, e := loadActiveDiamondVault(state, act.VaultId)
+	if e != nil {
+		return e
+	}
+	// Must hold all shares
+	if vault.SharesOf(act.RedeemAddress) != uint32(vault.TotalShares) {
+		return fmt.Errorf("Address %s does not hold all %d shares.", act.RedeemAddress.ToReadable(), vault.TotalShares)
+	}
+	return closeDiamondVault(state, act.VaultId, vault, act.RedeemAddress, stores.DiamondVaultStatusRedeemed)
+}
+
+func (act *Action_54_DiamondVaultRedeem) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_54_DiamondVaultRedeem) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_54_DiamondVaultRedeem) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_54_DiamondVaultRedeem) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_54_DiamondVaultRedeem) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Buy out all other holders at the buyout price and take the diamonds
+type Action_55_DiamondVaultBuyout struct {
+	VaultId      fields.DiamondVaultId
+	BuyerAddress fields.Address
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_55_DiamondVaultBuyout) Kind() uint16 {
+	return 55
+}
+
+// json api
+func (elm *Action_55_DiamondVaultBuyout) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_55_DiamondVaultBuyout) Size() uint32 {
+	return 2 + elm.VaultId.Size() +
+		elm.BuyerAddress.Size()
+}
+
+func (elm *Action_55_DiamondVaultBuyout) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.VaultId.Serialize()
+	var b2, _ = elm.BuyerAddress.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_55_DiamondVaultBuyout) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.VaultId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_55_DiamondVaultBuyout) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.BuyerAddress, // The buyer pay for shares
+	}
+}
+
+func (act *Action_55_DiamondVaultBuyout) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	vault, e := loadActiveDiamondVault(state, act.VaultId)
+	if e != nil {
+		return e
+	}
+	// Pay all other holders at the buyout price
+	price := vault.BuyoutPricePerShare.GetValue()
+	for _, holder := range vault.Holders {
+		if holder.Address.Equal(act.BuyerAddress) {
+			continue // Own shares are free
+		}
+		pay, e := fields.NewAmountByBigInt(new(big.Int).Mul(price, big.NewInt(int64(holder.Shares))))
+		if e != nil {
+			return e
+		}
+		e = DoSimpleTransferFromChainState(state, act.BuyerAddress, holder.Address, *pay)
+		if e != nil {
+			return e
+		}
+	}
+	return closeDiamondVault(state, act.VaultId, vault, act.BuyerAddress, stores.DiamondVaultStatusBoughtOut)
+}
+
+func (act *Action_55_DiamondVaultBuyout) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_55_DiamondVaultBuyout) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_55_DiamondVaultBuyout) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_55_DiamondVaultBuyout) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_55_DiamondVaultBuyout) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+func loadActiveDiamondVault(state interfaces.ChainStateOperation, id fields.DiamondVaultId) (*stores.DiamondVault, error) {
+	vault, e := state.DiamondVault(id)
+	if e != nil {
+		return nil, e
+	}
+	if vault == nil {
+		return nil, fmt.Errorf("Diamond vault id<%s> not find.", id.ToHex())
+	}
+	if vault.IsClosed() {
+		return nil, fmt.Errorf("Diamond vault id<%s> has been closed.", id.ToHex())
+	}
+	return vault, nil
+}
+
+// Unlock the diamonds to the address, all shares are void
+func closeDiamondVault(state interfaces.ChainStateOperation, id fields.DiamondVaultId, vault *stores.DiamondVault, toAddr fields.Address, status fields.VarUint1) error {
+	dianum := len(vault.DiamondList.Diamonds)
+	for i := 0; i < dianum; i++ {
+		diamond := vault.DiamondList.Diamonds[i]
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Status != stores.DiamondStatusVault {
+			return fmt.Errorf("Diamond <%s> status is not [stores.DiamondStatusVault].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal
+		diaitem.Address = toAddr
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	e9 := DoAddDiamondFromChainStateV3(state, toAddr, fields.DiamondNumber(dianum))
+	if e9 != nil {
+		return e9
+	}
+	// Not deleted, but saved for block rollback
+	vault.Status = status
+	vault.HolderCount = 0
+	vault.Holders = []*stores.DiamondVaultHolder{}
+	vault.CloseBlockHeight = fields.BlockHeight(state.GetPendingBlockHeight())
+	return state.DiamondVaultUpdate(id, vault)
+}
@@ -0,0 +1,519 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+三方托管（2-of-3）
+
+. 创建：买方锁定 HAC、SAT 或钻石，指定卖方和仲裁人
+. 放款：三方中任意两方签名，资产转给卖方
+. 退款：三方中任意两方签名，或超时后任何人可触发，资产退还买方
+
+锁定中的钻石标记为 DiamondStatusEscrow，不可转账
+
+*/
+
+// The buyer locks assets naming a seller and an arbiter
+type Action_45_EscrowCreate struct {
+	EscrowId           fields.EscrowId
+	BuyerAddress       fields.Address
+	SellerAddress      fields.Address
+	ArbiterAddress     fields.Address
+	TimeoutBlockHeight fields.BlockHeight // The buyer can be refunded after it
+
+	LockAmount      fields.Amount
+	LockSatoshi     fields.SatoshiVariation
+	LockDiamondList fields.DiamondListMaxLen200
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_45_EscrowCreate) Kind() uint16 {
+	return 45
+}
+
+// json api
+func (elm *Action_45_EscrowCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_45_EscrowCreate) Size() uint32 {
+	return 2 + elm.EscrowId.Size() +
+		elm.BuyerAddress.Size() +
+		elm.SellerAddress.Size() +
+		elm.ArbiterAddress.Size() +
+		elm.TimeoutBlockHeight.Size() +
+		elm.LockAmount.Size() +
+		elm.LockSatoshi.Size() +
+		elm.LockDiamondList.Size()
+}
+
+func (elm *Action_45_EscrowCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.EscrowId.Serialize()
+	var b2, _ = elm.BuyerAddress.Serialize()
+	var b3, _ = elm.SellerAddress.Serialize()
+	var b4, _ = elm.ArbiterAddress.Serialize()
+	var b5, _ = elm.TimeoutBlockHeight.Serialize()
+	var b6, e1 = elm.LockAmount.Serialize()
+	if e1 != nil {
+		return nil, e1
+	}
+	var b7, _ = elm.LockSatoshi.Serialize()
+	var b8, e2 = elm.LockDiamondList.Serialize()
+	if e2 != nil {
+		return nil, e2
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_45_EscrowCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.EscrowId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ArbiterAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TimeoutBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_45_EscrowCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.BuyerAddress, // The buyer lock assets
+	}
+}
+
+func (act *Action_45_EscrowCreate) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	// Check the validity of ID value
+	if len(act.EscrowId) != stores.EscrowIdLength || act.EscrowId[0] == 0 || act.EscrowId[stores.EscrowIdLength-1] == 0 {
+		return fmt.Errorf("Escrow id format error.")
+	}
+	hasescrow, e := state.Escrow(act.EscrowId)
+	if e != nil {
+		return e
+	}
+	if hasescrow != nil {
+		return fmt.Errorf("Escrow id<%s> already.", act.EscrowId.ToHex())
+	}
+	// Check address
+	if !act.BuyerAddress.IsValid() || !act.SellerAddress.IsValid() || !act.ArbiterAddress.IsValid() {
+		return fmt.Errorf("Buyer, seller or arbiter address is invalid.")
+	}
+	if act.BuyerAddress.Equal(act.SellerAddress) ||
+		act.BuyerAddress.Equal(act.ArbiterAddress) ||
+		act.SellerAddress.Equal(act.ArbiterAddress) {
+		return fmt.Errorf("Buyer, seller and arbiter address cannot be the same.")
+	}
+	// Check timeout
+	if uint64(act.TimeoutBlockHeight) <= state.GetPendingBlockHeight() {
+		return fmt.Errorf("TimeoutBlockHeight %d has passed.", act.TimeoutBlockHeight)
+	}
+	// Check assets
+	dianum := int(act.LockDiamondList.Count)
+	if dianum != len(act.LockDiamondList.Diamonds) {
+		return fmt.Errorf("Diamonds quantity error")
+	}
+	if dianum > 200 {
+		return fmt.Errorf("Diamonds quantity cannot over 200")
+	}
+	isLockHac := act.LockAmount.IsPositive()
+	isLockSat := act.LockSatoshi.NotEmpty.Check() && act.LockSatoshi.ValueSAT > 0
+	if act.LockAmount.IsNegative() {
+		return fmt.Errorf("LockAmount cannot be negative.")
+	}
+	if !isLockHac && !isLockSat && dianum == 0 {
+		return fmt.Errorf("Escrow assets cannot be empty.")
+	}
+	// Lock diamonds
+	for i := 0; i < dianum; i++ {
+		diamond := act.LockDiamondList.Diamonds[i]
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		ckerr := CheckDiamondStatusNormalAndBelong(&diamond, diaitem, &act.BuyerAddress)
+		if ckerr != nil {
+			return ckerr
+		}
+		diaitem.Status = stores.DiamondStatusEscrow // Mark locked in escrow
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	if dianum > 0 {
+		e9 := DoSubDiamondFromChainStateV3(state, act.BuyerAddress, fields.DiamondNumber(dianum))
+		if e9 != nil {
+			return e9
+		}
+	}
+	// Lock bitcoin
+	if isLockSat {
+		e := DoSubSatoshiFromChainStateV3(state, act.BuyerAddress, act.LockSatoshi.ValueSAT)
+		if e != nil {
+			return e
+		}
+	}
+	// Lock HAC
+	if isLockHac {
+		e := DoSubBalanceFromChainState(state, act.BuyerAddress, act.LockAmount)
+		if e != nil {
+			return e
+		}
+	}
+	// Save
+	escrow := &stores.Escrow{
+		Status:             stores.EscrowStatusLocked,
+		BuyerAddress:       act.BuyerAddress,
+		SellerAddress:      act.SellerAddress,
+		ArbiterAddress:     act.ArbiterAddress,
+		TimeoutBlockHeight: act.TimeoutBlockHeight,
+		LockAmount:         act.LockAmount,
+		LockSatoshi:        act.LockSatoshi,
+		LockDiamondList:    act.LockDiamondList,
+		CloseBlockHeight:   0,
+	}
+	return state.EscrowCreate(act.EscrowId, escrow)
+}
+
+func (act *Action_45_EscrowCreate) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_45_EscrowCreate) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_45_EscrowCreate) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_45_EscrowCreate) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_45_EscrowCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Any two of the buyer, seller and arbiter release the assets to the seller
+type Action_46_EscrowRelease struct {
+	EscrowId fields.EscrowId
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_46_EscrowRelease) Kind() uint16 {
+	return 46
+}
+
+// json api
+func (elm *Action_46_EscrowRelease) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_46_EscrowRelease) Size() uint32 {
+	return 2 + elm.EscrowId.Size()
+}
+
+func (elm *Action_46_EscrowRelease) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.EscrowId.Serialize()
+	buffer.Write(b1)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_46_EscrowRelease) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.EscrowId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_46_EscrowRelease) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Check two of three signatures when execute
+}
+
+func (act *Action_46_EscrowRelease) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	escrow, e := loadOpenEscrow(state, act.EscrowId)
+	if e != nil {
+		return e
+	}
+	if countEscrowSigns(act.belong_trs_v3, escrow) < 2 {
+		return fmt.Errorf("Escrow release need two of buyer, seller and arbiter signatures.")
+	}
+	return closeEscrow(state, act.EscrowId, escrow, stores.EscrowStatusReleased)
+}
+
+func (act *Action_46_EscrowRelease) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_46_EscrowRelease) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_46_EscrowRelease) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_46_EscrowRelease) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_46_EscrowRelease) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Refund the assets to the buyer by any two signatures, or by anyone after timeout
+type Action_47_EscrowRefund struct {
+	EscrowId fields.EscrowId
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_47_EscrowRefund) Kind() uint16 {
+	return 47
+}
+
+// json api
+func (elm *Action_47_EscrowRefund) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_47_EscrowRefund) Size() uint32 {
+	return 2 + elm.EscrowId.Size()
+}
+
+func (elm *Action_47_EscrowRefund) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.EscrowId.Serialize()
+	buffer.Write(b1)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_47_EscrowRefund) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.EscrowId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_47_EscrowRefund) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Check two of three signatures when execute
+}
+
+func (act *Action_47_EscrowRefund) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	escrow, e := loadOpenEscrow(state, act.EscrowId)
+	if e != nil {
+		return e
+	}
+	// Only refund to the buyer, anyone can do it after timeout
+	isTimeout := state.GetPendingBlockHeight() >= uint64(escrow.TimeoutBlockHeight)
+	if !isTimeout && countEscrowSigns(act.belong_trs_v3, escrow) < 2 {
+		return fmt.Errorf("Escrow refund need two of buyer, seller and arbiter signatures before timeout height %d.", escrow.TimeoutBlockHeight)
+	}
+	return closeEscrow(state, act.EscrowId, escrow, stores.EscrowStatusRefunded)
+}
+
+func (act *Action_47_EscrowRefund) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_47_EscrowRefund) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_47_EscrowRefund) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_47_EscrowRefund) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_47_EscrowRefund) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+func loadOpenEscrow(state interfaces.ChainStateOperation, id fields.EscrowId) (*stores.Escrow, error) {
+	escrow, e := state.Escrow(id)
+	if e != nil {
+		return nil, e
+	}
+	if escrow == nil {
+		return nil, fmt.Errorf("Escrow id<%s> not find.", id.ToHex())
+	}
+	if escrow.IsClosed() {
+		return nil, fmt.Errorf("Escrow id<%s> has been closed.", id.ToHex())
+	}
+	return escrow, nil
+}
+
+// Number of valid signatures of the buyer, seller and arbiter
+func countEscrowSigns(trs interfaces.Transaction, escrow *stores.Escrow) int {
+	count := 0
+	for _, addr := range []fields.Address{escrow.BuyerAddress, escrow.SellerAddress, escrow.ArbiterAddress} {
+		ok, e := trs.VerifyTargetSigns([]fields.Address{addr})
+		if ok && e == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Move the locked assets to the seller (released) or the buyer (refunded)
+func closeEscrow(state interfaces.ChainStateOperation, id fields.EscrowId, escrow *stores.Escrow, status fields.VarUint1) error {
+	toAddr := escrow.BuyerAddress
+	if status == stores.EscrowStatusReleased {
+		toAddr = escrow.SellerAddress
+	}
+	// Unlock diamonds
+	dianum := len(escrow.LockDiamondList.Diamonds)
+	for i := 0; i < dianum; i++ {
+		diamond := escrow.LockDiamondList.Diamonds[i]
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Status != stores.DiamondStatusEscrow {
+			return fmt.Errorf("Diamond <%s> status is not [stores.DiamondStatusEscrow].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal
+		diaitem.Address = toAddr
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	if dianum > 0 {
+		e9 := DoAddDiamondFromChainStateV3(state, toAddr, fields.DiamondNumber(dianum))
+		if e9 != nil {
+			return e9
+		}
+	}
+	// Unlock bitcoin
+	if escrow.LockSatoshi.NotEmpty.Check() && escrow.LockSatoshi.ValueSAT > 0 {
+		e := DoAddSatoshiFromChainStateV3(state, toAddr, escrow.LockSatoshi.ValueSAT)
+		if e != nil {
+			return e
+		}
+	}
+	// Unlock HAC
+	if escrow.LockAmount.IsPositive() {
+		e := DoAddBalanceFromChainState(state, toAddr, escrow.LockAmount)
+		if e != nil {
+			return e
+		}
+	}
+	// Not deleted, but saved for block rollback
+	escrow.Status = status
+	escrow.CloseBlockHeight = fields.BlockHeight(state.GetPendingBlockHeight())
+	return state.EscrowUpdate(id, escrow)
+}
@@ -0,0 +1,515 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+可撤销、多受益人的线性锁仓
+
+. 创建：付款人锁定 HAC，按比例分配给多个受益人，可指定撤销人
+. 提取：任何人可触发，按受益人比例提取已归属额度
+. 撤销：撤销人签名，停止归属，未归属余额退还付款人
+
+*/
+
+// Create a revocable and multi-beneficiary linear lock
+type Action_39_GrantLockblsCreate struct {
+	LockblsId           fields.LockblsId       // Lock ID
+	PaymentAddress      fields.Address         // Payment address
+	RevokerAddress      fields.OptionalAddress // Who can revoke, cannot be revoked if not exist
+	EffectBlockHeight   fields.BlockHeight     // Effective (start) block
+	LinearBlockNumber   fields.VarUint3        // Number of stepping blocks
+	TotalStockAmount    fields.Amount          // Total deposit limit
+	LinearReleaseAmount fields.Amount          // Limit vested each time
+
+	BeneficiaryCount       fields.VarUint1
+	BeneficiaryAddresses   []fields.Address
+	BeneficiaryProportions []fields.VarUint2 // Unit: 1/10000, the sum must be 10000
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_39_GrantLockblsCreate) Kind() uint16 {
+	return 39
+}
+
+// json api
+func (elm *Action_39_GrantLockblsCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_39_GrantLockblsCreate) Size() uint32 {
+	size := 2 + elm.LockblsId.Size() +
+		elm.PaymentAddress.Size() +
+		elm.RevokerAddress.Size() +
+		elm.EffectBlockHeight.Size() +
+		elm.LinearBlockNumber.Size() +
+		elm.TotalStockAmount.Size() +
+		elm.LinearReleaseAmount.Size() +
+		elm.BeneficiaryCount.Size()
+	size += uint32(elm.BeneficiaryCount) * (fields.AddressSize + 2)
+	return size
+}
+
+func (elm *Action_39_GrantLockblsCreate) Serialize() ([]byte, error) {
+	if int(elm.BeneficiaryCount) != len(elm.BeneficiaryAddresses) ||
+		int(elm.BeneficiaryCount) != len(elm.BeneficiaryProportions) {
+		return nil, fmt.Errorf("Beneficiary count error.")
+	}
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.LockblsId.Serialize()
+	var b2, _ = elm.PaymentAddress.Serialize()
+	var b3, _ = elm.RevokerAddress.Serialize()
+	var b4, _ = elm.EffectBlockHeight.Serialize()
+	var b5, _ = elm.LinearBlockNumber.Serialize()
+	var b6, _ = elm.TotalStockAmount.Serialize()
+	var b7, _ = elm.LinearReleaseAmount.Serialize()
+	var b8, _ = elm.BeneficiaryCount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	for i := 0; i < int(elm.BeneficiaryCount); i++ {
+		var bt1, _ = elm.BeneficiaryAddresses[i].Serialize()
+		var bt2, _ = elm.BeneficiaryProportions[i].Serialize()
+		buffer.Write(bt1)
+		buffer.Write(bt2)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_39_GrantLockblsCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LockblsId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PaymentAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RevokerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.EffectBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LinearBlockNumber.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TotalStockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LinearReleaseAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BeneficiaryCount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	bcn := int(elm.BeneficiaryCount)
+	elm.BeneficiaryAddresses = make([]fields.Address, bcn)
+	elm.BeneficiaryProportions = make([]fields.VarUint2, bcn)
+	for i := 0; i < bcn; i++ {
+		seek, e = elm.BeneficiaryAddresses[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+		seek, e = elm.BeneficiaryProportions[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+func (act *Action_39_GrantLockblsCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.PaymentAddress, // Signature is required for the payment account of warehouse lock
+	}
+}
+
+func (act *Action_39_GrantLockblsCreate) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	// Check the validity of ID value
+	if len(act.LockblsId) != stores.GrantLockblsIdLength || act.LockblsId[0] == 0 || act.LockblsId[stores.GrantLockblsIdLength-1] == 0 {
+		return fmt.Errorf("LockblsId format error.")
+	}
+	// Check whether the key already exists
+	haslock, e := state.GrantLockbls(act.LockblsId)
+	if e != nil {
+		return e
+	}
+	if haslock != nil {
+		return fmt.Errorf("Grant lockbls id<%s> already.", act.LockblsId.ToHex())
+	}
+	// Check step block number, same as Action_9_LockblsCreate
+	if act.LinearBlockNumber < 288 {
+		return fmt.Errorf("LinearBlockNumber cannot less 288.")
+	}
+	if act.LinearBlockNumber > 1600*10000 {
+		return fmt.Errorf("LinearBlockNumber cannot over 16000000.")
+	}
+	// Check amount
+	if !act.TotalStockAmount.IsPositive() || !act.LinearReleaseAmount.IsPositive() {
+		return fmt.Errorf("TotalStockAmount or LinearReleaseAmount error.")
+	}
+	if act.TotalStockAmount.LessThan(&act.LinearReleaseAmount) {
+		return fmt.Errorf("LinearReleaseAmount cannot more than TotalStockAmount.")
+	}
+	// Check beneficiaries
+	bcn := int(act.BeneficiaryCount)
+	if bcn == 0 || bcn > stores.GrantLockblsBeneficiaryMaxCount {
+		return fmt.Errorf("Beneficiary count must between 1 and %d.", stores.GrantLockblsBeneficiaryMaxCount)
+	}
+	if bcn != len(act.BeneficiaryAddresses) || bcn != len(act.BeneficiaryProportions) {
+		return fmt.Errorf("Beneficiary count error.")
+	}
+	beneficiaries := make([]*stores.GrantBeneficiary, bcn)
+	proportionSum := 0
+	for i := 0; i < bcn; i++ {
+		addr := act.BeneficiaryAddresses[i]
+		if !addr.IsValid() {
+			return fmt.Errorf("Beneficiary address %d is invalid.", i)
+		}
+		for j := 0; j < i; j++ {
+			if addr.Equal(act.BeneficiaryAddresses[j]) {
+				return fmt.Errorf("Beneficiary address %s is repeated.", addr.ToReadable())
+			}
+		}
+		if act.BeneficiaryProportions[i] == 0 {
+			return fmt.Errorf("Beneficiary proportion cannot be zero.")
+		}
+		proportionSum += int(act.BeneficiaryProportions[i])
+		beneficiaries[i] = &stores.GrantBeneficiary{
+			Address:        addr,
+			Proportion:     act.BeneficiaryProportions[i],
+			ReleasedAmount: *fields.NewEmptyAmount(),
+		}
+	}
+	if proportionSum != stores.GrantLockblsProportionBase {
+		return fmt.Errorf("Beneficiary proportions sum must be %d but got %d.", stores.GrantLockblsProportionBase, proportionSum)
+	}
+	// Deduct payment
+	e1 := DoSubBalanceFromChainState(state, act.PaymentAddress, act.TotalStockAmount)
+	if e1 != nil {
+		return e1
+	}
+	// Save lock
+	grantlock := &stores.GrantLockbls{
+		PaymentAddress:      act.PaymentAddress,
+		RevokerAddress:      act.RevokerAddress,
+		EffectBlockHeight:   act.EffectBlockHeight,
+		LinearBlockNumber:   act.LinearBlockNumber,
+		TotalLockAmount:     act.TotalStockAmount,
+		LinearReleaseAmount: act.LinearReleaseAmount,
+		IsRevoked:           fields.CreateBool(false),
+		BeneficiaryCount:    act.BeneficiaryCount,
+		Beneficiaries:       beneficiaries,
+	}
+	return state.GrantLockblsCreate(act.LockblsId, grantlock)
+}
+
+func (act *Action_39_GrantLockblsCreate) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_39_GrantLockblsCreate) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_39_GrantLockblsCreate) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_39_GrantLockblsCreate) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_39_GrantLockblsCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Release the vested share to a beneficiary
+type Action_40_GrantLockblsRelease struct {
+	LockblsId          fields.LockblsId // Lock ID
+	BeneficiaryAddress fields.Address   // Release to
+	ReleaseAmount      fields.Amount    // Current withdrawal limit
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_40_GrantLockblsRelease) Kind() uint16 {
+	return 40
+}
+
+// json api
+func (elm *Action_40_GrantLockblsRelease) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_40_GrantLockblsRelease) Size() uint32 {
+	return 2 + elm.LockblsId.Size() +
+		elm.BeneficiaryAddress.Size() +
+		elm.ReleaseAmount.Size()
+}
+
+func (elm *Action_40_GrantLockblsRelease) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.LockblsId.Serialize()
+	var b2, _ = elm.BeneficiaryAddress.Serialize()
+	var b3, _ = elm.ReleaseAmount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_40_GrantLockblsRelease) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LockblsId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BeneficiaryAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReleaseAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_40_GrantLockblsRelease) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Only release to the beneficiary, anyone can do it
+}
+
+func (act *Action_40_GrantLockblsRelease) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	grantlock, e := state.GrantLockbls(act.LockblsId)
+	if e != nil {
+		return e
+	}
+	if grantlock == nil {
+		return fmt.Errorf("Grant lockbls id<%s> not find.", act.LockblsId.ToHex())
+	}
+	idx, beneficiary := grantlock.GetBeneficiary(act.BeneficiaryAddress)
+	if beneficiary == nil {
+		return fmt.Errorf("Address %s is not the beneficiary.", act.BeneficiaryAddress.ToReadable())
+	}
+	if !act.ReleaseAmount.IsPositive() {
+		return fmt.Errorf("ReleaseAmount must be positive.")
+	}
+	// Calculate withdrawal limit
+	currentBlockHeight := state.GetPendingBlockHeight()
+	claimable := grantlock.ClaimableAmount(idx, currentBlockHeight)
+	if claimable.Cmp(act.ReleaseAmount.GetValue()) < 0 {
+		return fmt.Errorf("Current Max Release Amount not enough.") // The current available balance is insufficient
+	}
+	// Update released
+	released, e := beneficiary.ReleasedAmount.Add(&act.ReleaseAmount)
+	if e != nil {
+		return e
+	}
+	beneficiary.ReleasedAmount = *released
+	e = state.GrantLockblsUpdate(act.LockblsId, grantlock)
+	if e != nil {
+		return e
+	}
+	// Plus balance
+	return DoAddBalanceFromChainState(state, beneficiary.Address, act.ReleaseAmount)
+}
+
+func (act *Action_40_GrantLockblsRelease) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_40_GrantLockblsRelease) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_40_GrantLockblsRelease) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_40_GrantLockblsRelease) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_40_GrantLockblsRelease) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Stop vesting and return the unvested remainder to the payment address
+type Action_41_GrantLockblsRevoke struct {
+	LockblsId fields.LockblsId // Lock ID
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_41_GrantLockblsRevoke) Kind() uint16 {
+	return 41
+}
+
+// json api
+func (elm *Action_41_GrantLockblsRevoke) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_41_GrantLockblsRevoke) Size() uint32 {
+	return 2 + elm.LockblsId.Size()
+}
+
+func (elm *Action_41_GrantLockblsRevoke) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.LockblsId.Serialize()
+	buffer.Write(b1)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_41_GrantLockblsRevoke) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LockblsId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_41_GrantLockblsRevoke) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Check the signature of revoker when execute
+}
+
+func (act *Action_41_GrantLockblsRevoke) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	grantlock, e := state.GrantLockbls(act.LockblsId)
+	if e != nil {
+		return e
+	}
+	if grantlock == nil {
+		return fmt.Errorf("Grant lockbls id<%s> not find.", act.LockblsId.ToHex())
+	}
+	if grantlock.RevokerAddress.Exist.Is(false) {
+		return fmt.Errorf("Grant lockbls id<%s> cannot be revoked.", act.LockblsId.ToHex())
+	}
+	if grantlock.IsRevoked.Check() {
+		return fmt.Errorf("Grant lockbls id<%s> has been revoked.", act.LockblsId.ToHex())
+	}
+	// Check the signature of revoker
+	revoker := grantlock.RevokerAddress.Addr
+	signok, e1 := act.belong_trs_v3.VerifyTargetSigns([]fields.Address{revoker})
+	if e1 != nil {
+		return e1
+	}
+	if !signok {
+		return fmt.Errorf("Revoker %s signature verify fail.", revoker.ToReadable())
+	}
+	// Stop vesting
+	currentBlockHeight := state.GetPendingBlockHeight()
+	vested := grantlock.VestedAmount(currentBlockHeight)
+	grantlock.IsRevoked.Set(true)
+	grantlock.RevokeBlockHeight = fields.BlockHeight(currentBlockHeight)
+	e = state.GrantLockblsUpdate(act.LockblsId, grantlock)
+	if e != nil {
+		return e
+	}
+	// Return the unvested remainder
+	vestedAmt, e := fields.NewAmountByBigInt(vested)
+	if e != nil {
+		return e
+	}
+	unvested, e := grantlock.TotalLockAmount.Sub(vestedAmt)
+	if e != nil {
+		return e
+	}
+	if unvested.IsPositive() {
+		return DoAddBalanceFromChainState(state, grantlock.PaymentAddress, *unvested)
+	}
+	return nil
+}
+
+func (act *Action_41_GrantLockblsRevoke) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_41_GrantLockblsRevoke) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_41_GrantLockblsRevoke) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_41_GrantLockblsRevoke) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_41_GrantLockblsRevoke) IsBurning90PersentTxFees() bool {
+	return false
+}
@@ -0,0 +1,448 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+定期扣款（订阅授权）
+
+. 创建：付款人签名授权，收款人每周期最多拉取固定额度，直到结束高度或取消
+. 拉取：收款人签名，从付款人余额中扣款
+. 取消：付款人或收款人签名
+
+*/
+
+const (
+	RecurringPaymentPeriodBlockNumberMin = 288
+	RecurringPaymentPeriodBlockNumberMax = 1600 * 10000
+)
+
+// Authorize a payee to pull up to a fixed amount per period
+type Action_42_RecurringPaymentCreate struct {
+	PaymentId         fields.RecurringPaymentId // Payment ID
+	PayerAddress      fields.Address            // Pay from
+	PayeeAddress      fields.Address            // Pull to
+	StartBlockHeight  fields.BlockHeight        // The first period starts at
+	EndBlockHeight    fields.BlockHeight        // 0 means no end
+	PeriodBlockNumber fields.VarUint3           // Number of blocks per period
+	AmountPerPeriod   fields.Amount             // Pull limit of each period
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_42_RecurringPaymentCreate) Kind() uint16 {
+	return 42
+}
+
+// json api
+func (elm *Action_42_RecurringPaymentCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_42_RecurringPaymentCreate) Size() uint32 {
+	return 2 + elm.PaymentId.Size() +
+		elm.PayerAddress.Size() +
+		elm.PayeeAddress.Size() +
+		elm.StartBlockHeight.Size() +
+		elm.EndBlockHeight.Size() +
+		elm.PeriodBlockNumber.Size() +
+		elm.AmountPerPeriod.Size()
+}
+
+func (elm *Action_42_RecurringPaymentCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.PaymentId.Serialize()
+	var b2, _ = elm.PayerAddress.Serialize()
+	var b3, _ = elm.PayeeAddress.Serialize()
+	var b4, _ = elm.StartBlockHeight.Serialize()
+	var b5, _ = elm.EndBlockHeight.Serialize()
+	var b6, _ = elm.PeriodBlockNumber.Serialize()
+	var b7, e = elm.AmountPerPeriod.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_42_RecurringPaymentCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.PaymentId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PayerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PayeeAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.StartBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.EndBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PeriodBlockNumber.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AmountPerPeriod.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_42_RecurringPaymentCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.PayerAddress, // The payer authorize
+	}
+}
+
+func (act *Action_42_RecurringPaymentCreate) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	// Check the validity of ID value
+	if len(act.PaymentId) != stores.RecurringPaymentIdLength || act.PaymentId[0] == 0 || act.PaymentId[stores.RecurringPaymentIdLength-1] == 0 {
+		return fmt.Errorf("Recurring payment id format error.")
+	}
+	haspay, e := state.RecurringPayment(act.PaymentId)
+	if e != nil {
+		return e
+	}
+	if haspay != nil {
+		return fmt.Errorf("Recurring payment id<%s> already.", act.PaymentId.ToHex())
+	}
+	// Check address
+	if !act.PayerAddress.IsValid() || !act.PayeeAddress.IsValid() {
+		return fmt.Errorf("Payer or payee address is invalid.")
+	}
+	if act.PayerAddress.Equal(act.PayeeAddress) {
+		return fmt.Errorf("Payer and payee address cannot be the same.")
+	}
+	// Check period
+	if act.PeriodBlockNumber < RecurringPaymentPeriodBlockNumberMin || act.PeriodBlockNumber > RecurringPaymentPeriodBlockNumberMax {
+		return fmt.Errorf("PeriodBlockNumber must between %d and %d.", RecurringPaymentPeriodBlockNumberMin, RecurringPaymentPeriodBlockNumberMax)
+	}
+	if act.EndBlockHeight > 0 && act.EndBlockHeight <= act.StartBlockHeight {
+		return fmt.Errorf("EndBlockHeight must more than StartBlockHeight.")
+	}
+	if act.EndBlockHeight > 0 && uint64(act.EndBlockHeight) <= state.GetPendingBlockHeight() {
+		return fmt.Errorf("EndBlockHeight %d has passed.", act.EndBlockHeight)
+	}
+	// Check amount
+	if !act.AmountPerPeriod.IsPositive() {
+		return fmt.Errorf("AmountPerPeriod must be positive.")
+	}
+	// Save, the payment is not locked and pulled from the balance
+	payment := &stores.RecurringPayment{
+		PayerAddress:        act.PayerAddress,
+		PayeeAddress:        act.PayeeAddress,
+		StartBlockHeight:    act.StartBlockHeight,
+		EndBlockHeight:      act.EndBlockHeight,
+		PeriodBlockNumber:   act.PeriodBlockNumber,
+		AmountPerPeriod:     act.AmountPerPeriod,
+		CurrentPeriodIndex:  0,
+		CurrentPulledAmount: *fields.NewEmptyAmount(),
+		TotalPulledAmount:   *fields.NewEmptyAmount(),
+		IsCancelled:         fields.CreateBool(false),
+	}
+	return state.RecurringPaymentCreate(act.PaymentId, payment)
+}
+
+func (act *Action_42_RecurringPaymentCreate) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_42_RecurringPaymentCreate) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_42_RecurringPaymentCreate) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_42_RecurringPaymentCreate) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_42_RecurringPaymentCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// The payee pulls from the payer within the limit of current period
+type Action_43_RecurringPaymentPull struct {
+	PaymentId  fields.RecurringPaymentId // Payment ID
+	PullAmount fields.Amount
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_43_RecurringPaymentPull) Kind() uint16 {
+	return 43
+}
+
+// json api
+func (elm *Action_43_RecurringPaymentPull) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_43_RecurringPaymentPull) Size() uint32 {
+	return 2 + elm.PaymentId.Size() + elm.PullAmount.Size()
+}
+
+func (elm *Action_43_RecurringPaymentPull) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.PaymentId.Serialize()
+	var b2, e = elm.PullAmount.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_43_RecurringPaymentPull) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.PaymentId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PullAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_43_RecurringPaymentPull) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Check the signature of payee when execute
+}
+
+func (act *Action_43_RecurringPaymentPull) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	payment, e := state.RecurringPayment(act.PaymentId)
+	if e != nil {
+		return e
+	}
+	if payment == nil {
+		return fmt.Errorf("Recurring payment id<%s> not find.", act.PaymentId.ToHex())
+	}
+	// Check the signature of payee
+	signok, e1 := act.belong_trs_v3.VerifyTargetSigns([]fields.Address{payment.PayeeAddress})
+	if e1 != nil {
+		return e1
+	}
+	if !signok {
+		return fmt.Errorf("Payee %s signature verify fail.", payment.PayeeAddress.ToReadable())
+	}
+	if !act.PullAmount.IsPositive() {
+		return fmt.Errorf("PullAmount must be positive.")
+	}
+	// Check limit
+	currentBlockHeight := state.GetPendingBlockHeight()
+	if !payment.IsActive(currentBlockHeight) {
+		return fmt.Errorf("Recurring payment id<%s> is not active at height %d.", act.PaymentId.ToHex(), currentBlockHeight)
+	}
+	pullable, e := payment.PullableAmount(currentBlockHeight)
+	if e != nil {
+		return e
+	}
+	if pullable.LessThan(&act.PullAmount) {
+		return fmt.Errorf("Pull amount %s more than the remaining %s of current period.",
+			act.PullAmount.ToFinString(), pullable.ToFinString())
+	}
+	// Update pulled
+	period := payment.PeriodIndex(currentBlockHeight)
+	if period != uint64(payment.CurrentPeriodIndex) {
+		payment.CurrentPeriodIndex = fields.VarUint4(period)
+		payment.CurrentPulledAmount = *fields.NewEmptyAmount()
+	}
+	pulled, e := payment.CurrentPulledAmount.Add(&act.PullAmount)
+	if e != nil {
+		return e
+	}
+	payment.CurrentPulledAmount = *pulled
+	total, e := payment.TotalPulledAmount.Add(&act.PullAmount)
+	if e != nil {
+		return e
+	}
+	payment.TotalPulledAmount = *total
+	e = state.RecurringPaymentUpdate(act.PaymentId, payment)
+	if e != nil {
+		return e
+	}
+	// Transfer, fails if the balance of payer is insufficient
+	return DoSimpleTransferFromChainState(state, payment.PayerAddress, payment.PayeeAddress, act.PullAmount)
+}
+
+func (act *Action_43_RecurringPaymentPull) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_43_RecurringPaymentPull) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_43_RecurringPaymentPull) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_43_RecurringPaymentPull) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_43_RecurringPaymentPull) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Cancel the authorization by the payer or payee
+type Action_44_RecurringPaymentCancel struct {
+	PaymentId fields.RecurringPaymentId // Payment ID
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_44_RecurringPaymentCancel) Kind() uint16 {
+	return 44
+}
+
+// json api
+func (elm *Action_44_RecurringPaymentCancel) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_44_RecurringPaymentCancel) Size() uint32 {
+	return 2 + elm.PaymentId.Size()
+}
+
+func (elm *Action_44_RecurringPaymentCancel) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.PaymentId.Serialize()
+	buffer.Write(b1)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_44_RecurringPaymentCancel) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.PaymentId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_44_RecurringPaymentCancel) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Check the signature of payer or payee when execute
+}
+
+func (act *Action_44_RecurringPaymentCancel) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	payment, e := state.RecurringPayment(act.PaymentId)
+	if e != nil {
+		return e
+	}
+	if payment == nil {
+		return fmt.Errorf("Recurring payment id<%s> not find.", act.PaymentId.ToHex())
+	}
+	if payment.IsCancelled.Check() {
+		return fmt.Errorf("Recurring payment id<%s> has been cancelled.", act.PaymentId.ToHex())
+	}
+	// Either the payer or the payee can cancel
+	signok, _ := act.belong_trs_v3.VerifyTargetSigns([]fields.Address{payment.PayerAddress})
+	if !signok {
+		signok, _ = act.belong_trs_v3.VerifyTargetSigns([]fields.Address{payment.PayeeAddress})
+	}
+	if !signok {
+		return fmt.Errorf("Payer or payee signature verify fail.")
+	}
+	// Not deleted, but saved for block rollback
+	payment.IsCancelled.Set(true)
+	return state.RecurringPaymentUpdate(act.PaymentId, payment)
+}
+
+func (act *Action_44_RecurringPaymentCancel) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_44_RecurringPaymentCancel) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_44_RecurringPaymentCancel) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_44_RecurringPaymentCancel) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_44_RecurringPaymentCancel) IsBurning90PersentTxFees() bool {
+	return false
+}
@@ -0,0 +1,380 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+定时到账转账
+
+. 创建：从付款人扣除 HAC、SAT 或钻石，指定收款人和解锁高度
+. 钻石归属立即改为收款人，但状态为 DiamondStatusTimeLocked，不可转账
+. 提取：到达解锁高度后任何人可触发，资产计入收款人余额
+
+*/
+
+// Transfer HAC, SAT or diamonds that can be spent by the recipient after the unlock height
+type Action_50_TimeLockTransferCreate struct {
+	TransferId        fields.TimeLockTransferId
+	FromAddress       fields.Address
+	ToAddress         fields.Address
+	UnlockBlockHeight fields.BlockHeight
+
+	Amount      fields.Amount
+	Satoshi     fields.SatoshiVariation
+	DiamondList fields.DiamondListMaxLen200
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_50_TimeLockTransferCreate) Kind() uint16 {
+	return 50
+}
+
+// json api
+func (elm *Action_50_TimeLockTransferCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_50_TimeLockTransferCreate) Size() uint32 {
+	return 2 + elm.TransferId.Size() +
+		elm.FromAddress.Size() +
+		elm.ToAddress.Size() +
+		elm.UnlockBlockHeight.Size() +
+		elm.Amount.Size() +
+		elm.Satoshi.Size() +
+		elm.DiamondList.Size()
+}
+
+func (elm *Action_50_TimeLockTransferCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.TransferId.Serialize()
+	var b2, _ = elm.FromAddress.Serialize()
+	var b3, _ = elm.ToAddress.Serialize()
+	var b4, _ = elm.UnlockBlockHeight.Serialize()
+	var b5, e1 = elm.Amount.Serialize()
+	if e1 != nil {
+		return nil, e1
+	}
+	var b6, _ = elm.Satoshi.Serialize()
+	var b7, e2 = elm.DiamondList.Serialize()
+	if e2 != nil {
+		return nil, e2
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_50_TimeLockTransferCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.TransferId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.FromAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ToAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.UnlockBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Amount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Satoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_50_TimeLockTransferCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		elm.FromAddress, // Pay from
+	}
+}
+
+func (act *Action_50_TimeLockTransferCreate) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	// Check the validity of ID value
+	if len(act.TransferId) != stores.TimeLockTransferIdLength || act.TransferId[0] == 0 || act.TransferId[stores.TimeLockTransferIdLength-1] == 0 {
+		return fmt.Errorf("Time lock transfer id format error.")
+	}
+	hastrs, e := state.TimeLockTransfer(act.TransferId)
+	if e != nil {
+		return e
+	}
+	if hastrs != nil {
+		return fmt.Errorf("Time lock transfer id<%s> already.", act.TransferId.ToHex())
+	}
+	// Check address
+	if !act.ToAddress.IsValid() {
+		return fmt.Errorf("ToAddress is invalid.")
+	}
+	// Check unlock height
+	if uint64(act.UnlockBlockHeight) <= state.GetPendingBlockHeight() {
+		return fmt.Errorf("UnlockBlockHeight %d has passed.", act.UnlockBlockHeight)
+	}
+	// Check assets
+	dianum := int(act.DiamondList.Count)
+	if dianum != len(act.DiamondList.Diamonds) {
+		return fmt.Errorf("Diamonds quantity error")
+	}
+	if dianum > 200 {
+		return fmt.Errorf("Diamonds quantity cannot over 200")
+	}
+	if act.Amount.IsNegative() {
+		return fmt.Errorf("Amount cannot be negative.")
+	}
+	isHac := act.Amount.IsPositive()
+	isSat := act.Satoshi.NotEmpty.Check() && act.Satoshi.ValueSAT > 0
+	if !isHac && !isSat && dianum == 0 {
+		return fmt.Errorf("Time lock transfer assets cannot be empty.")
+	}
+	// Diamonds belong to the recipient but cannot be transferred
+	for i := 0; i < dianum; i++ {
+		diamond := act.DiamondList.Diamonds[i]
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		ckerr := CheckDiamondStatusNormalAndBelong(&diamond, diaitem, &act.FromAddress)
+		if ckerr != nil {
+			return ckerr
+		}
+		diaitem.Status = stores.DiamondStatusTimeLocked
+		diaitem.Address = act.ToAddress
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	if dianum > 0 {
+		e9 := DoSubDiamondFromChainStateV3(state, act.FromAddress, fields.DiamondNumber(dianum))
+		if e9 != nil {
+			return e9
+		}
+	}
+	if isSat {
+		e := DoSubSatoshiFromChainStateV3(state, act.FromAddress, act.Satoshi.ValueSAT)
+		if e != nil {
+			return e
+		}
+	}
+	if isHac {
+		e := DoSubBalanceFromChainState(state, act.FromAddress, act.Amount)
+		if e != nil {
+			return e
+		}
+	}
+	// Save
+	timelock := &stores.TimeLockTransfer{
+		Status:            stores.TimeLockTransferStatusLocked,
+		FromAddress:       act.FromAddress,
+		ToAddress:         act.ToAddress,
+		UnlockBlockHeight: act.UnlockBlockHeight,
+		Amount:            act.Amount,
+		Satoshi:           act.Satoshi,
+		DiamondList:       act.DiamondList,
+		ClaimBlockHeight:  0,
+	}
+	return state.TimeLockTransferCreate(act.TransferId, timelock)
+}
+
+func (act *Action_50_TimeLockTransferCreate) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_50_TimeLockTransferCreate) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_50_TimeLockTransferCreate) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_50_TimeLockTransferCreate) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_50_TimeLockTransferCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Credit the unlocked assets to the balance of recipient
+type Action_51_TimeLockTransferClaim struct {
+	TransferId fields.TimeLockTransferId
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_51_TimeLockTransferClaim) Kind() uint16 {
+	return 51
+}
+
+// json api
+func (elm *Action_51_TimeLockTransferClaim) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_51_TimeLockTransferClaim) Size() uint32 {
+	return 2 + elm.TransferId.Size()
+}
+
+func (elm *Action_51_TimeLockTransferClaim) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.TransferId.Serialize()
+	buffer.Write(b1)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_51_TimeLockTransferClaim) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.TransferId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_51_TimeLockTransferClaim) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Only credit to the recipient, anyone can do it
+}
+
+func (act *Action_51_TimeLockTransferClaim) WriteInChainState(state interfaces.ChainStateOperation) error {
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	timelock, e := state.TimeLockTransfer(act.TransferId)
+	if e != nil {
+		return e
+	}
+	if timelock == nil {
+		return fmt.Errorf("Time lock transfer id<%s> not find.", act.TransferId.ToHex())
+	}
+	if timelock.Status != stores.TimeLockTransferStatusLocked {
+		return fmt.Errorf("Time lock transfer id<%s> has been claimed.", act.TransferId.ToHex())
+	}
+	paddingHeight := state.GetPendingBlockHeight()
+	if paddingHeight < uint64(timelock.UnlockBlockHeight) {
+		return fmt.Errorf("Time lock transfer id<%s> unlock at height %d.", act.TransferId.ToHex(), timelock.UnlockBlockHeight)
+	}
+	toAddr := timelock.ToAddress
+	// Unlock diamonds
+	dianum := len(timelock.DiamondList.Diamonds)
+	for i := 0; i < dianum; i++ {
+		diamond := timelock.DiamondList.Diamonds[i]
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Status != stores.DiamondStatusTimeLocked {
+			return fmt.Errorf("Diamond <%s> status is not [stores.DiamondStatusTimeLocked].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	if dianum > 0 {
+		e9 := DoAddDiamondFromChainStateV3(state, toAddr, fields.DiamondNumber(dianum))
+		if e9 != nil {
+			return e9
+		}
+	}
+	if timelock.Satoshi.NotEmpty.Check() && timelock.Satoshi.ValueSAT > 0 {
+		e := DoAddSatoshiFromChainStateV3(state, toAddr, timelock.Satoshi.ValueSAT)
+		if e != nil {
+			return e
+		}
+	}
+	if timelock.Amount.IsPositive() {
+		e := DoAddBalanceFromChainState(state, toAddr, timelock.Amount)
+		if e != nil {
+			return e
+		}
+	}
+	// Not deleted, but saved for block rollback
+	timelock.Status = stores.TimeLockTransferStatusClaimed
+	timelock.ClaimBlockHeight = fields.BlockHeight(paddingHeight)
+	return state.TimeLockTransferUpdate(act.TransferId, timelock)
+}
+
+func (act *Action_51_TimeLockTransferClaim) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_51_TimeLockTransferClaim) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_51_TimeLockTransferClaim) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_51_TimeLockTransferClaim) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_51_TimeLockTransferClaim) IsBurning90PersentTxFees() bool {
+	return false
+}
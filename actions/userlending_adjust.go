@@ -0,0 +1,497 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/interfacev2"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+	"math/big"
+)
+
+/*
+
+用户间借贷：部分还款与协商调整
+
+部分还款流程：
+
+. 检查合约状态，仅抵押人可在可赎回期内操作
+. 检查释放的抵押物属于合约，且剩余抵押物不能为空
+. 检查还款比例不低于释放抵押物的比例
+. 转移HAC给贷出人
+. 解除钻石抵押状态，归还比特币
+. 更新合约赎回额度
+
+协商调整流程：
+
+. 检查双方签名
+. 修改到期高度与赎回额度
+
+*/
+
+// The mortgagor repays part of the loan and releases part of the collateral
+type Action_37_UsersLendingPartialRepay struct {
+	//
+	LendingID   fields.UserLendingId // Loan contract ID
+	RepayAmount fields.Amount        // Repay to the lender, deducted from the agreed redemption amount
+
+	ReleaseBitcoin     fields.SatoshiVariation     // Release bitcoin unit: SAT
+	ReleaseDiamondList fields.DiamondListMaxLen200 // Release diamond list
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_37_UsersLendingPartialRepay) Kind() uint16 {
+	return 37
+}
+
+// json api
+func (elm *Action_37_UsersLendingPartialRepay) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_37_UsersLendingPartialRepay) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.LendingID.Serialize()
+	var b2, _ = elm.RepayAmount.Serialize()
+	var b3, _ = elm.ReleaseBitcoin.Serialize()
+	var b4, _ = elm.ReleaseDiamondList.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_37_UsersLendingPartialRepay) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LendingID.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RepayAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReleaseBitcoin.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReleaseDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_37_UsersLendingPartialRepay) Size() uint32 {
+	return 2 + elm.LendingID.Size() +
+		elm.RepayAmount.Size() +
+		elm.ReleaseBitcoin.Size() +
+		elm.ReleaseDiamondList.Size()
+}
+
+func (*Action_37_UsersLendingPartialRepay) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // not sign
+}
+
+func (act *Action_37_UsersLendingPartialRepay) WriteInChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+	feeAddr := act.belong_trs_v3.GetAddress()
+
+	// Query the lending
+	usrlendObj, e := checkUserLendingForAdjust(state, act.LendingID)
+	if e != nil {
+		return e
+	}
+
+	// Only the mortgagor can repay
+	if feeAddr.NotEqual(usrlendObj.MortgagorAddress) {
+		return fmt.Errorf("only %s can do partial repay.", usrlendObj.MortgagorAddress.ToReadable())
+	}
+
+	// Same as Action_20_UsersLendingRansom: the mortgagor cannot redeem after the expiration if there is no agreement on automatic extension and public redemption
+	if paddingHeight > uint64(usrlendObj.ExpireBlockHeight) &&
+		usrlendObj.IsPublicRedeemable.Is(false) &&
+		usrlendObj.IsRedemptionOvertime.Is(false) {
+		return fmt.Errorf("only %s can do redeem after height %d.", usrlendObj.LenderAddress.ToReadable(), usrlendObj.ExpireBlockHeight)
+	}
+
+	// Check repay amount
+	if len(act.RepayAmount.Numeral) > 4 {
+		return fmt.Errorf("Amount <%s> byte length is too long.", act.RepayAmount.ToFinString())
+	}
+	if act.RepayAmount.IsPositive() == false {
+		return fmt.Errorf("Repay amount must be positive.")
+	}
+	if act.RepayAmount.LessThan(&usrlendObj.AgreedRedemptionAmount) == false {
+		return fmt.Errorf("Repay amount %s must less than %s, please use full redemption.", act.RepayAmount.ToFinString(), usrlendObj.AgreedRedemptionAmount.ToFinString())
+	}
+	newRedemptionAmt, e := usrlendObj.AgreedRedemptionAmount.Sub(&act.RepayAmount)
+	if e != nil {
+		return e
+	}
+	if len(newRedemptionAmt.Numeral) > 4 {
+		return fmt.Errorf("Amount <%s> byte length is too long.", newRedemptionAmt.ToFinString())
+	}
+
+	// Check release collateral
+	releaseDianum := int(act.ReleaseDiamondList.Count)
+	if releaseDianum != len(act.ReleaseDiamondList.Diamonds) {
+		return fmt.Errorf("Diamonds quantity error")
+	}
+	releaseSat := act.ReleaseBitcoin.GetRealSatoshi()
+	if releaseDianum == 0 && releaseSat == 0 {
+		return fmt.Errorf("Release diamond and bitcoin cannot be empty at the same time")
+	}
+	mortgageSat := usrlendObj.MortgageBitcoin.GetRealSatoshi()
+	if releaseSat > mortgageSat {
+		return fmt.Errorf("Release bitcoin %d cannot more than mortgage %d.", releaseSat, mortgageSat)
+	}
+	remainDiamonds := make([]fields.DiamondName, 0)
+	releaseMark := make(map[string]bool)
+	for _, v := range act.ReleaseDiamondList.Diamonds {
+		if releaseMark[string(v)] {
+			return fmt.Errorf("Diamond <%s> is repeated.", string(v))
+		}
+		releaseMark[string(v)] = true
+	}
+	for _, v := range usrlendObj.MortgageDiamondList.Diamonds {
+		if releaseMark[string(v)] {
+			delete(releaseMark, string(v))
+		} else {
+			remainDiamonds = append(remainDiamonds, v)
+		}
+	}
+	if len(releaseMark) > 0 {
+		return fmt.Errorf("Release diamonds not all belong to the lending.")
+	}
+	if len(remainDiamonds) == 0 && releaseSat == mortgageSat {
+		return fmt.Errorf("Cannot release all collateral, please use full redemption.")
+	}
+
+	// The proportion of release cannot more than the proportion of repay
+	// release / mortgage <= repay / redemption
+	repayValue := act.RepayAmount.GetValue()
+	redemptionValue := usrlendObj.AgreedRedemptionAmount.GetValue()
+	checkProportion := func(release, mortgage uint64) bool {
+		if release == 0 {
+			return true
+		}
+		left := new(big.Int).Mul(new(big.Int).SetUint64(release), redemptionValue)
+		right := new(big.Int).Mul(new(big.Int).SetUint64(mortgage), repayValue)
+		return left.Cmp(right) <= 0
+	}
+	if !checkProportion(uint64(releaseDianum), uint64(usrlendObj.MortgageDiamondList.Count)) {
+		return fmt.Errorf("Release diamonds proportion cannot more than repay proportion.")
+	}
+	if !checkProportion(uint64(releaseSat), uint64(mortgageSat)) {
+		return fmt.Errorf("Release bitcoin proportion cannot more than repay proportion.")
+	}
+
+	// Pay to the lender
+	e2 := DoSimpleTransferFromChainState(state, feeAddr, usrlendObj.LenderAddress, act.RepayAmount)
+	if e2 != nil {
+		return e2
+	}
+
+	// Release diamonds
+	for i := 0; i < len(act.ReleaseDiamondList.Diamonds); i++ {
+		diamond := act.ReleaseDiamondList.Diamonds[i]
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Address.NotEqual(usrlendObj.MortgagorAddress) {
+			return fmt.Errorf("diamond <%s> not belong to address %s", string(diamond), usrlendObj.MortgagorAddress.ToReadable())
+		}
+		if diaitem.Status != stores.DiamondStatusLendingOtherUser {
+			return fmt.Errorf("diamond <%s> status is not [stores.DiamondStatusLendingOtherUser].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal // Release diamond status
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	if releaseDianum > 0 {
+		e9 := DoAddDiamondFromChainStateV3(state, usrlendObj.MortgagorAddress, fields.DiamondNumber(releaseDianum))
+		if e9 != nil {
+			return e9
+		}
+	}
+
+	// Release bitcoin
+	if releaseSat > 0 {
+		e := DoAddSatoshiFromChainStateV3(state, usrlendObj.MortgagorAddress, releaseSat)
+		if e != nil {
+			return e
+		}
+	}
+
+	// Update the lending
+	newRepaidAmt, e := usrlendObj.RepaidAmount.Add(&act.RepayAmount)
+	if e != nil {
+		return e
+	}
+	usrlendObj.MortgageDiamondList = fields.DiamondListMaxLen200{
+		Count:    fields.VarUint1(len(remainDiamonds)),
+		Diamonds: remainDiamonds,
+	}
+	usrlendObj.MortgageBitcoin = fields.NewSatoshiVariation(uint64(mortgageSat - releaseSat))
+	usrlendObj.AgreedRedemptionAmount = *newRedemptionAmt
+	usrlendObj.ExtendDataVersion = stores.UserLendingExtendDataVersion1
+	usrlendObj.RepaidAmount = *newRepaidAmt
+	usrlendObj.LastAdjustBlockHeight = fields.BlockHeight(paddingHeight)
+	e10 := state.UserLendingUpdate(act.LendingID, usrlendObj)
+	if e10 != nil {
+		return e10
+	}
+
+	// System statistics
+	totalsupply, e20 := state.ReadTotalSupply()
+	if e20 != nil {
+		return e20
+	}
+	totalsupply.DoAdd(
+		stores.TotalSupplyStoreTypeOfUsersLendingCumulationRepayHacAmount,
+		act.RepayAmount.ToMei(),
+	)
+	totalsupply.DoAddUint(stores.TotalSupplyStoreTypeOfUsersLendingAdjustCount, 1)
+	e21 := state.UpdateSetTotalSupply(totalsupply)
+	if e21 != nil {
+		return e21
+	}
+
+	// complete
+	return nil
+}
+
+func (act *Action_37_UsersLendingPartialRepay) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_37_UsersLendingPartialRepay) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_37_UsersLendingPartialRepay) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_37_UsersLendingPartialRepay) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_37_UsersLendingPartialRepay) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Both parties agree to extend the expiration or change the redemption amount
+type Action_38_UsersLendingAdjust struct {
+	//
+	LendingID fields.UserLendingId // Loan contract ID
+
+	NewExpireBlockHeight      fields.BlockHeight // New agreed expiration block height
+	NewAgreedRedemptionAmount fields.Amount      // New agreed redemption amount
+
+	// data ptr
+	belong_trs    interfacev2.Transaction
+	belong_trs_v3 interfaces.Transaction
+}
+
+func (elm *Action_38_UsersLendingAdjust) Kind() uint16 {
+	return 38
+}
+
+// json api
+func (elm *Action_38_UsersLendingAdjust) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	return data
+}
+
+func (elm *Action_38_UsersLendingAdjust) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.LendingID.Serialize()
+	var b2, _ = elm.NewExpireBlockHeight.Serialize()
+	var b3, _ = elm.NewAgreedRedemptionAmount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_38_UsersLendingAdjust) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LendingID.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.NewExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.NewAgreedRedemptionAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_38_UsersLendingAdjust) Size() uint32 {
+	return 2 + elm.LendingID.Size() +
+		elm.NewExpireBlockHeight.Size() +
+		elm.NewAgreedRedemptionAmount.Size()
+}
+
+func (*Action_38_UsersLendingAdjust) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // Check the signatures of both parties when execute
+}
+
+func (act *Action_38_UsersLendingAdjust) WriteInChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return fmt.Errorf("mainnet not yet") // Waiting for review is not enabled yet
+	}
+
+	if act.belong_trs_v3 == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	// Query the lending
+	usrlendObj, e := checkUserLendingForAdjust(state, act.LendingID)
+	if e != nil {
+		return e
+	}
+
+	// Check the signatures of both parties
+	signok, e1 := act.belong_trs_v3.VerifyTargetSigns([]fields.Address{usrlendObj.MortgagorAddress, usrlendObj.LenderAddress})
+	if e1 != nil {
+		return e1
+	}
+	if !signok {
+		return fmt.Errorf("User Lending <%s> address signature verify fail.", act.LendingID.ToHex())
+	}
+
+	// Check redemption amount
+	if len(act.NewAgreedRedemptionAmount.Numeral) > 4 {
+		return fmt.Errorf("Amount <%s> byte length is too long.", act.NewAgreedRedemptionAmount.ToFinString())
+	}
+	if act.NewAgreedRedemptionAmount.IsPositive() == false {
+		return fmt.Errorf("Amount cannot be empty.")
+	}
+
+	// Check redemption period height if changed, same as Action_19_UsersLendingCreate
+	if act.NewExpireBlockHeight != usrlendObj.ExpireBlockHeight {
+		effectiveExpireBlockHeight := paddingHeight + 288
+		if sys.TestDebugLocalDevelopmentMark {
+			effectiveExpireBlockHeight = paddingHeight + 10 // Test environment 10 blocks
+		}
+		if uint64(act.NewExpireBlockHeight) < effectiveExpireBlockHeight {
+			return fmt.Errorf("NewExpireBlockHeight %d is too short, must over than %d.", act.NewExpireBlockHeight, effectiveExpireBlockHeight)
+		}
+	}
+
+	if act.NewExpireBlockHeight == usrlendObj.ExpireBlockHeight &&
+		act.NewAgreedRedemptionAmount.Equal(&usrlendObj.AgreedRedemptionAmount) {
+		return fmt.Errorf("User Lending <%s> not changed.", act.LendingID.ToHex())
+	}
+
+	// Update the lending
+	usrlendObj.ExpireBlockHeight = act.NewExpireBlockHeight
+	usrlendObj.AgreedRedemptionAmount = act.NewAgreedRedemptionAmount
+	usrlendObj.ExtendDataVersion = stores.UserLendingExtendDataVersion1
+	usrlendObj.LastAdjustBlockHeight = fields.BlockHeight(paddingHeight)
+	e10 := state.UserLendingUpdate(act.LendingID, usrlendObj)
+	if e10 != nil {
+		return e10
+	}
+
+	// System statistics
+	totalsupply, e20 := state.ReadTotalSupply()
+	if e20 != nil {
+		return e20
+	}
+	totalsupply.DoAddUint(stores.TotalSupplyStoreTypeOfUsersLendingAdjustCount, 1)
+	e21 := state.UpdateSetTotalSupply(totalsupply)
+	if e21 != nil {
+		return e21
+	}
+
+	// complete
+	return nil
+}
+
+func (act *Action_38_UsersLendingAdjust) WriteinChainState(state interfacev2.ChainStateOperation) error {
+	panic("WriteinChainState be deprecated")
+}
+
+func (act *Action_38_UsersLendingAdjust) RecoverChainState(state interfacev2.ChainStateOperation) error {
+	panic("RecoverChainState be deprecated")
+}
+
+func (elm *Action_38_UsersLendingAdjust) SetBelongTransaction(t interfacev2.Transaction) {
+	elm.belong_trs = t
+}
+
+func (elm *Action_38_UsersLendingAdjust) SetBelongTrs(t interfaces.Transaction) {
+	elm.belong_trs_v3 = t
+}
+
+// burning fees  // Destruction of 90% of transaction fees
+func (act *Action_38_UsersLendingAdjust) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+
+// Check id format and redemption status
+func checkUserLendingForAdjust(state interfaces.ChainStateOperation, lendingId fields.UserLendingId) (*stores.UserLending, error) {
+	// Check ID format
+	if len(lendingId) != stores.UserLendingIdLength ||
+		lendingId[0] == 0 ||
+		lendingId[stores.UserLendingIdLength-1] == 0 {
+		return nil, fmt.Errorf("User Lending Id format error.")
+	}
+	// Query whether the ID exists
+	usrlendObj, e := state.UserLending(lendingId)
+	if e != nil {
+		return nil, e
+	}
+	if usrlendObj == nil {
+		return nil, fmt.Errorf("User Lending <%s> not exist.", lendingId.ToHex())
+	}
+	if usrlendObj.IsRansomed.Check() {
+		return nil, fmt.Errorf("User Lending <%s> has been redeemed.", lendingId.ToHex())
+	}
+	return usrlendObj, nil
+}
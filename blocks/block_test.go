@@ -2,8 +2,13 @@ package blocks
 
 import (
 	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/memstate"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
 	"github.com/hacash/core/transactions"
 	"testing"
 )
@@ -26,3 +31,93 @@ func Test1(t *testing.T) {
 	}
 
 }
+
+func Test2(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	miner := account.CreateAccountByPassword("miner")
+
+	state := memstate.NewMemoryChainState(10)
+	state.BalanceSet(acc1.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(10)))
+	state.BalanceSet(acc2.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(1)))
+
+	tx1 := transactions.CreateOneTxOfSimpleTransfer(acc1, acc2.Address, fields.NewAmountByUnitMei(6), fields.NewAmountByUnit(1, 248), 1)
+	tx2 := transactions.CreateOneTxOfSimpleTransfer(acc1, acc2.Address, fields.NewAmountByUnitMei(6), fields.NewAmountByUnit(5, 247), 2)
+	tx3 := transactions.CreateOneTxOfSimpleTransfer(acc2, acc1.Address, fields.NewAmountByUnit(1, 247), fields.NewAmountByUnit(1, 244), 3)
+	tx4 := transactions.CreateOneTxOfSimpleTransfer(acc2, acc1.Address, fields.NewAmountByUnit(2, 247), fields.NewAmountByUnit(1, 243), 4)
+
+	cnf := &BlockTemplateConfig{
+		MaxTxCount:    2,
+		RewardAddress: miner.Address,
+		Reward:        fields.NewAmountByUnitMei(1),
+	}
+	if _, e := CreateBlockTemplate(nil, state, nil, cnf); e == nil {
+		t.Fatal("difficulty check error")
+	}
+	cnf.Difficulty = 1000
+	tpl, e := CreateBlockTemplate(nil, state, []interfaces.Transaction{tx4, tx3, tx2, tx1}, cnf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// tx1 highest purity, tx2 not enough balance, tx3 included, tx4 over count limit
+	if len(tpl.Included) != 2 || tpl.Included[0] != tx1 || tpl.Included[1] != tx3 {
+		t.Fatal("included txs error")
+	}
+	if len(tpl.Invalid) != 1 || tpl.Invalid[0] != tx2 || len(tpl.BackToPool) != 1 || tpl.BackToPool[0] != tx4 {
+		t.Fatal("dropped txs error")
+	}
+	totalfee, _ := tx1.GetFee().Add(tx3.GetFee())
+	if !tpl.Coinbase.TotalFeeUserPayed.Equal(totalfee) || tpl.Block.GetTransactionCount() != 3 || tpl.Block.GetHeight() != 10 || tpl.Block.GetDifficulty() != 1000 {
+		t.Fatal("coinbase or block error")
+	}
+	if !tpl.Block.GetMrklRoot().Equal(CalculateMrklRoot(tpl.Block.GetTrsList())) {
+		t.Fatal("mrkl root error")
+	}
+	// Base state not changed
+	bls1, _ := state.Balance(acc1.Address)
+	if !bls1.Hacash.Equal(fields.NewAmountByUnitMei(10)) {
+		t.Fatal("base state changed")
+	}
+	// Block can be written in state
+	if e := tpl.Block.WriteInChainState(state.Fork()); e != nil {
+		t.Fatal(e)
+	}
+
+	// Diamond create txs are not at height multiple of 5, thrown back to pool
+	var diamondtx = func(name string, number uint32, feeunit int) interfaces.Transaction {
+		act := &actions.Action_4_DiamondCreate{Diamond: fields.DiamondName(name), Number: fields.DiamondNumber(number), Address: acc1.Address}
+		tx, e := transactions.CreateOneTxOfDiamondCreate(acc1, act, fields.NewAmountByUnit(1, feeunit), 5)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return tx
+	}
+	dtx1 := diamondtx("NHMYYM", 1, 248)
+	dtx2 := diamondtx("WTYUIA", 2, 247)
+	state11 := state.Fork()
+	state11.SetPendingBlockHeight(11)
+	tpl, e = CreateBlockTemplate(nil, state11, []interfaces.Transaction{dtx2, tx3, dtx1}, cnf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(tpl.Invalid) != 0 || len(tpl.BackToPool) != 2 || tpl.BackToPool[0] != dtx1 || tpl.BackToPool[1] != dtx2 {
+		t.Fatal("diamond {BACKTOPOOL} txs error", tpl.InvalidErrors)
+	}
+	if len(tpl.Included) != 1 || tpl.Included[0] != tx3 {
+		t.Fatal("included txs error")
+	}
+
+	// At most one diamond create in a block, the second goes back to pool
+	oldmark := sys.TestDebugLocalDevelopmentMark
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = oldmark }()
+	tpl, e = CreateBlockTemplate(nil, state11, []interfaces.Transaction{dtx2, dtx1}, cnf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(tpl.Included) != 1 || tpl.Included[0] != dtx1 || len(tpl.Invalid) != 0 || len(tpl.BackToPool) != 1 || tpl.BackToPool[0] != dtx2 {
+		t.Fatal("second diamond txs error", tpl.InvalidErrors)
+	}
+
+}
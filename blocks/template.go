@@ -0,0 +1,208 @@
+package blocks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/transactions"
+)
+
+/**
+ * Block template builder
+ * Select transactions by fee purity, simulate each of them on a forked state
+ * and assemble the next block with coinbase and merkle root
+ */
+
+const (
+	BlockTemplateDefaultMaxTxCount = 2000
+	BlockTemplateDefaultMaxTxSize  = 1024 * 1024 * 2 // 2MB
+)
+
+type BlockTemplateConfig struct {
+	MaxTxCount    uint32 // Max transactions count, exclude coinbase
+	MaxTxSize     uint32 // Max total size of transactions, exclude coinbase
+	RewardAddress fields.Address
+	Reward        *fields.Amount
+	Message       fields.TrimString16
+	MinerNonce    fields.Bytes32 // Optional, 32 bytes
+	// Required, difficulty of the new block
+	// The template does not retarget, the caller calculates it by the chain
+	Difficulty uint32
+}
+
+type BlockTemplate struct {
+	Block    *Block_v1
+	Coinbase *transactions.Transaction_0_Coinbase
+	// Transactions included in the block, exclude coinbase
+	Included []interfaces.Transaction
+	// Valid but not included this time: over limit, second diamond or {BACKTOPOOL}
+	BackToPool []interfaces.Transaction
+	// Failed to execute, should be removed from tx pool
+	Invalid []interfaces.Transaction
+	// Failed reason of invalid transactions, key is tx hash hex
+	InvalidErrors map[string]error
+	TotalTxSize   uint32
+}
+
+// State which can fork a sub state for simulation, such as memstate.MemoryChainState
+type ForkableChainState interface {
+	ForkSubState() interfaces.ChainStateOperation
+}
+
+// Fork a state for simulation
+// support interfaces.ChainState and ForkableChainState
+func ForkStateForSimulation(state interfaces.ChainStateOperation) (interfaces.ChainStateOperation, error) {
+	switch s := state.(type) {
+	case interfaces.ChainState:
+		return s.ForkSubChild()
+	case ForkableChainState:
+		return s.ForkSubState(), nil
+	}
+	return nil, fmt.Errorf("state cannot be forked for simulation")
+}
+
+// Create the next block template
+// The state must be set pending block height as the new block height
+// The state itself will not be changed
+func CreateBlockTemplate(prevhead interfaces.BlockHeadMetaRead, state interfaces.ChainStateOperation, txs []interfaces.Transaction, cnf *BlockTemplateConfig) (*BlockTemplate, error) {
+	if cnf == nil || len(cnf.RewardAddress) != fields.AddressSize {
+		return nil, fmt.Errorf("reward address is required")
+	}
+	if cnf.Reward == nil {
+		return nil, fmt.Errorf("reward amount is required")
+	}
+	if cnf.Difficulty == 0 {
+		return nil, fmt.Errorf("difficulty is required")
+	}
+	maxcount := cnf.MaxTxCount
+	if maxcount == 0 {
+		maxcount = BlockTemplateDefaultMaxTxCount
+	}
+	maxsize := cnf.MaxTxSize
+	if maxsize == 0 {
+		maxsize = BlockTemplateDefaultMaxTxSize
+	}
+	blkhei := fields.BlockHeight(state.GetPendingBlockHeight())
+	if prevhead != nil && prevhead.GetHeight()+1 != uint64(blkhei) {
+		return nil, fmt.Errorf("state pending height %d not match prev block height %d", blkhei, prevhead.GetHeight())
+	}
+	// Fork base state
+	basestate, e := ForkStateForSimulation(state)
+	if e != nil {
+		return nil, e
+	}
+	if cs, ok := basestate.(interfaces.ChainState); ok {
+		defer cs.Destory() // Including all sub states
+	}
+	// Order by fee purity
+	candidates := make([]interfaces.Transaction, len(txs))
+	copy(candidates, txs)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].FeePurity() > candidates[j].FeePurity()
+	})
+	// Simulate
+	tpl := &BlockTemplate{
+		Included:      make([]interfaces.Transaction, 0),
+		BackToPool:    make([]interfaces.Transaction, 0),
+		Invalid:       make([]interfaces.Transaction, 0),
+		InvalidErrors: make(map[string]error),
+	}
+	var setInvalid = func(tx interfaces.Transaction, err error) {
+		tpl.Invalid = append(tpl.Invalid, tx)
+		tpl.InvalidErrors[tx.Hash().ToHex()] = err
+	}
+	totalfeeuserpay := fields.NewEmptyAmount()
+	totalfeeminergot := fields.NewEmptyAmount()
+	havediamond := false
+	curstate := basestate
+	txhashs := make(map[string]bool)
+	for _, tx := range candidates {
+		if uint32(len(tpl.Included)) >= maxcount {
+			tpl.BackToPool = append(tpl.BackToPool, tx)
+			continue
+		}
+		txsize := tx.Size()
+		if tpl.TotalTxSize+txsize > maxsize {
+			tpl.BackToPool = append(tpl.BackToPool, tx)
+			continue // Try smaller one
+		}
+		// One diamond per block
+		isdiamond := transactions.CheckoutAction_4_DiamondCreateFromTx(tx) != nil
+		if isdiamond && havediamond {
+			tpl.BackToPool = append(tpl.BackToPool, tx)
+			continue
+		}
+		// Duplicate check
+		txhx := tx.Hash()
+		if txhashs[string(txhx)] {
+			continue
+		}
+		ishav, e := curstate.CheckTxHash(txhx)
+		if e != nil {
+			return nil, e
+		}
+		if ishav {
+			setInvalid(tx, fmt.Errorf("tx <%s> is exist", txhx.ToHex()))
+			continue
+		}
+		// Execute on sub state
+		substate, e := ForkStateForSimulation(curstate)
+		if e != nil {
+			return nil, e
+		}
+		e = substate.ContainTxHash(txhx, blkhei)
+		if e == nil {
+			e = tx.WriteInChainState(substate)
+		}
+		if e != nil {
+			if cs, ok := substate.(interfaces.ChainState); ok {
+				cs.Destory()
+			}
+			if strings.HasPrefix(e.Error(), "{BACKTOPOOL}") {
+				tpl.BackToPool = append(tpl.BackToPool, tx)
+			} else {
+				setInvalid(tx, e)
+			}
+			continue
+		}
+		// ok
+		totalfeeuserpay, e = totalfeeuserpay.Add(tx.GetFee())
+		if e != nil {
+			return nil, e
+		}
+		totalfeeminergot, e = totalfeeminergot.Add(tx.GetFeeOfMinerRealReceived())
+		if e != nil {
+			return nil, e
+		}
+		curstate = substate
+		txhashs[string(txhx)] = true
+		havediamond = havediamond || isdiamond
+		tpl.TotalTxSize += txsize
+		tpl.Included = append(tpl.Included, tx)
+	}
+	// Coinbase
+	coinbase := transactions.NewTransaction_0_CoinbaseV1()
+	coinbase.Address = cnf.RewardAddress
+	coinbase.Reward = *cnf.Reward
+	coinbase.Message = cnf.Message
+	if len(cnf.MinerNonce) == 32 {
+		coinbase.MinerNonce = cnf.MinerNonce
+	}
+	coinbase.TotalFeeUserPayed = *totalfeeuserpay
+	coinbase.TotalFeeMinerReceived = *totalfeeminergot
+	// Block
+	block := NewEmptyBlockVersion1(prevhead)
+	block.Height = blkhei
+	block.Difficulty = fields.VarUint4(cnf.Difficulty)
+	block.AddTrs(coinbase)
+	for _, tx := range tpl.Included {
+		block.AddTrs(tx)
+	}
+	block.SetMrklRoot(CalculateMrklRoot(block.GetTrsList()))
+	tpl.Block = block
+	tpl.Coinbase = coinbase
+	return tpl, nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/fields"
 	"testing"
 )
 
@@ -22,3 +24,266 @@ func Test1(t *testing.T) {
 	fmt.Println(hex.EncodeToString(bts2))
 
 }
+
+func Test_protocol(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	bill := &OffChainFormPaymentChannelRealtimeReconciliation{
+		ChannelId:      bytes.Repeat([]byte{1}, 16),
+		ReuseVersion:   1,
+		BillAutoNumber: 2,
+		LeftBalance:    *fields.NewAmountByUnit(10, 248),
+		RightBalance:   *fields.NewAmountByUnit(5, 248),
+		LeftSatoshi:    fields.NewEmptySatoshiVariation(),
+		RightSatoshi:   fields.NewEmptySatoshiVariation(),
+		LeftAddress:    acc1.Address,
+		RightAddress:   acc2.Address,
+		Timestamp:      1,
+		LeftSign:       fields.CreateEmptySign(),
+		RightSign:      fields.CreateEmptySign(),
+	}
+	bill.FillTargetSignature(acc1)
+
+	tp1, tp2 := NewLoopbackTransportPair(4)
+	defer tp1.Close()
+	ss1, ss2 := NewProtocolSession(), NewProtocolSession()
+
+	// request reconciliation
+	req := &ProtocolMsgRequestReconciliation{Bill: *bill}
+	if e := ss1.Accept(req); e != nil {
+		t.Fatal(e)
+	}
+	tp1.Send(req)
+	msg, e := tp2.Receive()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := ss2.Accept(msg); e != nil {
+		t.Fatal(e)
+	}
+	// return signature
+	rcvbill := msg.(*ProtocolMsgRequestReconciliation).Bill
+	sign, _, _ := rcvbill.FillTargetSignature(acc2)
+	ret := &ProtocolMsgReturnSignature{SignStuffHash: rcvbill.SignStuffHash(), Sign: *sign}
+	// signature of other account not allowed
+	acc3 := account.CreateAccountByPassword("abcdef")
+	sigdata3, _ := acc3.Private.Sign(rcvbill.SignStuffHash())
+	sign3 := fields.Sign{PublicKey: acc3.PublicKey, Signature: sigdata3.Serialize64()}
+	if e := ss2.Accept(&ProtocolMsgReturnSignature{SignStuffHash: rcvbill.SignStuffHash(), Sign: sign3}); e == nil {
+		t.Fatal("return signature of other address must be refused")
+	}
+	if e := ss2.Accept(ret); e != nil {
+		t.Fatal(e)
+	}
+	tp2.Send(ret)
+	msg, _ = tp1.Receive()
+	if e := ss1.Accept(msg); e != nil {
+		t.Fatal(e)
+	}
+	if ss1.State() != ProtocolStateIdle || ss2.State() != ProtocolStateIdle {
+		t.Fatal("session state must be idle")
+	}
+	if e := rcvbill.VerifySignature(); e != nil {
+		t.Fatal(e)
+	}
+	// wrong order
+	if e := ss1.Accept(ret); e == nil {
+		t.Fatal("return signature not allowed in idle")
+	}
+	// reject
+	ss1.Accept(req)
+	rej := &ProtocolMsgReject{ErrCode: 1, Reason: fields.CreateStringMax255("balance not match")}
+	tp2.Send(rej)
+	msg, _ = tp1.Receive()
+	if e := ss1.Accept(msg); e != nil || ss1.State() != ProtocolStateIdle {
+		t.Fatal("reject must reset session")
+	}
+	if rcvrej := msg.(*ProtocolMsgReject); rcvrej.ErrCode != 1 || rcvrej.Reason.Value() != "balance not match" {
+		t.Fatal("reject reason error")
+	}
+}
+
+// Payment documents of one channel, the payer acc1 signed
+func createTestPayDocuments(cid fields.ChannelId, acc1, acc2 *account.Account, autonum uint64, leftamt, rightamt int64) *ChannelPayCompleteDocuments {
+	body := CreateEmptyProveBody(cid)
+	body.BillAutoNumber = fields.VarUint8(autonum)
+	body.PayAmount = *fields.NewAmountByUnit(1, 248)
+	body.LeftBalance = *fields.NewAmountByUnit(leftamt, 248)
+	body.RightBalance = *fields.NewAmountByUnit(rightamt, 248)
+	body.LeftAddress = acc1.Address
+	body.RightAddress = acc2.Address
+	payment := &OffChainFormPaymentChannelTransfer{
+		Timestamp:                            fields.BlockTxTimestamp(autonum),
+		OrderNoteHashHalfChecker:             make([]byte, fields.HashHalfCheckerSize),
+		MustSignCount:                        2,
+		MustSignAddresses:                    []fields.Address{acc1.Address, acc2.Address},
+		ChannelCount:                         1,
+		ChannelTransferProveHashHalfCheckers: []fields.HashHalfChecker{body.GetSignStuffHashHalfChecker()},
+		MustSigns:                            []fields.Sign{fields.CreateEmptySign(), fields.CreateEmptySign()},
+	}
+	payment.DoSignFillPosition(acc1)
+	return &ChannelPayCompleteDocuments{
+		ProveBodys:   &ChannelPayProveBodyList{Count: 1, ProveBodys: []*ChannelChainTransferProveBodyInfo{body}},
+		ChainPayment: payment,
+	}
+}
+
+func Test_protocol_payment(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	cid := fields.ChannelId(bytes.Repeat([]byte{1}, 16))
+
+	tp1, tp2 := NewLoopbackTransportPair(4)
+	defer tp1.Close()
+	payer, payee := NewProtocolSession(), NewProtocolSession()
+
+	// propose payment
+	docs := createTestPayDocuments(cid, acc1, acc2, 3, 9, 6)
+	propose := &ProtocolMsgProposePayment{Documents: *docs}
+	if e := payer.Accept(propose); e != nil {
+		t.Fatal(e)
+	}
+	tp1.Send(propose)
+	msg, e := tp2.Receive()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := payee.Accept(msg); e != nil || payee.State() != ProtocolStateWaitPaymentSignature {
+		t.Fatal("propose payment must wait signature")
+	}
+	// wrong order
+	exchange := &ProtocolMsgExchangeDocuments{Documents: *docs}
+	if e := payee.Accept(exchange); e == nil {
+		t.Fatal("exchange documents not allowed before signature")
+	}
+	if e := payee.Accept(propose); e == nil {
+		t.Fatal("propose payment not allowed while waiting signature")
+	}
+
+	// return signature
+	rcvdocs := msg.(*ProtocolMsgProposePayment).Documents
+	sign, e := rcvdocs.ChainPayment.DoSignFillPosition(acc2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ret := &ProtocolMsgReturnSignature{SignStuffHash: rcvdocs.ChainPayment.GetSignStuffHash(), Sign: *sign}
+	if e := payee.Accept(ret); e != nil {
+		t.Fatal(e)
+	}
+	tp2.Send(ret)
+	msg, _ = tp1.Receive()
+	if e := payer.Accept(msg); e != nil || payer.State() != ProtocolStateWaitDocuments {
+		t.Fatal("return signature must wait documents")
+	}
+	if e := payer.Accept(ret); e == nil {
+		t.Fatal("return signature not allowed while waiting documents")
+	}
+
+	// documents not match the proposal
+	other := createTestPayDocuments(cid, acc1, acc2, 4, 8, 7)
+	other.ChainPayment.DoSignFillPosition(acc2)
+	if e := payer.Accept(&ProtocolMsgExchangeDocuments{Documents: *other}); e == nil {
+		t.Fatal("documents not match the proposal must be refused")
+	}
+	// documents without all signatures
+	if e := payer.Accept(exchange); e == nil {
+		t.Fatal("documents without all signatures must be refused")
+	}
+
+	// exchange complete documents
+	docs.ChainPayment.FillSignByPosition(*sign)
+	exchange = &ProtocolMsgExchangeDocuments{Documents: *docs}
+	if e := payer.Accept(exchange); e != nil {
+		t.Fatal(e)
+	}
+	tp1.Send(exchange)
+	msg, _ = tp2.Receive()
+	if e := payee.Accept(msg); e != nil {
+		t.Fatal(e)
+	}
+	if payer.State() != ProtocolStateIdle || payee.State() != ProtocolStateIdle {
+		t.Fatal("session state must be idle")
+	}
+	if e := msg.(*ProtocolMsgExchangeDocuments).Documents.ChainPayment.CheckMustAddressAndSigns(); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func Test_archive(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	cid := fields.ChannelId(bytes.Repeat([]byte{1}, 16))
+
+	archive := NewBillArchive(cid)
+	for i := 1; i <= 3; i++ {
+		bill := &OffChainFormPaymentChannelRealtimeReconciliation{
+			ChannelId:      cid,
+			ReuseVersion:   1,
+			BillAutoNumber: fields.VarUint8(i),
+			LeftBalance:    *fields.NewAmountByUnit(int64(10+i), 248),
+			RightBalance:   *fields.NewAmountByUnit(int64(10-i), 248),
+			LeftSatoshi:    fields.NewEmptySatoshiVariation(),
+			RightSatoshi:   fields.NewEmptySatoshiVariation(),
+			LeftAddress:    acc1.Address,
+			RightAddress:   acc2.Address,
+			Timestamp:      fields.BlockTxTimestamp(i),
+		}
+		// unsigned bill cannot be archived
+		if _, e := archive.AppendBill(bill); e == nil {
+			t.Fatal("unsigned bill must be rejected")
+		}
+		bill.FillTargetSignature(acc1)
+		bill.FillTargetSignature(acc2)
+		if _, e := archive.AppendBill(bill); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	ExportBillArchive(buf, archive)
+	data := buf.Bytes()
+
+	archive2, e := ImportBillArchive(bytes.NewReader(data))
+	if e != nil {
+		t.Fatal(e)
+	}
+	latest, _ := archive2.LatestBill()
+	if latest.GetAutoNumber() != 3 {
+		t.Fatal("latest bill error")
+	}
+	fmt.Println(len(data), latest.GetLeftBalance().ToFinString())
+
+	// tamper
+	data[len(data)-40] ^= 1
+	if _, e := ImportBillArchive(bytes.NewReader(data)); e == nil {
+		t.Fatal("tampered archive must fail")
+	}
+
+	// The newest state exists only as payment documents
+	docs := createTestPayDocuments(cid, acc1, acc2, 5, 9, 11)
+	docs.ChainPayment.DoSignFillPosition(acc2)
+	if _, e := archive2.AppendDocuments(docs); e != nil {
+		t.Fatal(e)
+	}
+	buf.Reset()
+	ExportBillArchive(buf, archive2)
+	archive3, e := ImportBillArchive(bytes.NewReader(buf.Bytes()))
+	if e != nil {
+		t.Fatal(e)
+	}
+	latest, e = archive3.LatestBill()
+	if e != nil {
+		t.Fatal(e)
+	}
+	lamt := latest.GetLeftBalance()
+	if latest.TypeCode() != BillTypeCodeSimplePay || latest.GetAutoNumber() != 5 || !lamt.Equal(fields.NewAmountByUnit(9, 248)) {
+		t.Fatal("latest bill from documents error")
+	}
+	if e := latest.VerifySignature(); e != nil {
+		t.Fatal(e)
+	}
+}
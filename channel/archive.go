@@ -0,0 +1,410 @@
+package channel
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+/**
+
+通道票据归档（导出 / 导入）
+
+*/
+
+const (
+	BillArchiveVersion uint8 = 1
+)
+
+var billArchiveMagic = []byte("HCBA")
+
+const (
+	BillArchiveEntryTypeBill      uint8 = 1 // ReconciliationBalanceBill with type code
+	BillArchiveEntryTypeDocuments uint8 = 2 // ChannelPayCompleteDocuments
+)
+
+// One entry of the append-only log
+type BillArchiveEntry struct {
+	EntryType fields.VarUint1
+	Timestamp fields.BlockTxTimestamp // Archive time
+	Data      fields.StringMax16777215
+	ChainHash fields.Hash // hash(prev ChainHash + entry body), the first prev is channel id
+}
+
+func (e *BillArchiveEntry) Size() uint32 {
+	return e.EntryType.Size() + e.Timestamp.Size() + e.Data.Size() + e.ChainHash.Size()
+}
+
+func (e *BillArchiveEntry) serializeBody() ([]byte, error) {
+	var buffer bytes.Buffer
+	var bt1, _ = e.EntryType.Serialize()
+	buffer.Write(bt1)
+	var bt2, _ = e.Timestamp.Serialize()
+	buffer.Write(bt2)
+	var bt3, e3 = e.Data.Serialize()
+	if e3 != nil {
+		return nil, e3
+	}
+	buffer.Write(bt3)
+	return buffer.Bytes(), nil
+}
+
+func (e *BillArchiveEntry) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	body, e1 := e.serializeBody()
+	if e1 != nil {
+		return nil, e1
+	}
+	buffer.Write(body)
+	var bt, _ = e.ChainHash.Serialize()
+	buffer.Write(bt)
+	return buffer.Bytes(), nil
+}
+
+func (e *BillArchiveEntry) Parse(buf []byte, seek uint32) (uint32, error) {
+	var err error
+	seek, err = e.EntryType.Parse(buf, seek)
+	if err != nil {
+		return 0, err
+	}
+	seek, err = e.Timestamp.Parse(buf, seek)
+	if err != nil {
+		return 0, err
+	}
+	seek, err = e.Data.Parse(buf, seek)
+	if err != nil {
+		return 0, err
+	}
+	seek, err = e.ChainHash.Parse(buf, seek)
+	if err != nil {
+		return 0, err
+	}
+	return seek, nil
+}
+
+// Calculate chain hash with the previous one
+func (e *BillArchiveEntry) calculateChainHash(prev []byte) (fields.Hash, error) {
+	body, err := e.serializeBody()
+	if err != nil {
+		return nil, err
+	}
+	stuff := bytes.NewBuffer(nil)
+	stuff.Write(prev)
+	stuff.Write(body)
+	return fields.CalculateHash(stuff.Bytes()), nil
+}
+
+/********************************************************/
+
+// All signed bills and prove bodies of one channel
+type BillArchive struct {
+	ChannelId fields.ChannelId
+	Entries   []*BillArchiveEntry
+}
+
+func NewBillArchive(cid fields.ChannelId) *BillArchive {
+	return &BillArchive{
+		ChannelId: cid,
+		Entries:   make([]*BillArchiveEntry, 0),
+	}
+}
+
+// Append a bill, the bill must be valid and signed
+func (a *BillArchive) AppendBill(bill ReconciliationBalanceBill) (*BillArchiveEntry, error) {
+	e := a.checkBill(bill)
+	if e != nil {
+		return nil, e
+	}
+	data, e := bill.SerializeWithTypeCode()
+	if e != nil {
+		return nil, e
+	}
+	return a.appendEntry(BillArchiveEntryTypeBill, data)
+}
+
+// Append complete documents, all signatures must be correct
+func (a *BillArchive) AppendDocuments(docs *ChannelPayCompleteDocuments) (*BillArchiveEntry, error) {
+	e := a.checkDocuments(docs)
+	if e != nil {
+		return nil, e
+	}
+	data, e := docs.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	return a.appendEntry(BillArchiveEntryTypeDocuments, data)
+}
+
+func (a *BillArchive) appendEntry(ty uint8, data []byte) (*BillArchiveEntry, error) {
+	entry := &BillArchiveEntry{
+		EntryType: fields.VarUint1(ty),
+		Timestamp: fields.BlockTxTimestamp(time.Now().Unix()),
+		Data:      fields.CreateStringMax16777215(string(data)),
+	}
+	hx, e := entry.calculateChainHash(a.lastChainHash())
+	if e != nil {
+		return nil, e
+	}
+	entry.ChainHash = hx
+	a.Entries = append(a.Entries, entry)
+	return entry, nil
+}
+
+func (a *BillArchive) lastChainHash() []byte {
+	if len(a.Entries) == 0 {
+		return a.ChannelId
+	}
+	return a.Entries[len(a.Entries)-1].ChainHash
+}
+
+func (a *BillArchive) checkBill(bill ReconciliationBalanceBill) error {
+	if bill == nil {
+		return fmt.Errorf("Bill not be nil.")
+	}
+	if a.ChannelId.Equal(bill.GetChannelId()) == false {
+		return fmt.Errorf("Bill channel id <%s> not match archive <%s>.",
+			bill.GetChannelId().ToHex(), a.ChannelId.ToHex())
+	}
+	e := bill.CheckValidity()
+	if e != nil {
+		return e
+	}
+	return bill.VerifySignature()
+}
+
+func (a *BillArchive) checkDocuments(docs *ChannelPayCompleteDocuments) error {
+	if docs == nil || docs.ProveBodys == nil || docs.ChainPayment == nil {
+		return fmt.Errorf("Documents not be nil.")
+	}
+	body, e := a.documentsProveBody(docs)
+	if e != nil {
+		return e
+	}
+	hxhalf := body.GetSignStuffHashHalfChecker()
+	var isHashCheckOk = false
+	for _, hxckr := range docs.ChainPayment.ChannelTransferProveHashHalfCheckers {
+		if hxhalf.Equal(hxckr) {
+			isHashCheckOk = true
+			break
+		}
+	}
+	if !isHashCheckOk {
+		return fmt.Errorf("Prove body hash <%s> not find.", hxhalf.ToHex())
+	}
+	e = docs.ChainPayment.CheckValidity()
+	if e != nil {
+		return e
+	}
+	return docs.ChainPayment.VerifySignature()
+}
+
+// Find the prove body of this channel
+func (a *BillArchive) documentsProveBody(docs *ChannelPayCompleteDocuments) (*ChannelChainTransferProveBodyInfo, error) {
+	for _, v := range docs.ProveBodys.ProveBodys {
+		if a.ChannelId.Equal(v.ChannelId) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("Prove body of channel <%s> not find.", a.ChannelId.ToHex())
+}
+
+// The bill of this channel derived from the payment documents
+func (a *BillArchive) documentsBill(docs *ChannelPayCompleteDocuments) (*OffChainCrossNodeSimplePaymentReconciliationBill, error) {
+	if docs.ProveBodys == nil || docs.ChainPayment == nil {
+		return nil, fmt.Errorf("Documents not be nil.")
+	}
+	body, e := a.documentsProveBody(docs)
+	if e != nil {
+		return nil, e
+	}
+	return &OffChainCrossNodeSimplePaymentReconciliationBill{
+		ChannelChainTransferTargetProveBody: *body,
+		ChannelChainTransferData:            *docs.ChainPayment,
+	}, nil
+}
+
+// Re-verify the chain hashes and all signatures
+func (a *BillArchive) Verify() error {
+	var prev []byte = a.ChannelId
+	for i, entry := range a.Entries {
+		hx, e := entry.calculateChainHash(prev)
+		if e != nil {
+			return e
+		}
+		if hx.Equal(entry.ChainHash) == false {
+			return fmt.Errorf("Archive entry %d chain hash error.", i)
+		}
+		prev = entry.ChainHash
+		data := []byte(entry.Data.Value())
+		switch uint8(entry.EntryType) {
+		case BillArchiveEntryTypeBill:
+			bill, _, e := ParseReconciliationBalanceBillByPrefixTypeCode(data, 0)
+			if e != nil {
+				return e
+			}
+			e = a.checkBill(bill)
+			if e != nil {
+				return fmt.Errorf("Archive entry %d: %s", i, e.Error())
+			}
+		case BillArchiveEntryTypeDocuments:
+			docs := &ChannelPayCompleteDocuments{}
+			_, e := docs.Parse(data, 0)
+			if e != nil {
+				return e
+			}
+			e = a.checkDocuments(docs)
+			if e != nil {
+				return fmt.Errorf("Archive entry %d: %s", i, e.Error())
+			}
+		default:
+			return fmt.Errorf("Archive entry %d type <%d> not support.", i, entry.EntryType)
+		}
+	}
+	return nil
+}
+
+// The bill with max reuse version and auto number, used to defend a challenge
+// Payment documents are included as the simple pay bill of this channel
+func (a *BillArchive) LatestBill() (ReconciliationBalanceBill, error) {
+	var latest ReconciliationBalanceBill = nil
+	for _, entry := range a.Entries {
+		var bill ReconciliationBalanceBill = nil
+		data := []byte(entry.Data.Value())
+		switch uint8(entry.EntryType) {
+		case BillArchiveEntryTypeBill:
+			b, _, e := ParseReconciliationBalanceBillByPrefixTypeCode(data, 0)
+			if e != nil {
+				return nil, e
+			}
+			bill = b
+		case BillArchiveEntryTypeDocuments:
+			docs := &ChannelPayCompleteDocuments{}
+			_, e := docs.Parse(data, 0)
+			if e != nil {
+				return nil, e
+			}
+			b, e := a.documentsBill(docs)
+			if e != nil {
+				return nil, e
+			}
+			bill = b
+		default:
+			continue
+		}
+		if latest == nil {
+			latest = bill
+			continue
+		}
+		rv1, an1 := latest.GetReuseVersionAndAutoNumber()
+		rv2, an2 := bill.GetReuseVersionAndAutoNumber()
+		if rv2 > rv1 || (rv2 == rv1 && an2 > an1) {
+			latest = bill
+		}
+	}
+	return latest, nil
+}
+
+func (a *BillArchive) Size() uint32 {
+	size := uint32(len(billArchiveMagic)) + 1 + a.ChannelId.Size()
+	for _, v := range a.Entries {
+		size += v.Size()
+	}
+	return size
+}
+
+func (a *BillArchive) SerializeHead() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.Write(billArchiveMagic)
+	buffer.WriteByte(BillArchiveVersion)
+	var bt, _ = a.ChannelId.Serialize()
+	buffer.Write(bt)
+	return buffer.Bytes(), nil
+}
+
+func (a *BillArchive) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	head, _ := a.SerializeHead()
+	buffer.Write(head)
+	for _, v := range a.Entries {
+		bt, e := v.Serialize()
+		if e != nil {
+			return nil, e
+		}
+		buffer.Write(bt)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Parse all entries to the end of buf
+func (a *BillArchive) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	mlen := uint32(len(billArchiveMagic))
+	if uint32(len(buf)) < seek+mlen+1 {
+		return 0, fmt.Errorf("Bill archive buf too short.")
+	}
+	if bytes.Compare(buf[seek:seek+mlen], billArchiveMagic) != 0 {
+		return 0, fmt.Errorf("Bill archive magic error.")
+	}
+	seek += mlen
+	if buf[seek] != BillArchiveVersion {
+		return 0, fmt.Errorf("Unsupported bill archive version <%d>", buf[seek])
+	}
+	seek += 1
+	seek, e = a.ChannelId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	a.Entries = make([]*BillArchiveEntry, 0)
+	for seek < uint32(len(buf)) {
+		entry := &BillArchiveEntry{}
+		seek, e = entry.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+		a.Entries = append(a.Entries, entry)
+	}
+	return seek, nil
+}
+
+/********************************************************/
+
+// Export the whole archive
+func ExportBillArchive(w io.Writer, archive *BillArchive) error {
+	data, e := archive.Serialize()
+	if e != nil {
+		return e
+	}
+	_, e = w.Write(data)
+	return e
+}
+
+// Append one entry to an exported archive
+func AppendBillArchiveEntry(w io.Writer, entry *BillArchiveEntry) error {
+	data, e := entry.Serialize()
+	if e != nil {
+		return e
+	}
+	_, e = w.Write(data)
+	return e
+}
+
+// Import and re-verify all entries
+func ImportBillArchive(r io.Reader) (*BillArchive, error) {
+	data, e := ioutil.ReadAll(r)
+	if e != nil {
+		return nil, e
+	}
+	archive := &BillArchive{}
+	_, e = archive.Parse(data, 0)
+	if e != nil {
+		return nil, e
+	}
+	e = archive.Verify()
+	if e != nil {
+		return nil, e
+	}
+	return archive, nil
+}
@@ -0,0 +1,182 @@
+package dispute
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/sys"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	lacc := account.CreateAccountByPassword("left")
+	racc := account.CreateAccountByPassword("right")
+	cid := fields.ChannelId(bytes.Repeat([]byte{7}, 16))
+
+	sim := NewSimulator(cid)
+	sim.Deposit(lacc.Address, fields.NewAmountByUnit(100, 248), 0)
+	sim.Deposit(racc.Address, fields.NewAmountByUnit(100, 248), 0)
+
+	open := &actions.Action_2_OpenPaymentChannel{
+		ChannelId:    cid,
+		LeftAddress:  lacc.Address,
+		LeftAmount:   *fields.NewAmountByUnit(10, 248),
+		RightAddress: racc.Address,
+		RightAmount:  *fields.NewAmountByUnit(10, 248),
+	}
+	if res := sim.Apply(300000, open); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+
+	bill1, _ := CreateSignedReconciliation(cid, 1, 1, fields.NewAmountByUnit(15, 248), fields.NewAmountByUnit(5, 248), 0, 0, lacc, racc)
+	bill2, _ := CreateSignedReconciliation(cid, 1, 2, fields.NewAmountByUnit(8, 248), fields.NewAmountByUnit(12, 248), 0, 0, lacc, racc)
+	billv2, _ := CreateSignedReconciliation(cid, 2, 3, fields.NewAmountByUnit(8, 248), fields.NewAmountByUnit(12, 248), 0, 0, lacc, racc)
+
+	// left launch challenge with old bill
+	res := sim.Apply(300010, CreateActionOfReconciliation(lacc.Address, bill1))
+	if res.Rule != RuleChallengeLaunched {
+		t.Fatal(res.Rule, res.Error)
+	}
+	// stale bill and wrong reuse version
+	if res := sim.Apply(300020, CreateActionOfReconciliation(racc.Address, bill1)); res.Rule != RuleRejected {
+		t.Fatal("stale bill must be rejected")
+	}
+	if res := sim.Apply(300020, CreateActionOfReconciliation(racc.Address, billv2)); res.Rule != RuleRejected {
+		t.Fatal("wrong reuse version must be rejected")
+	}
+	// lock period not expire
+	if res := sim.Apply(300030, &actions.Action_27_ClosePaymentChannelByClaimDistribution{ChannelId: cid}); res.Rule != RuleRejected {
+		t.Fatal("lock period not expire")
+	}
+	// right respond with newer bill
+	res = sim.Apply(300040, CreateActionOfReconciliation(racc.Address, bill2))
+	if res.Rule != RuleChallengeSeizeAll {
+		t.Fatal(res.Rule, res.Error)
+	}
+
+	report, e := sim.Report()
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, st := range report.Steps {
+		fmt.Println(st.Height, st.Kind, st.Rule, st.Error)
+	}
+	fmt.Println(report.DecidingRule, report.LeftBalance.Hacash.ToFinString(), report.RightBalance.Hacash.ToFinString())
+}
+
+func Test2(t *testing.T) {
+
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	lacc := account.CreateAccountByPassword("left")
+	racc := account.CreateAccountByPassword("right")
+	cid := fields.ChannelId(bytes.Repeat([]byte{8}, 16))
+
+	sim := NewSimulator(cid)
+	sim.Deposit(lacc.Address, fields.NewAmountByUnit(100, 248), 0)
+	sim.Deposit(racc.Address, fields.NewAmountByUnit(100, 248), 0)
+
+	open := &actions.Action_2_OpenPaymentChannel{
+		ChannelId:    cid,
+		LeftAddress:  lacc.Address,
+		LeftAmount:   *fields.NewAmountByUnit(10, 248),
+		RightAddress: racc.Address,
+		RightAmount:  *fields.NewAmountByUnit(10, 248),
+	}
+	if res := sim.Apply(300000, open); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	bill1, _ := CreateSignedReconciliation(cid, 1, 1, fields.NewAmountByUnit(15, 248), fields.NewAmountByUnit(5, 248), 0, 0, lacc, racc)
+
+	splice := &actions.Action_35_PaymentChannelSpliceIn{
+		ChannelId:          cid,
+		LeftAmount:         *fields.NewAmountByUnit(12, 248),
+		LeftSatoshi:        fields.NewEmptySatoshiVariation(),
+		RightAmount:        *fields.NewAmountByUnit(8, 248),
+		RightSatoshi:       fields.NewEmptySatoshiVariation(),
+		LeftSpliceAmount:   *fields.NewEmptyAmount(),
+		LeftSpliceSatoshi:  fields.NewEmptySatoshiVariation(),
+		RightSpliceAmount:  *fields.NewAmountByUnit(20, 248),
+		RightSpliceSatoshi: fields.NewEmptySatoshiVariation(),
+	}
+	// without signature
+	if res := sim.Apply(300100, splice); res.Rule != RuleRejected {
+		t.Fatal("splice must be signed by both")
+	}
+	res := sim.Apply(300100, splice, lacc, racc)
+	if res.Rule != RuleSpliced {
+		t.Fatal(res.Rule, res.Error)
+	}
+	if res.Channel.ReuseVersion != 2 || res.Channel.RightAmount.ToMei() != 28 {
+		t.Fatal("splice result error")
+	}
+	// older bill is invalid
+	if res := sim.Apply(300200, CreateActionOfReconciliation(lacc.Address, bill1)); res.Rule != RuleRejected {
+		t.Fatal("older bill must be rejected")
+	}
+}
+
+func Test3(t *testing.T) {
+
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	lacc := account.CreateAccountByPassword("left")
+	racc := account.CreateAccountByPassword("right")
+	cid := fields.ChannelId(bytes.Repeat([]byte{9}, 16))
+
+	sim := NewSimulator(cid)
+	sim.Deposit(lacc.Address, fields.NewAmountByUnit(100, 248), 0)
+	sim.Deposit(racc.Address, fields.NewAmountByUnit(100, 248), 0)
+
+	open := &actions.Action_2_OpenPaymentChannel{
+		ChannelId:    cid,
+		LeftAddress:  lacc.Address,
+		LeftAmount:   *fields.NewAmountByUnit(10, 248),
+		RightAddress: racc.Address,
+		RightAmount:  *fields.NewAmountByUnit(10, 248),
+	}
+	if res := sim.Apply(300000, open); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	// Splice after one and a half interest periods
+	paychan, _ := sim.State().Channel(cid)
+	settled, _ := actions.PreviewChannelSettlement(paychan, nil, nil, 0, 0, 315000)
+	splice := &actions.Action_35_PaymentChannelSpliceIn{
+		ChannelId:          cid,
+		LeftAmount:         *fields.NewAmountByUnit(10, 248),
+		LeftSatoshi:        fields.NewEmptySatoshiVariation(),
+		RightAmount:        *fields.NewAmountByUnit(10, 248),
+		RightSatoshi:       fields.NewEmptySatoshiVariation(),
+		LeftSpliceAmount:   *fields.NewEmptyAmount(),
+		LeftSpliceSatoshi:  fields.NewEmptySatoshiVariation(),
+		RightSpliceAmount:  *fields.NewAmountByUnit(10, 248),
+		RightSpliceSatoshi: fields.NewEmptySatoshiVariation(),
+	}
+	res := sim.Apply(315000, splice, lacc, racc)
+	if res.Rule != RuleSpliced {
+		t.Fatal(res.Rule, res.Error)
+	}
+	// One period settled, the half period carries over
+	rightamt, _ := settled.RightAmount.Add(fields.NewAmountByUnit(10, 248))
+	if !res.Channel.LeftAmount.Equal(settled.LeftAmount) || !res.Channel.RightAmount.Equal(rightamt) {
+		t.Fatal("splice interest settle error")
+	}
+	if res.Channel.BelongHeight != 310000 {
+		t.Fatal("splice belong height must keep the partial period", res.Channel.BelongHeight)
+	}
+	// The next period completes at the original schedule
+	preview, e := actions.PreviewChannelSettlement(res.Channel, nil, nil, 0, 0, 320000)
+	if e != nil {
+		t.Fatal(e)
+	}
+	left, right, _ := coinbase.DoAppendCompoundInterestProportionOfHeightV2(&res.Channel.LeftAmount, &res.Channel.RightAmount, 1, 10, 0)
+	if !preview.HaveInterest || !preview.LeftAmount.Equal(left) || !preview.RightAmount.Equal(right) || !preview.RightInterest.IsPositive() {
+		t.Fatal("post splice interest error")
+	}
+}
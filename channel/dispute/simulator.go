@@ -0,0 +1,227 @@
+package dispute
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/channel"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/memstate"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+)
+
+/**
+
+通道仲裁规则模拟器
+
+*/
+
+// Rules that decide the channel status
+const (
+	RuleRejected               = "rejected"                 // Action execution failed, state not changed
+	RuleOpened                 = "opened"                   // Channel opened
+	RuleAgreementClosed        = "agreement closed"         // Both parties agree to close
+	RuleChallengeLaunched      = "challenge launched"       // Unilateral close, enter challenge period
+	RuleChallengeSeizeAll      = "challenge seize all"      // Respond with higher bill number, seize all funds
+	RuleChallengeExpireClaimed = "challenge expire claimed" // Challenge period expired, distribute as claimed
+	RuleSpliced                = "spliced"                  // Deposit or withdraw, older bills become invalid
+	RuleUnchanged              = "unchanged"                // Channel status not changed
+)
+
+// Result of one step
+type StepResult struct {
+	Height  uint64
+	Kind    uint16
+	Error   error
+	Rule    string
+	Channel *stores.Channel // Channel after this step
+}
+
+// Final report of the simulation
+type Report struct {
+	Channel      *stores.Channel
+	Steps        []*StepResult
+	DecidingRule string // The rule of the last successful step
+	// Balance of both parties after all steps
+	LeftBalance  *stores.Balance
+	RightBalance *stores.Balance
+}
+
+// Apply scripted actions to an in-memory state at chosen heights
+type Simulator struct {
+	state     *memstate.MemoryChainState
+	channelId fields.ChannelId
+	steps     []*StepResult
+}
+
+func NewSimulator(channelId fields.ChannelId) *Simulator {
+	return &Simulator{
+		state:     memstate.NewMemoryChainState(1),
+		channelId: channelId,
+		steps:     make([]*StepResult, 0),
+	}
+}
+
+func (s *Simulator) State() *memstate.MemoryChainState {
+	return s.state
+}
+
+// Set balance of address
+func (s *Simulator) Deposit(addr fields.Address, hac *fields.Amount, sat fields.Satoshi) error {
+	bls := stores.NewBalanceWithAmount(hac)
+	bls.Satoshi = sat
+	return s.state.BalanceSet(addr, bls)
+}
+
+// Execute an action at the target height, state will not change if failed
+// The belong transaction is signed by the signers if provided
+func (s *Simulator) Apply(height uint64, act interfaces.Action, signers ...*account.Account) *StepResult {
+	result := &StepResult{
+		Height: height,
+		Kind:   act.Kind(),
+	}
+	s.steps = append(s.steps, result)
+	before, _ := s.state.Channel(s.channelId)
+	// Execute on a fork
+	fork := s.state.Fork()
+	fork.SetPendingBlockHeight(height)
+	e := s.execute(fork, act, signers)
+	if e != nil {
+		result.Error = e
+		result.Rule = RuleRejected
+		result.Channel = before
+		return result
+	}
+	s.state = fork
+	after, _ := s.state.Channel(s.channelId)
+	result.Channel = after
+	result.Rule = decideRule(before, after, act.Kind())
+	return result
+}
+
+func (s *Simulator) execute(state *memstate.MemoryChainState, act interfaces.Action, signers []*account.Account) (e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e = fmt.Errorf("Action execute panic: %v", r)
+		}
+	}()
+	var mainaddr fields.Address
+	if len(signers) > 0 {
+		mainaddr = signers[0].Address
+	} else if reqs := act.RequestSignAddresses(); len(reqs) > 0 {
+		mainaddr = reqs[0]
+	} else {
+		ch, _ := state.Channel(s.channelId)
+		if ch == nil {
+			return fmt.Errorf("Payment Channel not find.")
+		}
+		mainaddr = ch.LeftAddress
+	}
+	trs, e := transactions.NewEmptyTransaction_2_Simple(mainaddr)
+	if e != nil {
+		return e
+	}
+	e = trs.AddAction(act)
+	if e != nil {
+		return e
+	}
+	if len(signers) > 0 {
+		prikeys := make(map[string][]byte)
+		addrs := make([]fields.Address, 0, len(signers))
+		for _, acc := range signers {
+			prikeys[string(acc.Address)] = acc.PrivateKey
+			addrs = append(addrs, acc.Address)
+		}
+		e = trs.FillNeedSigns(prikeys, addrs)
+		if e != nil {
+			return e
+		}
+	}
+	act.SetBelongTrs(trs)
+	return act.WriteInChainState(state)
+}
+
+// Which rule decided the status change
+func decideRule(before, after *stores.Channel, kind uint16) string {
+	if after == nil {
+		return RuleUnchanged
+	}
+	if before == nil || (before.IsAgreementClosed() && after.IsOpening()) {
+		return RuleOpened
+	}
+	if before.Status == after.Status {
+		if before.ReuseVersion != after.ReuseVersion {
+			return RuleSpliced
+		}
+		return RuleUnchanged
+	}
+	if after.IsChallenging() {
+		return RuleChallengeLaunched
+	}
+	if after.IsAgreementClosed() {
+		return RuleAgreementClosed
+	}
+	if after.IsFinalDistributionClosed() {
+		if kind == 27 {
+			return RuleChallengeExpireClaimed
+		}
+		return RuleChallengeSeizeAll
+	}
+	return RuleUnchanged
+}
+
+// Final distribution and the deciding rule
+func (s *Simulator) Report() (*Report, error) {
+	ch, e := s.state.Channel(s.channelId)
+	if e != nil {
+		return nil, e
+	}
+	if ch == nil {
+		return nil, fmt.Errorf("Payment Channel not find.")
+	}
+	report := &Report{
+		Channel:      ch,
+		Steps:        s.steps,
+		DecidingRule: RuleUnchanged,
+	}
+	for _, st := range s.steps {
+		if st.Error == nil && st.Rule != RuleUnchanged {
+			report.DecidingRule = st.Rule
+		}
+	}
+	report.LeftBalance, _ = s.state.Balance(ch.LeftAddress)
+	report.RightBalance, _ = s.state.Balance(ch.RightAddress)
+	return report, nil
+}
+
+/*************** scripted bills ***************/
+
+// Create a reconciliation bill signed by both parties
+func CreateSignedReconciliation(channelId fields.ChannelId, reuseVersion uint32, billNumber uint64,
+	leftAmt, rightAmt *fields.Amount, leftSat, rightSat fields.Satoshi,
+	lacc, racc *account.Account) (*channel.OnChainArbitrationBasisReconciliation, error) {
+	bill := &channel.OnChainArbitrationBasisReconciliation{
+		ChannelId:      channelId,
+		ReuseVersion:   fields.VarUint4(reuseVersion),
+		BillAutoNumber: fields.VarUint8(billNumber),
+		LeftBalance:    *leftAmt,
+		RightBalance:   *rightAmt,
+		LeftSatoshi:    leftSat.GetSatoshiVariation(),
+		RightSatoshi:   rightSat.GetSatoshiVariation(),
+	}
+	e := bill.FillSigns(lacc, racc)
+	if e != nil {
+		return nil, e
+	}
+	return bill, nil
+}
+
+// Unilateral close or respond challenge with reconciliation bill (Action_23)
+func CreateActionOfReconciliation(assertAddress fields.Address, bill *channel.OnChainArbitrationBasisReconciliation) *actions.Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeReconciliation {
+	return &actions.Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeReconciliation{
+		AssertAddress:  assertAddress,
+		Reconciliation: *bill,
+	}
+}
@@ -0,0 +1,235 @@
+package channel
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/fields"
+)
+
+/**
+
+通道双方协商消息协议
+
+*/
+
+const (
+	ProtocolVersion uint8 = 1 // Current version of the message protocol
+)
+
+const (
+	ProtocolMsgTypeProposePayment        uint8 = 1 // Propose a payment
+	ProtocolMsgTypeReturnSignature       uint8 = 2 // Return the signature of payment or reconciliation
+	ProtocolMsgTypeReject                uint8 = 3 // Reject payment or reconciliation
+	ProtocolMsgTypeRequestReconciliation uint8 = 4 // Request reconciliation
+	ProtocolMsgTypeExchangeDocuments     uint8 = 5 // Exchange complete payment documents
+)
+
+// Channel negotiation message interface
+type ProtocolMessage interface {
+	Type() uint8
+	Size() uint32
+	Serialize() ([]byte, error)
+	Parse(buf []byte, seek uint32) (uint32, error)
+}
+
+// serialize with version and type prefix
+func SerializeProtocolMessage(msg ProtocolMessage) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte(ProtocolVersion)
+	buffer.WriteByte(msg.Type())
+	body, e := msg.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(body)
+	return buffer.Bytes(), nil
+}
+
+// Deserialization
+func ParseProtocolMessage(buf []byte, seek uint32) (ProtocolMessage, uint32, error) {
+	if uint32(len(buf)) < seek+2 {
+		return nil, 0, fmt.Errorf("Protocol message buf too short.")
+	}
+	ver := buf[seek]
+	if ver != ProtocolVersion {
+		return nil, 0, fmt.Errorf("Unsupported protocol version <%d>", ver)
+	}
+	ty := buf[seek+1]
+	var msg ProtocolMessage = nil
+	// type
+	switch ty {
+	case ProtocolMsgTypeProposePayment:
+		msg = &ProtocolMsgProposePayment{}
+	case ProtocolMsgTypeReturnSignature:
+		msg = &ProtocolMsgReturnSignature{}
+	case ProtocolMsgTypeReject:
+		msg = &ProtocolMsgReject{}
+	case ProtocolMsgTypeRequestReconciliation:
+		msg = &ProtocolMsgRequestReconciliation{}
+	case ProtocolMsgTypeExchangeDocuments:
+		msg = &ProtocolMsgExchangeDocuments{}
+	default:
+		return nil, 0, fmt.Errorf("Unsupported protocol message type <%d>", ty)
+	}
+	// analysis
+	var e error
+	seek, e = msg.Parse(buf, seek+2)
+	if e != nil {
+		return nil, 0, e
+	}
+	return msg, seek, nil
+}
+
+/********************************************************/
+
+// Propose a payment, carry the documents signed by the payer
+type ProtocolMsgProposePayment struct {
+	Documents ChannelPayCompleteDocuments
+}
+
+func (m *ProtocolMsgProposePayment) Type() uint8 {
+	return ProtocolMsgTypeProposePayment
+}
+
+func (m *ProtocolMsgProposePayment) Size() uint32 {
+	return m.Documents.Size()
+}
+
+func (m *ProtocolMsgProposePayment) Serialize() ([]byte, error) {
+	if m.Documents.ProveBodys == nil || m.Documents.ChainPayment == nil {
+		return nil, fmt.Errorf("Protocol message documents not be nil.")
+	}
+	return m.Documents.Serialize()
+}
+
+func (m *ProtocolMsgProposePayment) Parse(buf []byte, seek uint32) (uint32, error) {
+	return m.Documents.Parse(buf, seek)
+}
+
+/********************************************************/
+
+// Return the signature of the target hash
+type ProtocolMsgReturnSignature struct {
+	SignStuffHash fields.Hash // Hash of payment or reconciliation to be signed
+	Sign          fields.Sign
+}
+
+func (m *ProtocolMsgReturnSignature) Type() uint8 {
+	return ProtocolMsgTypeReturnSignature
+}
+
+func (m *ProtocolMsgReturnSignature) Size() uint32 {
+	return m.SignStuffHash.Size() + m.Sign.Size()
+}
+
+func (m *ProtocolMsgReturnSignature) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var bt1, _ = m.SignStuffHash.Serialize()
+	buffer.Write(bt1)
+	var bt2, _ = m.Sign.Serialize()
+	buffer.Write(bt2)
+	return buffer.Bytes(), nil
+}
+
+func (m *ProtocolMsgReturnSignature) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = m.SignStuffHash.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if uint32(len(buf)) < seek+fields.SignSize {
+		return 0, fmt.Errorf("Protocol message buf too short.")
+	}
+	seek, e = m.Sign.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+/********************************************************/
+
+// Reject payment or reconciliation
+type ProtocolMsgReject struct {
+	ErrCode fields.VarUint2
+	Reason  fields.StringMax255
+}
+
+func (m *ProtocolMsgReject) Type() uint8 {
+	return ProtocolMsgTypeReject
+}
+
+func (m *ProtocolMsgReject) Size() uint32 {
+	return m.ErrCode.Size() + m.Reason.Size()
+}
+
+func (m *ProtocolMsgReject) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var bt1, _ = m.ErrCode.Serialize()
+	buffer.Write(bt1)
+	var bt2, _ = m.Reason.Serialize()
+	buffer.Write(bt2)
+	return buffer.Bytes(), nil
+}
+
+func (m *ProtocolMsgReject) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = m.ErrCode.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = m.Reason.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+/********************************************************/
+
+// Request reconciliation, carry the bill signed by the requester
+type ProtocolMsgRequestReconciliation struct {
+	Bill OffChainFormPaymentChannelRealtimeReconciliation
+}
+
+func (m *ProtocolMsgRequestReconciliation) Type() uint8 {
+	return ProtocolMsgTypeRequestReconciliation
+}
+
+func (m *ProtocolMsgRequestReconciliation) Size() uint32 {
+	return m.Bill.Size()
+}
+
+func (m *ProtocolMsgRequestReconciliation) Serialize() ([]byte, error) {
+	return m.Bill.Serialize()
+}
+
+func (m *ProtocolMsgRequestReconciliation) Parse(buf []byte, seek uint32) (uint32, error) {
+	return m.Bill.Parse(buf, seek)
+}
+
+/********************************************************/
+
+// Exchange the complete documents with all signatures
+type ProtocolMsgExchangeDocuments struct {
+	Documents ChannelPayCompleteDocuments
+}
+
+func (m *ProtocolMsgExchangeDocuments) Type() uint8 {
+	return ProtocolMsgTypeExchangeDocuments
+}
+
+func (m *ProtocolMsgExchangeDocuments) Size() uint32 {
+	return m.Documents.Size()
+}
+
+func (m *ProtocolMsgExchangeDocuments) Serialize() ([]byte, error) {
+	if m.Documents.ProveBodys == nil || m.Documents.ChainPayment == nil {
+		return nil, fmt.Errorf("Protocol message documents not be nil.")
+	}
+	return m.Documents.Serialize()
+}
+
+func (m *ProtocolMsgExchangeDocuments) Parse(buf []byte, seek uint32) (uint32, error) {
+	return m.Documents.Parse(buf, seek)
+}
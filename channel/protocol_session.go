@@ -0,0 +1,145 @@
+package channel
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/fields"
+)
+
+/**
+
+通道协商会话状态机
+
+*/
+
+const (
+	ProtocolStateIdle                   uint8 = 0 // No pending negotiation
+	ProtocolStateWaitPaymentSignature   uint8 = 1 // Payment proposed, waiting for signature or reject
+	ProtocolStateWaitDocuments          uint8 = 2 // Payment signed, waiting for complete documents
+	ProtocolStateWaitReconciliationSign uint8 = 3 // Reconciliation requested, waiting for signature or reject
+)
+
+// Negotiation session between two channel parties (or a hub)
+// Both sides feed every sent and received message in order
+type ProtocolSession struct {
+	state uint8
+	// Hash of the payment or reconciliation waiting for signature
+	pendingSignStuffHash fields.Hash
+	// Addresses allowed to return the signature
+	pendingSignAddresses []fields.Address
+}
+
+func NewProtocolSession() *ProtocolSession {
+	return &ProtocolSession{
+		state: ProtocolStateIdle,
+	}
+}
+
+func (s *ProtocolSession) State() uint8 {
+	return s.state
+}
+
+// Check the message order and advance the state
+func (s *ProtocolSession) Accept(msg ProtocolMessage) error {
+	if msg == nil {
+		return fmt.Errorf("Protocol message not be nil.")
+	}
+	switch s.state {
+
+	case ProtocolStateIdle:
+		switch m := msg.(type) {
+		case *ProtocolMsgProposePayment:
+			if m.Documents.ProveBodys == nil || m.Documents.ChainPayment == nil {
+				return fmt.Errorf("Propose payment documents not be nil.")
+			}
+			s.pendingSignStuffHash = m.Documents.ChainPayment.GetSignStuffHash()
+			s.pendingSignAddresses = m.Documents.ChainPayment.MustSignAddresses
+			s.state = ProtocolStateWaitPaymentSignature
+			return nil
+		case *ProtocolMsgRequestReconciliation:
+			s.pendingSignStuffHash = m.Bill.SignStuffHash()
+			s.pendingSignAddresses = []fields.Address{m.Bill.LeftAddress, m.Bill.RightAddress}
+			s.state = ProtocolStateWaitReconciliationSign
+			return nil
+		}
+
+	case ProtocolStateWaitPaymentSignature:
+		switch m := msg.(type) {
+		case *ProtocolMsgReturnSignature:
+			if e := s.checkReturnSignature(m); e != nil {
+				return e
+			}
+			s.state = ProtocolStateWaitDocuments
+			return nil
+		case *ProtocolMsgReject:
+			s.reset()
+			return nil
+		}
+
+	case ProtocolStateWaitDocuments:
+		switch m := msg.(type) {
+		case *ProtocolMsgExchangeDocuments:
+			if m.Documents.ProveBodys == nil || m.Documents.ChainPayment == nil {
+				return fmt.Errorf("Exchange documents not be nil.")
+			}
+			if s.pendingSignStuffHash.Equal(m.Documents.ChainPayment.GetSignStuffHash()) == false {
+				return fmt.Errorf("Exchange documents not match the proposed payment.")
+			}
+			// Check all signatures
+			e := m.Documents.ChainPayment.CheckMustAddressAndSigns()
+			if e != nil {
+				return e
+			}
+			s.reset()
+			return nil
+		case *ProtocolMsgReject:
+			s.reset()
+			return nil
+		}
+
+	case ProtocolStateWaitReconciliationSign:
+		switch m := msg.(type) {
+		case *ProtocolMsgReturnSignature:
+			if e := s.checkReturnSignature(m); e != nil {
+				return e
+			}
+			s.reset()
+			return nil
+		case *ProtocolMsgReject:
+			s.reset()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Protocol message type <%d> not allowed in state <%d>.", msg.Type(), s.state)
+}
+
+// Check the returned signature matches the pending hash and is signed by a party of it
+func (s *ProtocolSession) checkReturnSignature(m *ProtocolMsgReturnSignature) error {
+	if s.pendingSignStuffHash.Equal(m.SignStuffHash) == false {
+		return fmt.Errorf("Return signature hash not match, need %s but got %s.",
+			s.pendingSignStuffHash.ToHex(), m.SignStuffHash.ToHex())
+	}
+	sgaddr := fields.Address(account.NewAddressFromPublicKeyV0(m.Sign.PublicKey))
+	var isparty = false
+	for _, addr := range s.pendingSignAddresses {
+		if sgaddr.Equal(addr) {
+			isparty = true
+			break
+		}
+	}
+	if !isparty {
+		return fmt.Errorf("Return signature address %s not a party of the pending sign.", sgaddr.ToReadable())
+	}
+	ok, _ := account.CheckSignByHash32(m.SignStuffHash, m.Sign.PublicKey, m.Sign.Signature)
+	if !ok {
+		return fmt.Errorf("Return signature verify fail.")
+	}
+	return nil
+}
+
+func (s *ProtocolSession) reset() {
+	s.state = ProtocolStateIdle
+	s.pendingSignStuffHash = nil
+	s.pendingSignAddresses = nil
+}
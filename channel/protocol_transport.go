@@ -0,0 +1,71 @@
+package channel
+
+import (
+	"fmt"
+	"sync"
+)
+
+/**
+
+通道协商消息传输
+
+*/
+
+// Message transport between two channel parties
+type ProtocolTransport interface {
+	Send(msg ProtocolMessage) error
+	Receive() (ProtocolMessage, error) // Block until a message arrives
+	Close()
+}
+
+// In-memory transport, messages pass through serialize and parse, used for tests
+type LoopbackTransport struct {
+	sendch chan []byte
+	recvch chan []byte
+	closed chan struct{}
+	once   *sync.Once
+}
+
+// Create a pair of connected transports
+func NewLoopbackTransportPair(bufsize int) (*LoopbackTransport, *LoopbackTransport) {
+	ch1 := make(chan []byte, bufsize)
+	ch2 := make(chan []byte, bufsize)
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	t1 := &LoopbackTransport{ch1, ch2, closed, once}
+	t2 := &LoopbackTransport{ch2, ch1, closed, once}
+	return t1, t2
+}
+
+func (t *LoopbackTransport) Send(msg ProtocolMessage) error {
+	data, e := SerializeProtocolMessage(msg)
+	if e != nil {
+		return e
+	}
+	select {
+	case <-t.closed:
+		return fmt.Errorf("Loopback transport is closed.")
+	case t.sendch <- data:
+		return nil
+	}
+}
+
+func (t *LoopbackTransport) Receive() (ProtocolMessage, error) {
+	select {
+	case <-t.closed:
+		return nil, fmt.Errorf("Loopback transport is closed.")
+	case data := <-t.recvch:
+		msg, _, e := ParseProtocolMessage(data, 0)
+		if e != nil {
+			return nil, e
+		}
+		return msg, nil
+	}
+}
+
+// Close both sides
+func (t *LoopbackTransport) Close() {
+	t.once.Do(func() {
+		close(t.closed)
+	})
+}
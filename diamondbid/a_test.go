@@ -0,0 +1,89 @@
+package diamondbid
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	// Bids 1 ~ 10 HAC of diamond 30001 ~ 30010
+	smelts := make([]*stores.DiamondSmelt, 0)
+	for i := 1; i <= 10; i++ {
+		smelt := &stores.DiamondSmelt{
+			Number:              fields.DiamondNumber(30000 + i),
+			AverageBidBurnPrice: fields.VarUint2(i),
+		}
+		smelt.ParseApproxFeeOffer(fields.NewAmountByUnitMei(int64(i)))
+		smelts = append(smelts, smelt)
+	}
+	history := AnalyzeHistory(smelts)
+	fmt.Println(history.Bids.Min.ToFinString(), history.Bids.Max.ToFinString(), history.Bids.Mean.ToFinString(), history.Bids.Median.ToFinString(), history.TotalBurned.ToFinString())
+	if !history.Bids.Mean.Equal(fields.NewAmountByUnit(55, 247)) || !history.Bids.Percentile(0.8).Equal(fields.NewAmountByUnitMei(8)) {
+		t.Fatal("bid stats error")
+	}
+	if !history.TotalBurned.Equal(fields.NewAmountByUnit(495, 247)) || history.AverageBidBurnPrice != 10 || history.LastNumber != 30010 {
+		t.Fatal("history stats error")
+	}
+	if history.Bids.WinRatio(fields.NewAmountByUnit(35, 247)) != 0.3 {
+		t.Fatal("win ratio error")
+	}
+
+	// Competing in pool
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	var createTx = func(acc *account.Account, number uint32, fee *fields.Amount) *transactions.Transaction_2_Simple {
+		tx, _ := transactions.CreateOneTxOfDiamondCreate(acc, &actions.Action_4_DiamondCreate{
+			Diamond: fields.DiamondName("WTYUIA"),
+			Number:  fields.DiamondNumber(number),
+			Address: acc.Address,
+		}, fee, 1)
+		return tx
+	}
+	mytx := createTx(acc1, 30011, fields.NewAmountByUnitMei(3))
+	pool := []interfaces.Transaction{
+		mytx,
+		createTx(acc2, 30011, fields.NewAmountByUnitMei(9)),
+		createTx(acc2, 30012, fields.NewAmountByUnitMei(20)),
+	}
+	competing := CompetingBids(pool, 30011, acc1.Address)
+	if len(competing) != 1 {
+		t.Fatal("competing bids error")
+	}
+	bid, e := RecommendBid(history, competing, 0.5)
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(bid.ToFinString())
+	if !bid.Equal(fields.NewAmountByUnit(9001, 245)) {
+		t.Fatal("recommend bid error")
+	}
+	bid, _ = RecommendBid(history, nil, 0.9)
+	if !bid.Equal(fields.NewAmountByUnitMei(9)) {
+		t.Fatal("recommend bid error")
+	}
+
+	// Raise fee
+	newtx, e := RaiseDiamondTxFee(mytx, acc1, bid)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ok, _ := newtx.VerifyAllNeedSigns(); !ok || !newtx.GetFee().Equal(bid) {
+		t.Fatal("raise fee error")
+	}
+	act1, _ := mytx.GetActionList()[0].Serialize()
+	act2, _ := newtx.GetActionList()[0].Serialize()
+	if string(act1) != string(act2) || !mytx.GetFee().Equal(fields.NewAmountByUnitMei(3)) {
+		t.Fatal("raise fee changed the action or origin tx")
+	}
+	if _, e := RaiseDiamondTxFee(mytx, acc1, fields.NewAmountByUnitMei(2)); e == nil {
+		t.Fatal("raise fee check error")
+	}
+
+}
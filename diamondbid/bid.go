@@ -0,0 +1,70 @@
+package diamondbid
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+)
+
+// Outbid the highest competing bid by this at least
+var MinBidIncrement = fields.NewAmountSmall(1, 244) // 0.0001 HAC
+
+// Recommend a bid for the target win probability (0 ~ 1):
+// the bid at the probability percentile of history, and higher than all competing bids
+func RecommendBid(history *HistoryStats, competing []*fields.Amount, probability float64) (*fields.Amount, error) {
+	if probability < 0 || probability > 1 {
+		return nil, fmt.Errorf("Win probability must between 0 and 1.")
+	}
+	bid := fields.NewEmptyAmount()
+	if history != nil {
+		bid = history.Bids.Percentile(probability)
+	}
+	if len(competing) > 0 {
+		highest := NewBidStats(competing).Max
+		outbid, e := highest.Add(MinBidIncrement)
+		if e != nil {
+			return nil, e
+		}
+		if outbid.MoreThan(bid) {
+			bid = outbid
+		}
+	}
+	if !bid.IsPositive() {
+		bid = MinBidIncrement
+	}
+	// Same as the precision of DiamondSmelt.ApproxFeeOffer, round up
+	bid, _, e := bid.CompressForMainNumLen(4, true)
+	if e != nil {
+		return nil, e
+	}
+	return bid.Copy(), nil
+}
+
+// Raise the fee of a diamond create tx and sign it again, the action is not changed
+func RaiseDiamondTxFee(tx interfaces.Transaction, feeacc *account.Account, newfee *fields.Amount) (interfaces.Transaction, error) {
+	acts := tx.GetActionList()
+	if len(acts) != 1 {
+		return nil, fmt.Errorf("Diamond create tx need only one action but got %d actions.", len(acts))
+	}
+	if _, ok := acts[0].(*actions.Action_4_DiamondCreate); !ok {
+		return nil, fmt.Errorf("Tx is not diamond create.")
+	}
+	if !tx.GetAddress().Equal(feeacc.Address) {
+		return nil, fmt.Errorf("Fee account need %s but got %s.", tx.GetAddress().ToReadable(), fields.Address(feeacc.Address).ToReadable())
+	}
+	if !newfee.MoreThan(tx.GetFee()) {
+		return nil, fmt.Errorf("New fee %s must more than %s.", newfee.ToFinString(), tx.GetFee().ToFinString())
+	}
+	newtx := tx.Clone()
+	newtx.SetFee(newfee)
+	newtx.CleanSigns()
+	allPrivateKeyBytes := make(map[string][]byte, 1)
+	allPrivateKeyBytes[string(feeacc.Address)] = feeacc.PrivateKey
+	e := newtx.FillNeedSigns(allPrivateKeyBytes, nil)
+	if e != nil {
+		return nil, e
+	}
+	return newtx, nil
+}
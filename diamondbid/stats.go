@@ -0,0 +1,152 @@
+package diamondbid
+
+import (
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+	"math/big"
+	"sort"
+)
+
+/**
+
+钻石竞价统计：
+
+. 历史：DiamondSmelt 中记录的中标手续费（ApproxFeeOffer，保留四位有效数字）
+. 竞争：交易池中同一编号钻石的挖矿交易手续费
+. 第 30001 枚开始，中标手续费的 90% 被销毁
+
+*/
+
+// Distribution of bids
+type BidStats struct {
+	Count  int
+	Min    *fields.Amount
+	Max    *fields.Amount
+	Mean   *fields.Amount
+	Median *fields.Amount
+	Sorted []*fields.Amount // Ascending
+}
+
+// Bid at the percentile p (0 ~ 1) by the nearest rank
+func (s *BidStats) Percentile(p float64) *fields.Amount {
+	if s.Count == 0 {
+		return fields.NewEmptyAmount()
+	}
+	if p <= 0 {
+		return s.Min
+	}
+	if p >= 1 {
+		return s.Max
+	}
+	rank := int(p*float64(s.Count)+0.9999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return s.Sorted[rank]
+}
+
+// Ratio of bids less than or equal to the amount
+func (s *BidStats) WinRatio(bid *fields.Amount) float64 {
+	if s.Count == 0 {
+		return 1
+	}
+	n := sort.Search(s.Count, func(i int) bool {
+		return s.Sorted[i].MoreThan(bid)
+	})
+	return float64(n) / float64(s.Count)
+}
+
+func NewBidStats(bids []*fields.Amount) *BidStats {
+	sorted := make([]*fields.Amount, len(bids))
+	copy(sorted, bids)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].LessThan(sorted[j])
+	})
+	s := &BidStats{
+		Count:  len(sorted),
+		Sorted: sorted,
+	}
+	if s.Count == 0 {
+		s.Min, s.Max, s.Mean, s.Median = fields.NewEmptyAmount(), fields.NewEmptyAmount(), fields.NewEmptyAmount(), fields.NewEmptyAmount()
+		return s
+	}
+	s.Min = sorted[0]
+	s.Max = sorted[s.Count-1]
+	s.Median = sorted[s.Count/2]
+	total := big.NewInt(0)
+	for _, b := range sorted {
+		total.Add(total, b.GetValue())
+	}
+	total.Quo(total, big.NewInt(int64(s.Count)))
+	s.Mean, _ = fields.NewAmountByBigInt(total)
+	if s.Mean == nil {
+		s.Mean = fields.NewEmptyAmount()
+	}
+	return s
+}
+
+// Burned part of the bid, same as Action_4_DiamondCreate
+func BurnedAmount(number uint32, fee *fields.Amount) *fields.Amount {
+	if number <= actions.DiamondCreateBurning90PercentTxFeesAboveNumber || fee.Unit < 2 {
+		return fields.NewEmptyAmount()
+	}
+	f2 := fee.Copy()
+	f2.Unit -= 1
+	burn, e := fee.Sub(f2)
+	if e != nil {
+		return fields.NewEmptyAmount()
+	}
+	return burn
+}
+
+// Statistics of the mined diamonds
+type HistoryStats struct {
+	Bids                *BidStats
+	TotalBurned         *fields.Amount
+	FirstNumber         uint32
+	LastNumber          uint32
+	AverageBidBurnPrice uint16 // Of the last diamond
+}
+
+func AnalyzeHistory(smelts []*stores.DiamondSmelt) *HistoryStats {
+	bids := make([]*fields.Amount, 0, len(smelts))
+	burned := fields.NewEmptyAmount()
+	res := &HistoryStats{}
+	for _, smelt := range smelts {
+		num := uint32(smelt.Number)
+		bid := smelt.GetApproxFeeOffer()
+		bids = append(bids, bid)
+		if b, e := burned.Add(BurnedAmount(num, bid)); e == nil {
+			burned = b
+		}
+		if res.FirstNumber == 0 || num < res.FirstNumber {
+			res.FirstNumber = num
+		}
+		if num >= res.LastNumber {
+			res.LastNumber = num
+			res.AverageBidBurnPrice = uint16(smelt.AverageBidBurnPrice)
+		}
+	}
+	res.Bids = NewBidStats(bids)
+	res.TotalBurned = burned
+	return res
+}
+
+// Competing bids in the pool of the diamond number, excluding the address (usually self)
+func CompetingBids(txs []interfaces.Transaction, number uint32, exclude fields.Address) []*fields.Amount {
+	bids := make([]*fields.Amount, 0)
+	for _, tx := range txs {
+		act := transactions.CheckoutAction_4_DiamondCreateFromTx(tx)
+		if act == nil || uint32(act.Number) != number {
+			continue
+		}
+		if exclude != nil && (tx.GetAddress().Equal(exclude) || act.Address.Equal(exclude)) {
+			continue
+		}
+		bids = append(bids, tx.GetFee())
+	}
+	return bids
+}
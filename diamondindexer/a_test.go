@@ -0,0 +1,250 @@
+package diamondindexer
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/blocks"
+	"github.com/hacash/core/diamondname"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	acc3 := account.CreateAccountByPassword("abcdef")
+
+	idx := NewDiamondIndexer()
+	var scan = func(height uint64, main fields.Address, acts ...interfaces.Action) {
+		blk := blocks.NewEmptyBlockV1()
+		blk.Height = fields.BlockHeight(height)
+		trs, _ := transactions.NewEmptyTransaction_2_Simple(main)
+		trs.Fee = *fields.NewAmountSmall(1, 244)
+		for _, act := range acts {
+			trs.AddAction(act)
+		}
+		if idx.ScanTx(blk, trs) != 0 {
+			t.Fatal("scan tx return error")
+		}
+	}
+	var diamonds = func(names ...string) fields.DiamondListMaxLen200 {
+		list := fields.DiamondListMaxLen200{Count: fields.VarUint1(len(names))}
+		for _, n := range names {
+			list.Diamonds = append(list.Diamonds, fields.DiamondName(n))
+		}
+		return list
+	}
+
+	// Mint
+	scan(5, acc1.Address, &actions.Action_4_DiamondCreate{Diamond: fields.DiamondName("NHMYYM"), Number: 1, Address: acc1.Address})
+	scan(10, acc1.Address, &actions.Action_4_DiamondCreate{Diamond: fields.DiamondName("WTYUIA"), Number: 2, Address: acc1.Address})
+	// Transfer
+	scan(11, acc1.Address, &actions.Action_5_DiamondTransfer{Diamond: fields.DiamondName("NHMYYM"), ToAddress: acc2.Address})
+	scan(12, acc3.Address, &actions.Action_6_OutfeeQuantityDiamondTransfer{FromAddress: acc2.Address, ToAddress: acc3.Address, DiamondList: diamonds("NHMYYM")})
+	// Lending and seized by the lender
+	lendid := []byte("userlending0001")
+	scan(13, acc1.Address, &actions.Action_19_UsersLendingCreate{LendingID: lendid, MortgagorAddress: acc1.Address, LenderAddress: acc2.Address, MortgageDiamondList: diamonds("WTYUIA")})
+	if rcd := idx.Diamond("WTYUIA"); !rcd.IsLending || !rcd.Owner.Equal(acc1.Address) {
+		t.Fatal("lending lock error")
+	}
+	scan(14, acc2.Address, &actions.Action_20_UsersLendingRansom{LendingID: lendid})
+	// Engrave
+	scan(15, acc2.Address, &actions.Action_32_DiamondsEngraved{DiamondList: diamonds("WTYUIA"), EngravedContent: fields.CreateStringMax255("hello")})
+	scan(16, acc2.Address, &actions.Action_32_DiamondsEngraved{DiamondList: diamonds("WTYUIA"), EngravedContent: fields.CreateStringMax255("world")})
+
+	rcd := idx.DiamondByNumber(2)
+	for _, ev := range rcd.History {
+		fmt.Println(ev.BlockHeight, ev.Kind.String(), ev.From.ToReadable(), ev.To.ToReadable(), ev.Content)
+	}
+	if rcd.Name != "WTYUIA" || rcd.Mint.BlockHeight != 10 || !rcd.Mint.BidFee.Equal(fields.NewAmountSmall(1, 244)) {
+		t.Fatal("mint info error")
+	}
+	if rcd.IsLending || !rcd.Owner.Equal(acc2.Address) || len(rcd.History) != 5 || len(rcd.Engravings) != 2 {
+		t.Fatal("diamond record error")
+	}
+	scan(17, acc2.Address, &actions.Action_33_DiamondsEngravedRecovery{DiamondList: diamonds("WTYUIA")})
+	if len(idx.Diamond("WTYUIA").Engravings) != 0 {
+		t.Fatal("engrave clear error")
+	}
+
+	// Query by address
+	if own := idx.OwnedDiamonds(acc1.Address); len(own) != 0 {
+		t.Fatal("acc1 owned error", own)
+	}
+	if own := idx.OwnedDiamonds(acc2.Address); len(own) != 1 || own[0] != "WTYUIA" {
+		t.Fatal("acc2 owned error", own)
+	}
+	if own := idx.OwnedDiamonds(acc3.Address); len(own) != 1 || own[0] != "NHMYYM" {
+		t.Fatal("acc3 owned error", own)
+	}
+	// mint 2, transfer 1, lending lock 1, unlock 1
+	if n := len(idx.AddressHistory(acc1.Address)); n != 5 {
+		t.Fatal("acc1 history error", n)
+	}
+
+	// Escrow refund to buyer acc2, swap to acc1, time lock to acc3
+	escrowid := []byte("escrowid00000001")
+	scan(18, acc2.Address, &actions.Action_45_EscrowCreate{EscrowId: escrowid, BuyerAddress: acc2.Address, SellerAddress: acc1.Address, LockDiamondList: diamonds("WTYUIA")})
+	if rcd := idx.Diamond("WTYUIA"); rcd.Status != stores.DiamondStatusEscrow || !rcd.Owner.Equal(acc2.Address) {
+		t.Fatal("escrow lock error")
+	}
+	scan(19, acc2.Address, &actions.Action_47_EscrowRefund{EscrowId: escrowid})
+	scan(20, acc1.Address, &actions.Action_48_DiamondSwap{Offer: actions.DiamondSwapOffer{SellerAddress: acc2.Address, DiamondList: diamonds("WTYUIA")}, BuyerAddress: acc1.Address})
+	trsid := []byte("timelocktrs00001")
+	scan(21, acc1.Address, &actions.Action_50_TimeLockTransferCreate{TransferId: trsid, FromAddress: acc1.Address, ToAddress: acc3.Address, DiamondList: diamonds("WTYUIA")})
+	if rcd := idx.Diamond("WTYUIA"); rcd.Status != stores.DiamondStatusTimeLocked || !rcd.Owner.Equal(acc3.Address) {
+		t.Fatal("time lock error")
+	}
+	scan(22, acc3.Address, &actions.Action_51_TimeLockTransferClaim{TransferId: trsid})
+	// Vault bought out by acc1
+	vaultid := []byte("diamondvault0001")
+	scan(23, acc3.Address, &actions.Action_52_DiamondVaultCreate{VaultId: vaultid, CreatorAddress: acc3.Address, DiamondList: diamonds("WTYUIA", "NHMYYM")})
+	if rcd := idx.Diamond("NHMYYM"); rcd.Status != stores.DiamondStatusVault || !rcd.Owner.Equal(acc3.Address) {
+		t.Fatal("vault lock error")
+	}
+	scan(24, acc1.Address, &actions.Action_55_DiamondVaultBuyout{VaultId: vaultid, BuyerAddress: acc1.Address})
+	if own := idx.OwnedDiamonds(acc1.Address); len(own) != 2 || idx.Diamond("WTYUIA").Status != stores.DiamondStatusNormal {
+		t.Fatal("vault buyout error", own)
+	}
+	if own := idx.OwnedDiamonds(acc3.Address); len(own) != 0 {
+		t.Fatal("acc3 owned error", own)
+	}
+
+	// Reset
+	idx.Init()
+	if idx.Diamond("NHMYYM") != nil {
+		t.Fatal("init error")
+	}
+
+}
+
+func Test2(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	idx := NewDiamondIndexer()
+	var scan = func(height uint64, main fields.Address, act interfaces.Action) {
+		blk := blocks.NewEmptyBlockV1()
+		blk.Height = fields.BlockHeight(height)
+		trs, _ := transactions.NewEmptyTransaction_2_Simple(main)
+		trs.AddAction(act)
+		if idx.ScanTx(blk, trs) != 0 {
+			t.Fatal("scan tx return error")
+		}
+	}
+	var diamonds = func(names ...string) fields.DiamondListMaxLen200 {
+		list := fields.DiamondListMaxLen200{Count: fields.VarUint1(len(names))}
+		for _, n := range names {
+			list.Diamonds = append(list.Diamonds, fields.DiamondName(n))
+		}
+		return list
+	}
+
+	scan(5, acc1.Address, &actions.Action_4_DiamondCreate{Diamond: fields.DiamondName("NHMYYM"), Number: 1, Address: acc1.Address})
+	scan(10, acc1.Address, &actions.Action_4_DiamondCreate{Diamond: fields.DiamondName("WTYUIA"), Number: 2, Address: acc1.Address})
+
+	// Lock -> partial repay -> ransom by the lender
+	lendid := []byte("userlending0002")
+	scan(11, acc1.Address, &actions.Action_19_UsersLendingCreate{LendingID: lendid, MortgagorAddress: acc1.Address, LenderAddress: acc2.Address, MortgageDiamondList: diamonds("NHMYYM", "WTYUIA")})
+	scan(12, acc1.Address, &actions.Action_37_UsersLendingPartialRepay{LendingID: lendid, RepayAmount: *fields.NewAmountSmall(5, 248), ReleaseDiamondList: diamonds("NHMYYM")})
+	if rcd := idx.Diamond("NHMYYM"); rcd.IsLending || !rcd.Owner.Equal(acc1.Address) {
+		t.Fatal("partial repay release error")
+	}
+	if rcd := idx.Diamond("WTYUIA"); !rcd.IsLending || !rcd.Owner.Equal(acc1.Address) {
+		t.Fatal("partial repay remain error")
+	}
+	if ev := idx.Diamond("NHMYYM").History[2]; ev.Kind != EventLendingUnlock || !ev.To.Equal(acc1.Address) || string(ev.LendingId) != string(lendid) {
+		t.Fatal("partial repay event error")
+	}
+
+	// The ransom only moves the remaining diamond
+	scan(13, acc2.Address, &actions.Action_20_UsersLendingRansom{LendingID: lendid})
+	if rcd := idx.Diamond("WTYUIA"); rcd.IsLending || !rcd.Owner.Equal(acc2.Address) {
+		t.Fatal("ransom error")
+	}
+	if rcd := idx.Diamond("NHMYYM"); len(rcd.History) != 3 || !rcd.Owner.Equal(acc1.Address) {
+		t.Fatal("released diamond moved by ransom")
+	}
+	if own := idx.OwnedDiamonds(acc1.Address); len(own) != 1 || own[0] != "NHMYYM" {
+		t.Fatal("acc1 owned error", own)
+	}
+
+}
+
+// Diamond name records of the current owner
+func Test3(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	acc3 := account.CreateAccountByPassword("abcdef")
+
+	rcd := &DiamondRecord{
+		Name:  "WTYUIA",
+		Mint:  &MintInfo{Number: 2, BlockHeight: 10},
+		Owner: acc1.Address,
+		History: []*Event{
+			{Kind: EventMint, Diamond: "WTYUIA", BlockHeight: 10, To: acc1.Address},
+		},
+	}
+	var event = func(kind EventKind, height uint64, from, to fields.Address, content string) {
+		rcd.History = append(rcd.History, &Event{
+			Kind: kind, Diamond: "WTYUIA", BlockHeight: height, From: from, To: to, Content: content,
+		})
+		rcd.Owner = to
+	}
+	var engrave = func(height uint64, key, value string) {
+		content, e := diamondname.CreateRecordContent(key, value)
+		if e != nil {
+			t.Fatal(e)
+		}
+		fmt.Println(len(content), content)
+		event(EventEngrave, height, rcd.Owner, rcd.Owner, content)
+	}
+
+	event(EventEngrave, 20, acc1.Address, acc1.Address, "hello")
+	engrave(1100, diamondname.RecordKeyPay, fields.Address(acc2.Address).ToReadable())
+	engrave(2200, "url", "https://hacash.org")
+	engrave(3300, "url", "https://hacash.com")
+	res, e := rcd.ResolveName()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !res.PayAddress.Equal(acc2.Address) || res.Records["url"] != "https://hacash.com" || res.OwnerSince != 10 {
+		t.Fatal("resolve error")
+	}
+
+	// Transferred, records of the previous owner are stale
+	event(EventTransfer, 3400, acc1.Address, acc3.Address, "")
+	res, _ = rcd.ResolveName()
+	if !res.PayAddress.Equal(acc3.Address) || len(res.Records) != 0 || res.OwnerSince != 3400 {
+		t.Fatal("stale records not ignored")
+	}
+	// Lending lock and redeem keep the owner
+	event(EventLendingLock, 3500, acc3.Address, acc3.Address, "")
+	engrave(4500, "name", "Alice, Bob: {x}")
+	event(EventLendingUnlock, 4600, acc3.Address, acc3.Address, "")
+	res, _ = rcd.ResolveName()
+	if res.Records["name"] != "Alice, Bob: {x}" || res.OwnerSince != 3400 {
+		t.Fatal("escaped record error")
+	}
+	// Cleared
+	event(EventEngraveClear, 4700, acc3.Address, acc3.Address, "")
+	if res, _ = rcd.ResolveName(); len(res.Records) != 0 {
+		t.Fatal("cleared records not ignored")
+	}
+
+	rcd.Mint = nil
+	if _, e := rcd.ResolveName(); e == nil {
+		t.Fatal("not fully indexed check error")
+	}
+	if _, e := NewDiamondIndexer().ResolveName("@NHMYYM"); e == nil {
+		t.Fatal("not find check error")
+	}
+
+}
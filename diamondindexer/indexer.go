@@ -0,0 +1,369 @@
+package diamondindexer
+
+import (
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"sort"
+	"sync"
+)
+
+/**
+
+钻石所有权与历史索引，实现 interfaces.ConfirmTxIndexer
+
+只扫描已确认的交易，不处理回滚
+
+Tracked actions: 4 mint, 5/6/7 transfer, 15/16/19/20/37 lending, 32/33 engrave,
+45/46/47 escrow, 48 swap, 50/51 time lock, 52/54/55 vault
+
+*/
+
+type DiamondIndexer struct {
+	diamonds map[string]*DiamondRecord              // name => record
+	numbers  map[uint32]string                      // number => name
+	owned    map[string]map[string]bool             // address => names
+	addrlogs map[string][]*Event                    // address => events
+	lendings map[string]fields.DiamondListMaxLen200 // lending id => mortgage diamonds
+	locks    map[string]*lockInfo                   // escrow, time lock or vault id => locked diamonds
+
+	lock sync.RWMutex
+}
+
+func NewDiamondIndexer() *DiamondIndexer {
+	idx := &DiamondIndexer{}
+	idx.Init()
+	return idx
+}
+
+func (idx *DiamondIndexer) Init() {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.diamonds = make(map[string]*DiamondRecord)
+	idx.numbers = make(map[uint32]string)
+	idx.owned = make(map[string]map[string]bool)
+	idx.addrlogs = make(map[string][]*Event)
+	idx.lendings = make(map[string]fields.DiamondListMaxLen200)
+	idx.locks = make(map[string]*lockInfo)
+}
+
+// Diamonds locked by escrow, time lock or vault
+type lockInfo struct {
+	diamonds  fields.DiamondListMaxLen200
+	owner     fields.Address // Owner while locked, and refund target of escrow
+	recipient fields.Address // Seller of escrow
+}
+
+func lockKey(kind EventKind, id []byte) string {
+	return string([]byte{byte(kind)}) + string(id)
+}
+
+// Always scan next tx
+func (idx *DiamondIndexer) ScanTx(block interfaces.BlockHeadMetaRead, tx interfaces.Transaction) int8 {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	for _, act := range tx.GetActionList() {
+		idx.scanAction(block, tx, act)
+	}
+	return 0
+}
+
+func (idx *DiamondIndexer) scanAction(block interfaces.BlockHeadMetaRead, tx interfaces.Transaction, action interfaces.Action) {
+	mainAddr := tx.GetAddress()
+	var newEvent = func(kind EventKind, diamond fields.DiamondName) *Event {
+		return &Event{
+			Kind:        kind,
+			ActionKind:  action.Kind(),
+			Diamond:     string(diamond),
+			BlockHeight: block.GetHeight(),
+			BlockHash:   block.Hash(),
+			TxHash:      tx.Hash(),
+		}
+	}
+	switch act := action.(type) {
+	case *actions.Action_4_DiamondCreate:
+		ev := newEvent(EventMint, act.Diamond)
+		ev.To = act.Address
+		rcd := idx.record(string(act.Diamond))
+		rcd.Mint = &MintInfo{
+			Number:        uint32(act.Number),
+			BlockHeight:   block.GetHeight(),
+			BlockHash:     block.Hash(),
+			TxHash:        tx.Hash(),
+			PrevHash:      act.PrevHash,
+			Nonce:         act.Nonce,
+			CustomMessage: act.CustomMessage,
+			Miner:         act.Address,
+			BidFee:        *tx.GetFee(),
+		}
+		idx.numbers[uint32(act.Number)] = rcd.Name
+		idx.apply(ev)
+	case *actions.Action_5_DiamondTransfer:
+		idx.transfer(newEvent(EventTransfer, act.Diamond), mainAddr, act.ToAddress)
+	case *actions.Action_6_OutfeeQuantityDiamondTransfer:
+		for _, dia := range act.DiamondList.Diamonds {
+			idx.transfer(newEvent(EventTransfer, dia), act.FromAddress, act.ToAddress)
+		}
+	case *actions.Action_7_MultipleDiamondTransfer:
+		for _, dia := range act.DiamondList.Diamonds {
+			idx.transfer(newEvent(EventTransfer, dia), mainAddr, act.ToAddress)
+		}
+	case *actions.Action_15_DiamondsSystemLendingCreate:
+		idx.lendingLock(newEvent, act.LendingID, act.MortgageDiamondList, mainAddr)
+	case *actions.Action_16_DiamondsSystemLendingRansom:
+		idx.lendingUnlock(newEvent, act.LendingID, mainAddr)
+	case *actions.Action_19_UsersLendingCreate:
+		idx.lendingLock(newEvent, act.LendingID, act.MortgageDiamondList, act.MortgagorAddress)
+	case *actions.Action_20_UsersLendingRansom:
+		idx.lendingUnlock(newEvent, act.LendingID, mainAddr)
+	case *actions.Action_37_UsersLendingPartialRepay:
+		idx.lendingRelease(newEvent, act.LendingID, act.ReleaseDiamondList)
+	case *actions.Action_32_DiamondsEngraved:
+		for _, dia := range act.DiamondList.Diamonds {
+			ev := newEvent(EventEngrave, dia)
+			ev.Content = act.EngravedContent.Str
+			idx.apply(ev)
+		}
+	case *actions.Action_33_DiamondsEngravedRecovery:
+		for _, dia := range act.DiamondList.Diamonds {
+			idx.apply(newEvent(EventEngraveClear, dia))
+		}
+	case *actions.Action_45_EscrowCreate:
+		idx.assetLock(newEvent, EventEscrowLock, act.EscrowId, act.LockDiamondList, act.BuyerAddress, &lockInfo{owner: act.BuyerAddress, recipient: act.SellerAddress})
+	case *actions.Action_46_EscrowRelease:
+		idx.assetUnlock(newEvent, EventEscrowLock, EventEscrowUnlock, act.EscrowId, func(lock *lockInfo) fields.Address { return lock.recipient })
+	case *actions.Action_47_EscrowRefund:
+		idx.assetUnlock(newEvent, EventEscrowLock, EventEscrowUnlock, act.EscrowId, func(lock *lockInfo) fields.Address { return lock.owner })
+	case *actions.Action_48_DiamondSwap:
+		for _, dia := range act.Offer.DiamondList.Diamonds {
+			idx.transfer(newEvent(EventTransfer, dia), act.Offer.SellerAddress, act.BuyerAddress)
+		}
+	case *actions.Action_50_TimeLockTransferCreate:
+		idx.assetLock(newEvent, EventTimeLock, act.TransferId, act.DiamondList, act.FromAddress, &lockInfo{owner: act.ToAddress})
+	case *actions.Action_51_TimeLockTransferClaim:
+		idx.assetUnlock(newEvent, EventTimeLock, EventTimeUnlock, act.TransferId, func(lock *lockInfo) fields.Address { return lock.owner })
+	case *actions.Action_52_DiamondVaultCreate:
+		idx.assetLock(newEvent, EventVaultLock, act.VaultId, act.DiamondList, act.CreatorAddress, &lockInfo{owner: act.CreatorAddress})
+	case *actions.Action_54_DiamondVaultRedeem:
+		idx.assetUnlock(newEvent, EventVaultLock, EventVaultUnlock, act.VaultId, func(*lockInfo) fields.Address { return act.RedeemAddress })
+	case *actions.Action_55_DiamondVaultBuyout:
+		idx.assetUnlock(newEvent, EventVaultLock, EventVaultUnlock, act.VaultId, func(*lockInfo) fields.Address { return act.BuyerAddress })
+	}
+}
+
+// Lock diamonds in escrow, time lock or vault, the owner while locked is lock.owner
+func (idx *DiamondIndexer) assetLock(newEvent func(EventKind, fields.DiamondName) *Event, kind EventKind, id []byte, list fields.DiamondListMaxLen200, from fields.Address, lock *lockInfo) {
+	if len(list.Diamonds) == 0 {
+		return // HAC or SAT only
+	}
+	lock.diamonds = list
+	idx.locks[lockKey(kind, id)] = lock
+	for _, dia := range list.Diamonds {
+		ev := newEvent(kind, dia)
+		ev.From = from
+		ev.To = lock.owner
+		ev.LockId = id
+		idx.apply(ev)
+	}
+}
+
+func (idx *DiamondIndexer) assetUnlock(newEvent func(EventKind, fields.DiamondName) *Event, lockkind EventKind, kind EventKind, id []byte, target func(*lockInfo) fields.Address) {
+	key := lockKey(lockkind, id)
+	lock, ok := idx.locks[key]
+	if !ok {
+		return // The lock is not scanned
+	}
+	delete(idx.locks, key)
+	to := target(lock)
+	for _, dia := range lock.diamonds.Diamonds {
+		ev := newEvent(kind, dia)
+		ev.From = idx.record(string(dia)).Owner
+		ev.To = to
+		ev.LockId = id
+		idx.apply(ev)
+	}
+}
+
+func (idx *DiamondIndexer) transfer(ev *Event, from, to fields.Address) {
+	ev.From = from
+	ev.To = to
+	idx.apply(ev)
+}
+
+func (idx *DiamondIndexer) lendingLock(newEvent func(EventKind, fields.DiamondName) *Event, id []byte, list fields.DiamondListMaxLen200, owner fields.Address) {
+	if len(list.Diamonds) == 0 {
+		return // Bitcoin only user lending
+	}
+	idx.lendings[string(id)] = list
+	for _, dia := range list.Diamonds {
+		ev := newEvent(EventLendingLock, dia)
+		ev.From = owner
+		ev.To = owner
+		ev.LendingId = id
+		idx.apply(ev)
+	}
+}
+
+// The diamonds go to the redeemer
+func (idx *DiamondIndexer) lendingUnlock(newEvent func(EventKind, fields.DiamondName) *Event, id []byte, redeemer fields.Address) {
+	list, ok := idx.lendings[string(id)]
+	if !ok {
+		return // The lending is not scanned
+	}
+	delete(idx.lendings, string(id))
+	for _, dia := range list.Diamonds {
+		ev := newEvent(EventLendingUnlock, dia)
+		ev.From = idx.record(string(dia)).Owner
+		ev.To = redeemer
+		ev.LendingId = id
+		idx.apply(ev)
+	}
+}
+
+// Partial repay, the released diamonds stay with the mortgagor
+func (idx *DiamondIndexer) lendingRelease(newEvent func(EventKind, fields.DiamondName) *Event, id []byte, release fields.DiamondListMaxLen200) {
+	list, ok := idx.lendings[string(id)]
+	if !ok {
+		return // The lending is not scanned
+	}
+	releaseMark := make(map[string]bool)
+	for _, dia := range release.Diamonds {
+		releaseMark[string(dia)] = true
+	}
+	remain := make([]fields.DiamondName, 0, len(list.Diamonds))
+	for _, dia := range list.Diamonds {
+		if !releaseMark[string(dia)] {
+			remain = append(remain, dia)
+			continue
+		}
+		mortgagor := idx.record(string(dia)).Owner
+		ev := newEvent(EventLendingUnlock, dia)
+		ev.From = mortgagor
+		ev.To = mortgagor
+		ev.LendingId = id
+		idx.apply(ev)
+	}
+	idx.lendings[string(id)] = fields.DiamondListMaxLen200{
+		Count:    fields.VarUint1(len(remain)),
+		Diamonds: remain,
+	}
+}
+
+func (idx *DiamondIndexer) record(name string) *DiamondRecord {
+	rcd, ok := idx.diamonds[name]
+	if !ok {
+		rcd = &DiamondRecord{Name: name}
+		idx.diamonds[name] = rcd
+	}
+	return rcd
+}
+
+// Update the record and address index by the event
+func (idx *DiamondIndexer) apply(ev *Event) {
+	rcd := idx.record(ev.Diamond)
+	if ev.To == nil {
+		// Engraving does not change the owner
+		ev.From = rcd.Owner
+		ev.To = rcd.Owner
+	}
+	rcd.History = append(rcd.History, ev)
+	switch ev.Kind {
+	case EventLendingLock:
+		rcd.IsLending = true
+		rcd.LendingId = ev.LendingId
+		rcd.Status = stores.DiamondStatusLendingOtherUser
+		if ev.ActionKind == 15 {
+			rcd.Status = stores.DiamondStatusLendingSystem
+		}
+	case EventLendingUnlock:
+		rcd.IsLending = false
+		rcd.LendingId = nil
+		rcd.Status = stores.DiamondStatusNormal
+	case EventEscrowLock:
+		rcd.Status = stores.DiamondStatusEscrow
+		rcd.LockId = ev.LockId
+	case EventTimeLock:
+		rcd.Status = stores.DiamondStatusTimeLocked
+		rcd.LockId = ev.LockId
+	case EventVaultLock:
+		rcd.Status = stores.DiamondStatusVault
+		rcd.LockId = ev.LockId
+	case EventEscrowUnlock, EventTimeUnlock, EventVaultUnlock:
+		rcd.Status = stores.DiamondStatusNormal
+		rcd.LockId = nil
+	case EventEngrave:
+		rcd.Engravings = append(rcd.Engravings, ev.Content)
+	case EventEngraveClear:
+		rcd.Engravings = nil
+	}
+	// Owner
+	if ev.To != nil {
+		if rcd.Owner != nil {
+			delete(idx.owned[string(rcd.Owner)], rcd.Name)
+		}
+		rcd.Owner = ev.To
+		if idx.owned[string(ev.To)] == nil {
+			idx.owned[string(ev.To)] = make(map[string]bool)
+		}
+		idx.owned[string(ev.To)][rcd.Name] = true
+	}
+	// Address history
+	if ev.To == nil {
+		return // The owner is unknown
+	}
+	idx.addrlogs[string(ev.To)] = append(idx.addrlogs[string(ev.To)], ev)
+	if ev.From != nil && !ev.From.Equal(ev.To) {
+		idx.addrlogs[string(ev.From)] = append(idx.addrlogs[string(ev.From)], ev)
+	}
+}
+
+/////////////////////////////////////////////
+
+func (rcd *DiamondRecord) copy() *DiamondRecord {
+	cp := *rcd
+	cp.Engravings = append([]string{}, rcd.Engravings...)
+	cp.History = append([]*Event{}, rcd.History...)
+	return &cp
+}
+
+// Query the diamond by name, return nil if not find
+func (idx *DiamondIndexer) Diamond(name string) *DiamondRecord {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	rcd, ok := idx.diamonds[name]
+	if !ok {
+		return nil
+	}
+	return rcd.copy()
+}
+
+// Query the diamond by number, return nil if the mint is not scanned
+func (idx *DiamondIndexer) DiamondByNumber(number uint32) *DiamondRecord {
+	idx.lock.RLock()
+	name, ok := idx.numbers[number]
+	idx.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	return idx.Diamond(name)
+}
+
+// Diamonds currently owned by the address (including locked ones), sorted by name
+func (idx *DiamondIndexer) OwnedDiamonds(addr fields.Address) []string {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	names := make([]string, 0, len(idx.owned[string(addr)]))
+	for name := range idx.owned[string(addr)] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// All events about the address, in scan order
+func (idx *DiamondIndexer) AddressHistory(addr fields.Address) []*Event {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return append([]*Event{}, idx.addrlogs[string(addr)]...)
+}
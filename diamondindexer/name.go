@@ -0,0 +1,41 @@
+package diamondindexer
+
+import (
+	"fmt"
+	"github.com/hacash/core/diamondname"
+	"github.com/hacash/core/fields"
+)
+
+// Resolve the name records, only those engraved after the current owner got the diamond are valid
+func (rcd *DiamondRecord) ResolveName() (*diamondname.Resolution, error) {
+	if rcd.Mint == nil {
+		return nil, fmt.Errorf("Diamond <%s> history is not fully indexed.", rcd.Name)
+	}
+	since := rcd.OwnerAcquiredHeight()
+	engravings := make([]string, 0)
+	for _, ev := range rcd.CurrentOwnerEngraveEvents() {
+		if ev.BlockHeight < since {
+			continue // Engraved by a previous owner
+		}
+		engravings = append(engravings, ev.Content)
+	}
+	res, e := diamondname.ResolveEngravings(fields.DiamondName(rcd.Name), rcd.Owner, engravings)
+	if e != nil {
+		return nil, e
+	}
+	res.OwnerSince = since
+	return res, nil
+}
+
+// Resolve "@WTYUIA"
+func (idx *DiamondIndexer) ResolveName(name string) (*diamondname.Resolution, error) {
+	diamond, e := diamondname.ParseName(name)
+	if e != nil {
+		return nil, e
+	}
+	rcd := idx.Diamond(string(diamond))
+	if rcd == nil {
+		return nil, fmt.Errorf("Diamond <%s> not find.", string(diamond))
+	}
+	return rcd.ResolveName()
+}
@@ -0,0 +1,123 @@
+package diamondindexer
+
+import (
+	"github.com/hacash/core/fields"
+)
+
+type EventKind uint8
+
+const (
+	EventMint          EventKind = 1  // Action_4_DiamondCreate
+	EventTransfer      EventKind = 2  // Action_5, 6, 7
+	EventLendingLock   EventKind = 3  // Action_15, 19
+	EventLendingUnlock EventKind = 4  // Action_16, 20, 37, redeemed, seized or partially repaid
+	EventEngrave       EventKind = 5  // Action_32
+	EventEngraveClear  EventKind = 6  // Action_33
+	EventEscrowLock    EventKind = 7  // Action_45
+	EventEscrowUnlock  EventKind = 8  // Action_46 to seller, Action_47 to buyer
+	EventTimeLock      EventKind = 9  // Action_50, the owner is the recipient
+	EventTimeUnlock    EventKind = 10 // Action_51
+	EventVaultLock     EventKind = 11 // Action_52
+	EventVaultUnlock   EventKind = 12 // Action_54 redeemed, Action_55 bought out
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventMint:
+		return "mint"
+	case EventTransfer:
+		return "transfer"
+	case EventLendingLock:
+		return "lending_lock"
+	case EventLendingUnlock:
+		return "lending_unlock"
+	case EventEngrave:
+		return "engrave"
+	case EventEngraveClear:
+		return "engrave_clear"
+	case EventEscrowLock:
+		return "escrow_lock"
+	case EventEscrowUnlock:
+		return "escrow_unlock"
+	case EventTimeLock:
+		return "time_lock"
+	case EventTimeUnlock:
+		return "time_unlock"
+	case EventVaultLock:
+		return "vault_lock"
+	case EventVaultUnlock:
+		return "vault_unlock"
+	}
+	return "unknown"
+}
+
+// One change of the diamond
+type Event struct {
+	Kind        EventKind
+	ActionKind  uint16
+	Diamond     string
+	BlockHeight uint64
+	BlockHash   fields.Hash
+	TxHash      fields.Hash
+	From        fields.Address // Empty for mint
+	To          fields.Address // Owner after the event
+	LendingId   []byte         // Lending events
+	LockId      []byte         // Escrow, time lock and vault events
+	Content     string         // Engrave event
+}
+
+// Mint details from Action_4_DiamondCreate
+type MintInfo struct {
+	Number        uint32
+	BlockHeight   uint64
+	BlockHash     fields.Hash
+	TxHash        fields.Hash
+	PrevHash      fields.Hash
+	Nonce         fields.Bytes8
+	CustomMessage fields.Bytes32
+	Miner         fields.Address
+	BidFee        fields.Amount // Fee of the mint transaction
+}
+
+// Current state and history of one diamond
+type DiamondRecord struct {
+	Name       string
+	Mint       *MintInfo // nil if the mint is not scanned
+	Owner      fields.Address
+	Status     fields.VarUint1 // Same as stores.DiamondStatusXxx
+	IsLending  bool
+	LendingId  []byte
+	LockId     []byte // Escrow, time lock or vault id
+	Engravings []string
+	History    []*Event
+}
+
+// Block height the current owner got the diamond, by mint, transfer, seize or unlock to another address
+func (rcd *DiamondRecord) OwnerAcquiredHeight() uint64 {
+	var height uint64 = 0
+	for _, ev := range rcd.History {
+		if ev.From == nil || !ev.From.Equal(ev.To) {
+			height = ev.BlockHeight
+		}
+	}
+	return height
+}
+
+// Engraving events after the current owner got the diamond
+// Engravings of previous owners are not included, even if not cleared
+func (rcd *DiamondRecord) CurrentOwnerEngraveEvents() []*Event {
+	events := make([]*Event, 0)
+	for _, ev := range rcd.History {
+		if ev.From == nil || !ev.From.Equal(ev.To) {
+			events = events[:0] // Owner changed
+			continue
+		}
+		switch ev.Kind {
+		case EventEngrave:
+			events = append(events, ev)
+		case EventEngraveClear:
+			events = events[:0]
+		}
+	}
+	return events
+}
@@ -0,0 +1,77 @@
+package diamondminer
+
+import (
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"testing"
+	"time"
+)
+
+// Find the diamond at the target nonce
+type testDevice struct {
+	target uint64
+}
+
+func (*testDevice) GetSuperveneWide() int {
+	return 1
+}
+
+func (d *testDevice) DoMining(stuff *MiningStuff, stopmark *uint32, nonceStart uint64, nonceEnd uint64) (bool, fields.Bytes8, string, uint64) {
+	if d.target >= nonceStart && d.target < nonceEnd {
+		return true, nonceBytes(d.target), "WTYUIA", d.target - nonceStart + 1
+	}
+	return false, nil, "", nonceEnd - nonceStart
+}
+
+func Test1(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	latest := &stores.DiamondSmelt{
+		Number:           100,
+		ContainBlockHash: fields.Hash(make([]byte, 32)),
+	}
+
+	// CPU device can be stopped
+	miner := NewDiamondMiner(NewCPUDevice(2))
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		miner.StopMining()
+	}()
+	res, e := miner.Excavate(latest, acc1.Address, fields.Bytes32(make([]byte, 32)))
+	if e == nil {
+		// A real diamond found, valid anyway
+		if _, ok := CheckDiamond(res.Stuff, res.Nonce); !ok {
+			t.Fatal("diamond check error")
+		}
+	}
+	if miner.TriedNonceNumber() == 0 {
+		t.Fatal("cpu device not mining")
+	}
+
+	// Result to action and bid tx
+	miner = NewDiamondMiner(&testDevice{MiningRoundNonceNumber + 7})
+	res, e = miner.Excavate(latest, acc1.Address, fields.Bytes32(make([]byte, 32)))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if miner.TriedNonceNumber() != MiningRoundNonceNumber+8 {
+		t.Fatal("tried nonce number error")
+	}
+	tx, e := res.CreateBidTx(acc1, fields.NewAmountSmall(5, 247), 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	act := tx.GetActionList()[0]
+	if len(tx.GetActionList()) != 1 {
+		t.Fatal("bid tx action error")
+	}
+	dia := res.CreateAction()
+	if act.Kind() != 4 || dia.Number != 101 || string(dia.Diamond) != "WTYUIA" || uint64(dia.Nonce[7]) != 7 {
+		t.Fatal("diamond action error")
+	}
+	if ok, _ := tx.VerifyAllNeedSigns(); !ok {
+		t.Fatal("bid tx sign error")
+	}
+
+}
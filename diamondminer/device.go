@@ -0,0 +1,125 @@
+package diamondminer
+
+import (
+	"encoding/binary"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/x16rs"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Mining stuff of the next diamond
+type MiningStuff struct {
+	Number        fields.DiamondNumber
+	PrevHash      fields.Hash // Contain block hash of the latest diamond
+	Address       fields.Address
+	CustomMessage fields.Bytes32
+}
+
+// Stuff of the next diamond after the latest
+func NewMiningStuff(latest *stores.DiamondSmelt, reward fields.Address, msg fields.Bytes32) *MiningStuff {
+	return &MiningStuff{
+		Number:        latest.Number + 1,
+		PrevHash:      latest.ContainBlockHash,
+		Address:       reward,
+		CustomMessage: msg,
+	}
+}
+
+// Create the diamond create action with the nonce
+func (s *MiningStuff) CreateAction(diamond string, nonce fields.Bytes8) *actions.Action_4_DiamondCreate {
+	return &actions.Action_4_DiamondCreate{
+		Diamond:       fields.DiamondName(diamond),
+		Number:        s.Number,
+		PrevHash:      s.PrevHash,
+		Nonce:         nonce,
+		Address:       s.Address,
+		CustomMessage: s.CustomMessage,
+	}
+}
+
+func nonceBytes(nonce uint64) fields.Bytes8 {
+	bts := make([]byte, 8)
+	binary.BigEndian.PutUint64(bts, nonce)
+	return bts
+}
+
+// Same as the check of Action_4_DiamondCreate
+func CheckDiamond(s *MiningStuff, nonce fields.Bytes8) (string, bool) {
+	msg := s.CreateAction("", nonce).GetRealCustomMessage()
+	sha3hash, diamondResHash, diamondStr := x16rs.Diamond(uint32(s.Number), s.PrevHash, nonce, s.Address, msg)
+	diamond, isdia := x16rs.IsDiamondHashResultString(diamondStr)
+	if !isdia {
+		return "", false
+	}
+	if !x16rs.CheckDiamondDifficulty(uint32(s.Number), sha3hash, diamondResHash) {
+		return "", false
+	}
+	return diamond, true
+}
+
+// Equipment end of diamond mining
+type DiamondDevice interface {
+	GetSuperveneWide() int // Concurrent number
+	// Search nonce in [nonceStart, nonceEnd), stop when the stop mark is 1, use sync/atomic to access it
+	// Return success, nonce, diamond name and number of tried nonce
+	DoMining(stuff *MiningStuff, stopmark *uint32, nonceStart uint64, nonceEnd uint64) (bool, fields.Bytes8, string, uint64)
+}
+
+// CPU device, each goroutine searches nonce with the step of supervene wide
+type CPUDevice struct {
+	supervene int
+}
+
+// Use all cpu cores if supervene is zero
+func NewCPUDevice(supervene int) *CPUDevice {
+	if supervene <= 0 {
+		supervene = runtime.NumCPU()
+	}
+	return &CPUDevice{supervene}
+}
+
+func (c *CPUDevice) GetSuperveneWide() int {
+	return c.supervene
+}
+
+func (c *CPUDevice) DoMining(stuff *MiningStuff, stopmark *uint32, nonceStart uint64, nonceEnd uint64) (bool, fields.Bytes8, string, uint64) {
+	var success = false
+	var resNonce fields.Bytes8
+	var resDiamond string
+	var tried uint64 = 0
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < c.supervene; i++ {
+		wg.Add(1)
+		go func(start uint64) {
+			defer wg.Done()
+			var count uint64 = 0
+			for n := start; n < nonceEnd; n += uint64(c.supervene) {
+				if atomic.LoadUint32(stopmark) == 1 {
+					break
+				}
+				count++
+				nonce := nonceBytes(n)
+				diamond, ok := CheckDiamond(stuff, nonce)
+				if ok {
+					lock.Lock()
+					if !success {
+						success, resNonce, resDiamond = true, nonce, diamond
+					}
+					lock.Unlock()
+					atomic.StoreUint32(stopmark, 1) // Stop others
+					break
+				}
+			}
+			lock.Lock()
+			tried += count
+			lock.Unlock()
+		}(nonceStart + uint64(i))
+	}
+	wg.Wait()
+	return success, resNonce, resDiamond, tried
+}
@@ -0,0 +1,118 @@
+package diamondminer
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+
+钻石挖矿参考实现：
+
+. 由最新的 stores.DiamondSmelt 得到下一枚钻石的挖矿数据
+. 设备并发搜索 nonce，可随时停止
+. 挖出后生成 Action_4_DiamondCreate 以及手续费竞价交易
+
+*/
+
+// Nonce number of each DoMining call, check the stop mark between rounds
+const MiningRoundNonceNumber uint64 = 1 << 20
+
+type MiningResult struct {
+	Stuff   *MiningStuff
+	Nonce   fields.Bytes8
+	Diamond string
+}
+
+func (r *MiningResult) CreateAction() *actions.Action_4_DiamondCreate {
+	return r.Stuff.CreateAction(r.Diamond, r.Nonce)
+}
+
+// Create the fee bid transaction of the diamond, paid and signed by the fee account
+func (r *MiningResult) CreateBidTx(feeacc *account.Account, bidfee *fields.Amount, timestamp int64) (*transactions.Transaction_2_Simple, error) {
+	return transactions.CreateOneTxOfDiamondCreate(feeacc, r.CreateAction(), bidfee, timestamp)
+}
+
+type DiamondMiner struct {
+	device DiamondDevice
+
+	stopmark *uint32
+	hashrate uint64 // Tried nonce number of the last mining
+
+	lock sync.Mutex
+}
+
+func NewDiamondMiner(device DiamondDevice) *DiamondMiner {
+	return &DiamondMiner{
+		device: device,
+	}
+}
+
+// Mining the next diamond of the latest, block until found or stopped
+func (m *DiamondMiner) Excavate(latest *stores.DiamondSmelt, reward fields.Address, msg fields.Bytes32) (*MiningResult, error) {
+	stuff := NewMiningStuff(latest, reward, msg)
+	return m.ExcavateStuff(stuff, 0)
+}
+
+// Search nonce from nonceStart, return error if stopped
+func (m *DiamondMiner) ExcavateStuff(stuff *MiningStuff, nonceStart uint64) (*MiningResult, error) {
+	var stopmark uint32 = 0
+	m.lock.Lock()
+	if m.stopmark != nil {
+		m.lock.Unlock()
+		return nil, fmt.Errorf("Diamond miner is already mining.")
+	}
+	m.stopmark = &stopmark
+	m.hashrate = 0
+	m.lock.Unlock()
+	defer func() {
+		m.lock.Lock()
+		m.stopmark = nil
+		m.lock.Unlock()
+	}()
+	for nonce := nonceStart; ; nonce += MiningRoundNonceNumber {
+		end := nonce + MiningRoundNonceNumber
+		if end < nonce {
+			end = ^uint64(0) // Last round
+		}
+		success, resNonce, diamond, tried := m.device.DoMining(stuff, &stopmark, nonce, end)
+		m.lock.Lock()
+		m.hashrate += tried
+		m.lock.Unlock()
+		if success {
+			return &MiningResult{
+				Stuff:   stuff,
+				Nonce:   resNonce,
+				Diamond: diamond,
+			}, nil
+		}
+		if atomic.LoadUint32(&stopmark) == 1 {
+			return nil, fmt.Errorf("Diamond mining stopped.")
+		}
+		if end == ^uint64(0) {
+			return nil, fmt.Errorf("Diamond mining nonce exhausted.")
+		}
+	}
+}
+
+// Stop the mining, Excavate returns error
+func (m *DiamondMiner) StopMining() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.stopmark != nil {
+		atomic.StoreUint32(m.stopmark, 1)
+	}
+}
+
+// Tried nonce number of the current or last mining
+func (m *DiamondMiner) TriedNonceNumber() uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.hashrate
+}
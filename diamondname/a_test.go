@@ -0,0 +1,62 @@
+package diamondname
+
+import (
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/fields"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	diamond, e := ParseName("@WTYUIA")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e := ParseName("@WTYUI0"); e == nil {
+		t.Fatal("parse name error")
+	}
+
+	var record = func(key, value string) string {
+		content, e := CreateRecordContent(key, value)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if len(content) > 64 {
+			t.Fatal("record over one engraving", content)
+		}
+		return content
+	}
+	engravings := []string{
+		"hello",
+		record(RecordKeyPay, fields.Address(acc2.Address).ToReadable()),
+		record("url", "https://hacash.org"),
+		record("url", "https://hacash.com"),
+		record("name", "Alice, Bob: {x}"),
+	}
+	res, e := ResolveEngravings(diamond, acc1.Address, engravings)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !res.PayAddress.Equal(acc2.Address) || res.Records["url"] != "https://hacash.com" || res.Records["name"] != "Alice, Bob: {x}" {
+		t.Fatal("resolve error")
+	}
+	res, _ = ResolveEngravings(diamond, acc1.Address, engravings[:1])
+	if !res.PayAddress.Equal(acc1.Address) || len(res.Records) != 0 {
+		t.Fatal("resolve without record error")
+	}
+	if rcd, e := ParseRecordContent(engravings[2]); e != nil || rcd.Key != "url" || rcd.Value != "https://hacash.org" {
+		t.Fatal("parse record error")
+	}
+
+	// Pay record must be an address
+	if _, e := CreateRecordContent(RecordKeyPay, "not address"); e == nil {
+		t.Fatal("pay address check error")
+	}
+	if _, e := CreateRecordContent("a=b", "c"); e == nil {
+		t.Fatal("record key check error")
+	}
+
+}
@@ -0,0 +1,77 @@
+package diamondname
+
+import (
+	"fmt"
+	"github.com/hacash/core/engraving"
+	"github.com/hacash/core/fields"
+	"strings"
+)
+
+/**
+
+钻石名称服务：钻石的所有者用一条铭刻写入名称记录
+
+	{dns:<key>=<value>}          压缩字典格式
+
+. 共识只允许钻石当前的所有者铭刻，每个钻石 1000 个区块内只能铭刻一次
+. 解析时只接受当前所有者获得钻石之后的铭刻，由 diamondindexer 的转移与铭刻历史判断
+. 钻石转移后，旧所有者铭刻的记录被忽略，即使没有被清除
+. 同一个 key 以最后一条记录为准
+
+*/
+
+const (
+	RecordKeyPay = "pay" // Payment address
+
+	NamePrefix = "@"
+
+	recordDictKey = "dns"
+)
+
+type Record struct {
+	Key   string
+	Value string
+}
+
+// Parse "@WTYUIA" or "WTYUIA" to diamond name
+func ParseName(name string) (fields.DiamondName, error) {
+	name = strings.TrimPrefix(name, NamePrefix)
+	if !fields.IsDiamondValueString(name) {
+		return nil, fmt.Errorf("<%s> is not a diamond name.", name)
+	}
+	return fields.DiamondName(name), nil
+}
+
+// Create the engraving content of the record
+func CreateRecordContent(key string, value string) (string, error) {
+	if len(key) == 0 || strings.ContainsAny(key, "=") {
+		return "", fmt.Errorf("Record key cannot be empty or contains '='.")
+	}
+	if key == RecordKeyPay {
+		if _, e := fields.CheckReadableAddress(value); e != nil {
+			return "", fmt.Errorf("Record pay address <%s> error: %s", value, e)
+		}
+	}
+	return engraving.EncodeDict([]engraving.DictItem{
+		{Key: recordDictKey, Value: key + "=" + value},
+	})
+}
+
+// Parse the engraving content, return error if not a record
+func ParseRecordContent(content string) (*Record, error) {
+	if engraving.DetectType(content) != engraving.TypeCompressedDict {
+		return nil, fmt.Errorf("Engraving is not a dict.")
+	}
+	items, e := engraving.DecodeDict(content)
+	if e != nil {
+		return nil, e
+	}
+	if len(items) != 1 || items[0].Key != recordDictKey {
+		return nil, fmt.Errorf("Engraving is not a name record.")
+	}
+	kv := strings.SplitN(items[0].Value, "=", 2)
+	if len(kv) != 2 || len(kv[0]) == 0 {
+		return nil, fmt.Errorf("Name record format error.")
+	}
+	return &Record{kv[0], kv[1]}, nil
+}
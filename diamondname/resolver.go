@@ -0,0 +1,39 @@
+package diamondname
+
+import (
+	"github.com/hacash/core/fields"
+)
+
+type Resolution struct {
+	Diamond    fields.DiamondName
+	Owner      fields.Address
+	OwnerSince uint64            // Block height the owner got the diamond
+	PayAddress fields.Address    // The owner if no pay record
+	Records    map[string]string // Latest record of each key
+}
+
+// Resolve records from the engravings made by the current owner, in engraved order
+// Use diamondindexer to get the engravings after the owner got the diamond
+func ResolveEngravings(diamond fields.DiamondName, owner fields.Address, engravings []string) (*Resolution, error) {
+	res := &Resolution{
+		Diamond:    diamond,
+		Owner:      owner,
+		PayAddress: owner,
+		Records:    make(map[string]string),
+	}
+	for _, content := range engravings {
+		rcd, e := ParseRecordContent(content)
+		if e != nil {
+			continue // Not a record
+		}
+		res.Records[rcd.Key] = rcd.Value
+	}
+	if pay, ok := res.Records[RecordKeyPay]; ok {
+		addr, e := fields.CheckReadableAddress(pay)
+		if e != nil {
+			return nil, e
+		}
+		res.PayAddress = *addr
+	}
+	return res, nil
+}
@@ -0,0 +1,78 @@
+package diamondrarity
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	names := []string{"NHMYYM", "WTYUIA", "KBSZNE", "XVMEHA", "WWWWWW", "HXVMEK", "BSZNWT", "YUIAHX"}
+	smelts := make([]*stores.DiamondSmelt, 0)
+	for i, name := range names {
+		smelt := &stores.DiamondSmelt{
+			Diamond:            fields.DiamondName(name),
+			Number:             fields.DiamondNumber(i + 1),
+			ContainBlockHeight: fields.BlockHeight(5 + i*50000),
+			LifeGene:           fields.CalculateHash([]byte(name)),
+		}
+		smelt.ParseApproxFeeOffer(fields.NewAmountByUnitMei(int64(i + 1)))
+		smelts = append(smelts, smelt)
+	}
+	col, e := Analyze(smelts, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, d := range col.Diamonds {
+		fmt.Println(d.Rank, d.Name, d.Score, d.BidPercentile)
+	}
+	total := 0
+	for _, n := range col.LetterFrequency {
+		total += n
+	}
+	if total != len(names)*DiamondNameSize || col.LetterFrequency["W"] != 8 {
+		t.Fatal("letter frequency error")
+	}
+	for i := 1; i < len(col.Diamonds); i++ {
+		a, b := col.Diamonds[i-1], col.Diamonds[i]
+		if a.Rank != i || a.Score < b.Score || (a.Score == b.Score && a.Number > b.Number) {
+			t.Fatal("rarity rank error")
+		}
+	}
+	if col.Diamond("WWWWWW").LetterRepeat != 6 || col.TraitFrequency[TraitLetterRepeat]["6"] != 1 {
+		t.Fatal("letter repeat error")
+	}
+	if col.BidPercentiles["p50"] != fields.NewAmountByUnitMei(4).ToFinString() {
+		t.Fatal("bid percentile error")
+	}
+	if col.TraitFrequency[TraitHeightBand]["0"] != 2 {
+		t.Fatal("height band error")
+	}
+
+	// Stable with any input order
+	reversed := make([]*stores.DiamondSmelt, len(smelts))
+	for i := range smelts {
+		reversed[len(smelts)-1-i] = smelts[i]
+	}
+	col2, _ := Analyze(reversed, 0)
+	js1, _ := col.ToJSON()
+	js2, _ := col2.ToJSON()
+	if string(js1) != string(js2) {
+		t.Fatal("rarity not stable")
+	}
+	fmt.Println(string(js1[0:200]))
+	var data map[string]interface{}
+	if e := json.Unmarshal(js1, &data); e != nil || data["count"].(float64) != 8 {
+		t.Fatal("json error")
+	}
+
+	// Name check
+	smelts[0].Diamond = fields.DiamondName("ABCDEF")
+	if _, e := Analyze(smelts, 0); e == nil {
+		t.Fatal("diamond name check error")
+	}
+
+}
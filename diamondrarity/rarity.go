@@ -0,0 +1,127 @@
+package diamondrarity
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hacash/core/diamondbid"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"math"
+	"sort"
+)
+
+/**
+
+钻石稀有度：
+
+. 字母频率：16 个字母在所有钻石字面值中出现的次数
+. 特征频率：形状、背景、主色、颜色数、重复字母数、铸造高度段
+. 稀有度分数 = 各特征值及 6 个字母的信息量之和：-log2(频率)
+. 分数保留 6 位小数，排名按分数从高到低，分数相同则编号小的在前
+
+分数只与统计的钻石集合有关，与输入顺序无关
+
+*/
+
+const scorePrecision = 1000000
+
+type DiamondRarity struct {
+	*DiamondTraits
+	Score         float64 `json:"score"`
+	Rank          int     `json:"rank"`           // From 1
+	BidPercentile float64 `json:"bid_percentile"` // Ratio of bids not more than it
+}
+
+type Collection struct {
+	Count           int                       `json:"count"`
+	LetterFrequency map[string]int            `json:"letter_frequency"`
+	TraitFrequency  map[string]map[string]int `json:"trait_frequency"`
+	BidPercentiles  map[string]string         `json:"bid_percentiles"`
+	Diamonds        []*DiamondRarity          `json:"diamonds"` // Sorted by rank
+
+	byName map[string]*DiamondRarity
+}
+
+// Bid percentiles in the statistics, such as "p50"
+var BidPercentilePoints = []int{10, 25, 50, 75, 90}
+
+func Analyze(smelts []*stores.DiamondSmelt, bandSize uint64) (*Collection, error) {
+	col := &Collection{
+		Count:           len(smelts),
+		LetterFrequency: make(map[string]int),
+		TraitFrequency:  make(map[string]map[string]int),
+		BidPercentiles:  make(map[string]string),
+		Diamonds:        make([]*DiamondRarity, 0, len(smelts)),
+		byName:          make(map[string]*DiamondRarity),
+	}
+	for i := 0; i < len(Alphabet); i++ {
+		col.LetterFrequency[Alphabet[i:i+1]] = 0
+	}
+	for _, name := range TraitNames {
+		col.TraitFrequency[name] = make(map[string]int)
+	}
+	bids := make([]*fields.Amount, 0, len(smelts))
+	for _, smelt := range smelts {
+		traits, e := ExtractTraits(smelt, bandSize)
+		if e != nil {
+			return nil, e
+		}
+		for i := 0; i < len(traits.Name); i++ {
+			col.LetterFrequency[traits.Name[i:i+1]]++
+		}
+		for _, name := range TraitNames {
+			col.TraitFrequency[name][traits.TraitValue(name)]++
+		}
+		bids = append(bids, smelt.GetApproxFeeOffer())
+		rarity := &DiamondRarity{DiamondTraits: traits}
+		col.Diamonds = append(col.Diamonds, rarity)
+		col.byName[traits.Name] = rarity
+	}
+	// Bids
+	bidstats := diamondbid.NewBidStats(bids)
+	for _, p := range BidPercentilePoints {
+		col.BidPercentiles[fmt.Sprintf("p%d", p)] = bidstats.Percentile(float64(p) / 100).ToFinString()
+	}
+	// Score
+	for i, rarity := range col.Diamonds {
+		rarity.Score = col.score(rarity.DiamondTraits)
+		rarity.BidPercentile = bidstats.WinRatio(bids[i])
+	}
+	sort.SliceStable(col.Diamonds, func(i, j int) bool {
+		a, b := col.Diamonds[i], col.Diamonds[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Number < b.Number
+	})
+	for i, rarity := range col.Diamonds {
+		rarity.Rank = i + 1
+	}
+	return col, nil
+}
+
+func (col *Collection) score(traits *DiamondTraits) float64 {
+	var score float64 = 0
+	letters := float64(col.Count * DiamondNameSize)
+	for i := 0; i < len(traits.Name); i++ {
+		score -= math.Log2(float64(col.LetterFrequency[traits.Name[i:i+1]]) / letters)
+	}
+	for _, name := range TraitNames {
+		freq := col.TraitFrequency[name][traits.TraitValue(name)]
+		score -= math.Log2(float64(freq) / float64(col.Count))
+	}
+	return math.Round(score*scorePrecision) / scorePrecision
+}
+
+// Query by diamond name, return nil if not in the collection
+func (col *Collection) Diamond(name string) *DiamondRarity {
+	return col.byName[name]
+}
+
+func (col *Collection) ToJSON() ([]byte, error) {
+	return json.Marshal(col)
+}
+
+func (rarity *DiamondRarity) ToJSON() ([]byte, error) {
+	return json.Marshal(rarity)
+}
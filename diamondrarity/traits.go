@@ -0,0 +1,119 @@
+package diamondrarity
+
+import (
+	"fmt"
+	"github.com/hacash/core/diamondvisual"
+	"github.com/hacash/core/stores"
+	"strings"
+)
+
+// Diamond literal alphabet
+const Alphabet = "WTYUIAHXVMEKBSZN"
+
+const (
+	DiamondNameSize = 6
+
+	DefaultHeightBandSize uint64 = 100000 // About one year
+)
+
+// Trait names, also the keys of the trait frequency
+const (
+	TraitShape          = "shape"
+	TraitBackground     = "background"
+	TraitDominantColor  = "dominant_color"
+	TraitDistinctColors = "distinct_colors"
+	TraitLetterRepeat   = "letter_repeat"
+	TraitHeightBand     = "height_band"
+)
+
+// Scored traits in fixed order
+var TraitNames = []string{
+	TraitShape,
+	TraitBackground,
+	TraitDominantColor,
+	TraitDistinctColors,
+	TraitLetterRepeat,
+	TraitHeightBand,
+}
+
+type DiamondTraits struct {
+	Name        string `json:"name"`
+	Number      uint32 `json:"number"`
+	BlockHeight uint64 `json:"block_height"`
+	Bid         string `json:"bid"`
+
+	Shape          uint8  `json:"shape"`
+	Background     uint8  `json:"background"`
+	DominantColor  uint8  `json:"dominant_color"`  // Most used facet color, the smaller index if tie
+	DistinctColors uint8  `json:"distinct_colors"` // Number of different facet colors
+	LetterRepeat   uint8  `json:"letter_repeat"`   // Max count of the same letter
+	HeightBand     uint32 `json:"height_band"`
+}
+
+// Value of the trait as the frequency key
+func (t *DiamondTraits) TraitValue(name string) string {
+	var v uint64
+	switch name {
+	case TraitShape:
+		v = uint64(t.Shape)
+	case TraitBackground:
+		v = uint64(t.Background)
+	case TraitDominantColor:
+		v = uint64(t.DominantColor)
+	case TraitDistinctColors:
+		v = uint64(t.DistinctColors)
+	case TraitLetterRepeat:
+		v = uint64(t.LetterRepeat)
+	case TraitHeightBand:
+		v = uint64(t.HeightBand)
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+func ExtractTraits(smelt *stores.DiamondSmelt, bandSize uint64) (*DiamondTraits, error) {
+	name := smelt.Diamond.Name()
+	if len(name) != DiamondNameSize || strings.Trim(name, Alphabet) != "" {
+		return nil, fmt.Errorf("Diamond name <%s> error.", name)
+	}
+	if bandSize == 0 {
+		bandSize = DefaultHeightBandSize
+	}
+	gene := smelt.GetVisualGene()
+	if gene == nil {
+		return nil, fmt.Errorf("Diamond <%s> visual gene error.", name)
+	}
+	vis, e := diamondvisual.ParseVisualGeneIndex(gene)
+	if e != nil {
+		return nil, e
+	}
+	traits := &DiamondTraits{
+		Name:        name,
+		Number:      uint32(smelt.Number),
+		BlockHeight: uint64(smelt.ContainBlockHeight),
+		Bid:         smelt.GetApproxFeeOffer().ToFinString(),
+		Shape:       vis.Shape,
+		Background:  vis.Background,
+		HeightBand:  uint32(uint64(smelt.ContainBlockHeight) / bandSize),
+	}
+	// Facet colors
+	var colors [16]int
+	for _, c := range vis.Facets {
+		colors[c]++
+	}
+	for i, n := range colors {
+		if n > 0 {
+			traits.DistinctColors++
+		}
+		if n > colors[traits.DominantColor] {
+			traits.DominantColor = uint8(i)
+		}
+	}
+	// Letters
+	for i := 0; i < len(name); i++ {
+		n := uint8(strings.Count(name, name[i:i+1]))
+		if n > traits.LetterRepeat {
+			traits.LetterRepeat = n
+		}
+	}
+	return traits, nil
+}
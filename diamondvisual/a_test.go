@@ -0,0 +1,176 @@
+package diamondvisual
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test ./diamondvisual -update
+var updateGolden = flag.Bool("update", false, "update golden images in testdata")
+
+// Synthetic fixtures: valid diamond literals with hand-picked LifeGenes
+// covering all shapes and extreme gene values, NOT the on-chain data of these diamonds
+var syntheticDiamonds = []struct {
+	Name     string
+	LifeGene string
+}{
+	{"NHMYYM", "0cb6e1ab2cd6c4ebbc1ce2f4b3d1c5d2f8fdd4f3a32a8eb8b1fc0c52f1bb8c00"},
+	{"WTYUIA", "7c1bd44a3e8a0f9cd2e77b6e1b0de2ab90a6c3f7d58e61b4a0e7a9f32c1d6b41"},
+	{"KBSZNE", "ffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221102"},
+	{"XVMEHA", "0000000000000000000000000000000000000000123456789abcdef012345603"},
+}
+
+func goldenGene(t *testing.T, name string, lifegene string) []byte {
+	hx, _ := hex.DecodeString(lifegene)
+	smelt := &stores.DiamondSmelt{
+		Diamond:  fields.DiamondName(name),
+		LifeGene: hx,
+	}
+	gene := smelt.GetVisualGene()
+	if len(gene) != VisualGeneSize {
+		t.Fatal("visual gene error", name)
+	}
+	return gene
+}
+
+func checkGolden(t *testing.T, file string, data []byte, equal func(a, b []byte) bool) {
+	path := filepath.Join("testdata", file)
+	if *updateGolden {
+		if e := ioutil.WriteFile(path, data, 0644); e != nil {
+			t.Fatal(e)
+		}
+		return
+	}
+	golden, e := ioutil.ReadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !equal(golden, data) {
+		t.Fatalf("%s not match the golden image", file)
+	}
+}
+
+// Compare pixels, the encoded bytes may differ between versions of the png encoder
+func equalPNG(a, b []byte) bool {
+	img1, e1 := png.Decode(bytes.NewReader(a))
+	img2, e2 := png.Decode(bytes.NewReader(b))
+	if e1 != nil || e2 != nil || img1.Bounds() != img2.Bounds() {
+		return false
+	}
+	rect := img1.Bounds()
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r1, g1, b1, a1 := img1.At(x, y).RGBA()
+			r2, g2, b2, a2 := img2.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func Test1(t *testing.T) {
+
+	for _, dia := range syntheticDiamonds {
+		gene := goldenGene(t, dia.Name, dia.LifeGene)
+		vis, _ := ParseVisualGene(gene)
+		fmt.Println(dia.Name, hex.EncodeToString(gene), Shapes[vis.Shape].Name)
+		svg, e := RenderSVG(gene, 256)
+		if e != nil {
+			t.Fatal(e)
+		}
+		checkGolden(t, "synthetic_"+dia.Name+".svg", svg, bytes.Equal)
+		pngbts, e := RenderPNG(gene, 64)
+		if e != nil {
+			t.Fatal(e)
+		}
+		checkGolden(t, "synthetic_"+dia.Name+".png", pngbts, equalPNG)
+	}
+
+}
+
+func Test2(t *testing.T) {
+
+	// Deterministic
+	gene := goldenGene(t, syntheticDiamonds[0].Name, syntheticDiamonds[0].LifeGene)
+	svg1, _ := RenderSVG(gene, 100)
+	svg2, _ := RenderSVG(gene, 100)
+	if !bytes.Equal(svg1, svg2) {
+		t.Fatal("render is not deterministic")
+	}
+
+	// Gene size
+	if _, e := ParseVisualGene(gene[1:]); e == nil {
+		t.Fatal("gene size check error")
+	}
+
+	// Facets cover the canvas center
+	vis, _ := ParseVisualGene(gene)
+	img := vis.Image(100)
+	if img.RGBAAt(50, 60) == vis.Background {
+		t.Fatal("facet raster error")
+	}
+	if img.RGBAAt(1, 1) != vis.Background {
+		t.Fatal("background raster error")
+	}
+
+}
+
+// Real mainnet diamonds, one "NAME LIFEGENEHEX" per line, read from a full node
+// Render with -update once to create the golden images, wallets compare against them
+func Test3(t *testing.T) {
+
+	content, e := ioutil.ReadFile(filepath.Join("testdata", "mainnet_diamonds.txt"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	count := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		item := strings.Fields(line)
+		if len(item) == 2 && item[0] == "smelt" {
+			// Stored stores.DiamondSmelt exported from a full node
+			bts, e := hex.DecodeString(item[1])
+			if e != nil {
+				t.Fatal(e)
+			}
+			smelt := &stores.DiamondSmelt{}
+			if _, e := smelt.Parse(bts, 0); e != nil {
+				t.Fatal(e)
+			}
+			item = []string{string(smelt.Diamond), smelt.LifeGene.ToHex()}
+		}
+		if len(item) != 2 || len(item[1]) != 64 {
+			t.Fatal("mainnet diamond line format error:", line)
+		}
+		count++
+		gene := goldenGene(t, item[0], item[1])
+		svg, e := RenderSVG(gene, 256)
+		if e != nil {
+			t.Fatal(e)
+		}
+		checkGolden(t, "mainnet_"+item[0]+".svg", svg, bytes.Equal)
+		pngbts, e := RenderPNG(gene, 64)
+		if e != nil {
+			t.Fatal(e)
+		}
+		checkGolden(t, "mainnet_"+item[0]+".png", pngbts, equalPNG)
+	}
+	if count == 0 {
+		t.Skip("no mainnet diamond in testdata/mainnet_diamonds.txt")
+	}
+
+}
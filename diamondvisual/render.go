@@ -0,0 +1,112 @@
+package diamondvisual
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+func colorHex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Quarter canvas units to decimal string
+func quarterString(v int64) string {
+	return fmt.Sprintf("%d.%02d", v/4, v%4*25)
+}
+
+// Render as SVG, width and height are size pixels
+func (v *Visual) SVG(size int) []byte {
+	shape := Shapes[v.Shape]
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		size, size, CanvasSize, CanvasSize))
+	buf.WriteString("\n")
+	buf.WriteString(fmt.Sprintf(`<rect width="%d" height="%d" fill="%s"/>`, CanvasSize, CanvasSize, colorHex(v.Background)))
+	buf.WriteString("\n")
+	for i, facet := range shape.Facets() {
+		points := make([]string, len(facet))
+		for k, p := range facet {
+			points[k] = quarterString(p.X) + "," + quarterString(p.Y)
+		}
+		buf.WriteString(fmt.Sprintf(`<polygon points="%s" fill="%s" stroke="%s" stroke-width="0.5" stroke-linejoin="round"/>`,
+			strings.Join(points, " "), colorHex(v.Facets[i]), colorHex(OutlineColor)))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// Rasterize, pixels on the border of two facets (or a facet and the background) are outlined
+func (v *Visual) Image(size int) *image.RGBA {
+	shape := Shapes[v.Shape]
+	facets := shape.Facets()
+	// Pixel center (px+0.5)*CanvasSize/size in quarter units, scaled by 2*size
+	scale := int64(2 * size)
+	index := make([]int, size*size)
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			p := point{int64(2*px+1) * CanvasSize * 4, int64(2*py+1) * CanvasSize * 4}
+			idx := -1
+			for i, facet := range facets {
+				if insideConvex(facet, p, scale) {
+					idx = i
+					break
+				}
+			}
+			index[py*size+px] = idx
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			idx := index[py*size+px]
+			isBorder := false
+			if px+1 < size && index[py*size+px+1] != idx {
+				isBorder = true
+			}
+			if py+1 < size && index[(py+1)*size+px] != idx {
+				isBorder = true
+			}
+			c := v.Background
+			if isBorder {
+				c = OutlineColor
+			} else if idx >= 0 {
+				c = v.Facets[idx]
+			}
+			img.SetRGBA(px, py, c)
+		}
+	}
+	return img
+}
+
+// Render as PNG
+func (v *Visual) PNG(size int) ([]byte, error) {
+	var buf bytes.Buffer
+	e := png.Encode(&buf, v.Image(size))
+	if e != nil {
+		return nil, e
+	}
+	return buf.Bytes(), nil
+}
+
+// Render the 10 bytes visual gene as SVG
+func RenderSVG(gene []byte, size int) ([]byte, error) {
+	vis, e := ParseVisualGene(gene)
+	if e != nil {
+		return nil, e
+	}
+	return vis.SVG(size), nil
+}
+
+// Render the 10 bytes visual gene as PNG
+func RenderPNG(gene []byte, size int) ([]byte, error) {
+	vis, e := ParseVisualGene(gene)
+	if e != nil {
+		return nil, e
+	}
+	return vis.PNG(size)
+}
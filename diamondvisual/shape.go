@@ -0,0 +1,80 @@
+package diamondvisual
+
+/**
+
+形状：在 100 x 100 的画布中，由台面、腰线和底尖确定
+
+. 台面（TableY）和腰线（GirdleY）各等分 8 段，得到 9 个点，相邻点构成冠部 8 个四边形
+. 腰线 8 段与底尖（CuletY）构成亭部 8 个三角形
+
+所有参数为整数，顶点坐标均为 1/4 的整数倍，渲染只使用整数运算，保证各平台结果一致
+
+*/
+
+const (
+	CanvasSize = 100
+	segments   = FacetCount / 2
+)
+
+// Outline parameters of a diamond shape, canvas units
+type Shape struct {
+	Name       string
+	TableHalf  int64 // Half width of the table
+	TableY     int64
+	GirdleHalf int64 // Half width of the girdle
+	GirdleY    int64
+	CuletY     int64
+}
+
+// Selected by the first byte of gene mod ShapeCount
+var Shapes = [ShapeCount]Shape{
+	{Name: "brilliant", TableHalf: 24, TableY: 20, GirdleHalf: 46, GirdleY: 40, CuletY: 92},
+	{Name: "wide", TableHalf: 32, TableY: 22, GirdleHalf: 47, GirdleY: 38, CuletY: 86},
+	{Name: "tall", TableHalf: 18, TableY: 12, GirdleHalf: 42, GirdleY: 44, CuletY: 94},
+	{Name: "flat", TableHalf: 28, TableY: 28, GirdleHalf: 48, GirdleY: 42, CuletY: 84},
+}
+
+// Point in quarter canvas units
+type point struct {
+	X, Y int64
+}
+
+// All facet polygons, crown quads first then pavilion triangles, from left to right
+func (s *Shape) Facets() [][]point {
+	const center = CanvasSize / 2 * 4
+	table := make([]point, segments+1)
+	girdle := make([]point, segments+1)
+	for i := int64(0); i <= segments; i++ {
+		// 4 * (center - half + 2 * half * i / 8)
+		table[i] = point{center - 4*s.TableHalf + s.TableHalf*i, 4 * s.TableY}
+		girdle[i] = point{center - 4*s.GirdleHalf + s.GirdleHalf*i, 4 * s.GirdleY}
+	}
+	culet := point{center, 4 * s.CuletY}
+	facets := make([][]point, 0, FacetCount)
+	for i := 0; i < segments; i++ {
+		facets = append(facets, []point{table[i], table[i+1], girdle[i+1], girdle[i]})
+	}
+	for i := 0; i < segments; i++ {
+		facets = append(facets, []point{girdle[i], girdle[i+1], culet})
+	}
+	return facets
+}
+
+// Whether the point is inside or on the edge of the convex polygon
+func insideConvex(poly []point, p point, scale int64) bool {
+	var hasPos, hasNeg bool
+	n := len(poly)
+	for i := 0; i < n; i++ {
+		a, b := poly[i], poly[(i+1)%n]
+		cross := (b.X-a.X)*scale*(p.Y-a.Y*scale) - (b.Y-a.Y)*scale*(p.X-a.X*scale)
+		if cross > 0 {
+			hasPos = true
+		} else if cross < 0 {
+			hasNeg = true
+		}
+		if hasPos && hasNeg {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package diamondvisual
+
+import (
+	"fmt"
+	"github.com/hacash/core/stores"
+	"image/color"
+)
+
+/**
+
+钻石外观渲染：将 DiamondSmelt.GetVisualGene 的 10 字节基因转换为图像
+
+基因格式（10 字节）：
+. 第 1 字节：形状选择，取值 mod ShapeCount
+. 后 9 字节共 18 个半字节（高位在前）：
+    第 0~5 个来自钻石字面值（WTYUIAHXVMEKBSZN 对应 0~F）
+    第 6~16 个来自 LifeGene
+    第 17 个固定为 0，不使用
+. 第 0~15 个半字节依次为 16 个切面的颜色（Palette），第 16 个为背景色（BackgroundPalette）
+
+切面顺序：冠部 8 个四边形从左至右，然后亭部 8 个三角形从左至右
+
+*/
+
+const (
+	VisualGeneSize = 10
+	FacetCount     = 16 // 8 crown + 8 pavilion
+	ShapeCount     = 4
+)
+
+// Facet colors, indexed by a half byte of the gene
+var Palette = [16]color.RGBA{
+	{0xF5, 0xF5, 0xF5, 0xFF}, // 0 white
+	{0xC0, 0xC0, 0xC0, 0xFF}, // 1 silver
+	{0x80, 0x80, 0x80, 0xFF}, // 2 gray
+	{0x33, 0x33, 0x33, 0xFF}, // 3 black
+	{0xE5, 0x39, 0x35, 0xFF}, // 4 red
+	{0xF4, 0x8F, 0xB1, 0xFF}, // 5 pink
+	{0xFB, 0x8C, 0x00, 0xFF}, // 6 orange
+	{0xFD, 0xD8, 0x35, 0xFF}, // 7 yellow
+	{0xC0, 0xCA, 0x33, 0xFF}, // 8 lime
+	{0x43, 0xA0, 0x47, 0xFF}, // 9 green
+	{0x00, 0x89, 0x7B, 0xFF}, // A teal
+	{0x00, 0xAC, 0xC1, 0xFF}, // B cyan
+	{0x1E, 0x88, 0xE5, 0xFF}, // C blue
+	{0x39, 0x49, 0xAB, 0xFF}, // D indigo
+	{0x8E, 0x24, 0xAA, 0xFF}, // E purple
+	{0x6D, 0x4C, 0x41, 0xFF}, // F brown
+}
+
+// Background colors, indexed by the 17th half byte of the gene
+var BackgroundPalette = [16]color.RGBA{
+	{0xFF, 0xFF, 0xFF, 0xFF},
+	{0xFA, 0xFA, 0xFA, 0xFF},
+	{0xEC, 0xEF, 0xF1, 0xFF},
+	{0xFF, 0xEB, 0xEE, 0xFF},
+	{0xFC, 0xE4, 0xEC, 0xFF},
+	{0xF3, 0xE5, 0xF5, 0xFF},
+	{0xED, 0xE7, 0xF6, 0xFF},
+	{0xE8, 0xEA, 0xF6, 0xFF},
+	{0xE3, 0xF2, 0xFD, 0xFF},
+	{0xE1, 0xF5, 0xFE, 0xFF},
+	{0xE0, 0xF7, 0xFA, 0xFF},
+	{0xE0, 0xF2, 0xF1, 0xFF},
+	{0xE8, 0xF5, 0xE9, 0xFF},
+	{0xF1, 0xF8, 0xE9, 0xFF},
+	{0xFF, 0xFD, 0xE7, 0xFF},
+	{0xFF, 0xF3, 0xE0, 0xFF},
+}
+
+// Outline color of all facets
+var OutlineColor = color.RGBA{0x26, 0x32, 0x38, 0xFF}
+
+// Decoded visual gene
+type Visual struct {
+	Shape      uint8
+	Facets     [FacetCount]color.RGBA
+	Background color.RGBA
+}
+
+// Half byte at index, high bits first
+func geneHalfByte(gene []byte, index int) uint8 {
+	b := gene[1+index/2]
+	if index%2 == 0 {
+		return b >> 4
+	}
+	return b & 0x0F
+}
+
+// Palette indexes of the gene
+type VisualIndex struct {
+	Shape      uint8
+	Facets     [FacetCount]uint8
+	Background uint8
+}
+
+// Decode the 10 bytes visual gene to palette indexes
+func ParseVisualGeneIndex(gene []byte) (*VisualIndex, error) {
+	if len(gene) != VisualGeneSize {
+		return nil, fmt.Errorf("Visual gene size must be %d but got %d.", VisualGeneSize, len(gene))
+	}
+	idx := &VisualIndex{
+		Shape: gene[0] % ShapeCount,
+	}
+	for i := 0; i < FacetCount; i++ {
+		idx.Facets[i] = geneHalfByte(gene, i)
+	}
+	idx.Background = geneHalfByte(gene, FacetCount)
+	return idx, nil
+}
+
+// Decode the 10 bytes visual gene
+func ParseVisualGene(gene []byte) (*Visual, error) {
+	idx, e := ParseVisualGeneIndex(gene)
+	if e != nil {
+		return nil, e
+	}
+	vis := &Visual{
+		Shape: idx.Shape,
+	}
+	for i := 0; i < FacetCount; i++ {
+		vis.Facets[i] = Palette[idx.Facets[i]]
+	}
+	vis.Background = BackgroundPalette[idx.Background]
+	return vis, nil
+}
+
+// Decode the visual gene of the diamond
+func ParseDiamondSmelt(smelt *stores.DiamondSmelt) (*Visual, error) {
+	gene := smelt.GetVisualGene()
+	if gene == nil {
+		return nil, fmt.Errorf("Diamond <%s> visual gene error.", smelt.Diamond.Name())
+	}
+	return ParseVisualGene(gene)
+}
@@ -0,0 +1,108 @@
+package engraving
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	// Dict encode and decode
+	content, e := EncodeDict([]DictItem{
+		{"name", "Blue {moon}"},
+		{"url", "https://hacash.org"},
+		{"color", "a,b:c"},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(content)
+	if content != `{n:Blue \{moon\},u:https\://hacash.org,color:a\,b\:c}` {
+		t.Fatal("dict encode error")
+	}
+	if DetectType(content) != TypeCompressedDict {
+		t.Fatal("dict detect error")
+	}
+	items, e := DecodeDict(content)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(items) != 3 || items[0].Key != "name" || items[0].Value != "Blue {moon}" || items[2].Value != "a,b:c" {
+		t.Fatal("dict decode error")
+	}
+
+	// Sorted keys
+	content, _ = EncodeDictMap(map[string]string{"url": "x", "author": "y"})
+	if content != "{a:y,u:x}" {
+		t.Fatal("dict map encode error", content)
+	}
+
+	// Unknown abbreviation and too long
+	if _, e := EncodeDict([]DictItem{{"z", "1"}}); e == nil {
+		t.Fatal("dict key check error")
+	}
+	if _, e := EncodeDict([]DictItem{{"name", string(bytes.Repeat([]byte("a"), 64))}}); e == nil {
+		t.Fatal("dict size check error")
+	}
+
+	// Not a valid dict, decoded as string
+	if DetectType("{z:1}") != TypeString || DetectType("hello world") != TypeString {
+		t.Fatal("string detect error")
+	}
+
+}
+
+func Test2(t *testing.T) {
+
+	file := []byte("hacash diamond certificate")
+
+	md5c, e := CreateFileCommitment(TypeMD5, bytes.NewReader(file))
+	if e != nil {
+		t.Fatal(e)
+	}
+	sha256c, e := CreateFileCommitment(TypeSHA256, bytes.NewReader(file))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if DetectType(md5c) != TypeMD5 || DetectType(sha256c) != TypeSHA256 {
+		t.Fatal("hash detect error")
+	}
+	for _, c := range []string{md5c, sha256c} {
+		ok, e := VerifyFileCommitment(c, bytes.NewReader(file))
+		if e != nil || !ok {
+			t.Fatal("verify file error")
+		}
+		ok, _ = VerifyFileCommitment(c, bytes.NewReader([]byte("other")))
+		if ok {
+			t.Fatal("verify other file error")
+		}
+	}
+	if _, e := VerifyFileCommitment("hello", bytes.NewReader(file)); e == nil {
+		t.Fatal("verify string error")
+	}
+
+	// Typed decode of diamond engravings
+	dia := stores.NewDiamond(fields.Address(make([]byte, 21)))
+	for _, c := range []string{"hello", "{n:HACD}", md5c, sha256c, "\x00\x01"} {
+		dia.EngravedContents.Count += 1
+		dia.EngravedContents.Lists = append(dia.EngravedContents.Lists, fields.CreateStringMax255(c))
+	}
+	entries := DecodeDiamondEngravings(dia)
+	types := []uint8{TypeString, TypeCompressedDict, TypeMD5, TypeSHA256, TypeUnknown}
+	for i, en := range entries {
+		fmt.Println(en.Index, en.TypeName, en.Value)
+		if en.Type != types[i] || en.Index != i {
+			t.Fatal("diamond engravings decode error", i)
+		}
+	}
+	if entries[2].Value.(*HashCommitment).ToHex() != fmt.Sprintf("%x", md5c) {
+		t.Fatal("hash commitment decode error")
+	}
+	if entries[4].Value != "0001" {
+		t.Fatal("unknown decode error")
+	}
+
+}
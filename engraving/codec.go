@@ -0,0 +1,161 @@
+package engraving
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/hacash/core/stores"
+)
+
+/**
+
+铭刻内容编解码
+
+Action_32_DiamondsEngraved 的 EngravedType 不保存在 stores.Diamond 中，
+解码时按注册顺序依次检测内容格式：
+
+. 1  CompressedDict  可见字符 {k:v,k:v}，键可使用字典缩写
+. 0  String          可见字符
+. 51 MD5             16 字节文件哈希承诺
+. 52 SHA256          32 字节文件哈希承诺
+
+*/
+
+const (
+	TypeString         uint8 = 0
+	TypeCompressedDict uint8 = 1
+	TypeMD5            uint8 = 51
+	TypeSHA256         uint8 = 52
+	TypeUnknown        uint8 = 255 // Not registered, decoded as hex
+
+	MaxContentSize = 64 // Same as Action_32_DiamondsEngraved
+)
+
+// Engraving content codec of one type
+type Codec interface {
+	Type() uint8
+	Name() string
+	Check(content string) error // Whether the content is valid for this type
+	Decode(content string) (interface{}, error)
+}
+
+// Detection order
+var registry = []Codec{}
+
+// Register a codec, the later registered is detected later
+func Register(codec Codec) error {
+	for _, c := range registry {
+		if c.Type() == codec.Type() {
+			return fmt.Errorf("Engraving codec type %d already registered.", codec.Type())
+		}
+	}
+	registry = append(registry, codec)
+	return nil
+}
+
+func GetCodec(typ uint8) Codec {
+	for _, c := range registry {
+		if c.Type() == typ {
+			return c
+		}
+	}
+	return nil
+}
+
+func init() {
+	Register(&DictCodec{})
+	Register(&StringCodec{})
+	Register(&HashCodec{TypeMD5})
+	Register(&HashCodec{TypeSHA256})
+}
+
+// Detect the type of content, return TypeUnknown if no codec match
+func DetectType(content string) uint8 {
+	for _, c := range registry {
+		if c.Check(content) == nil {
+			return c.Type()
+		}
+	}
+	return TypeUnknown
+}
+
+// One decoded engraving
+type Entry struct {
+	Index    int
+	Type     uint8
+	TypeName string
+	Content  string      // Raw content
+	Value    interface{} // string, []DictItem, *HashCommitment or hex string if unknown
+}
+
+// Decode content by the detected type
+func Decode(content string) *Entry {
+	for _, c := range registry {
+		if c.Check(content) != nil {
+			continue
+		}
+		value, e := c.Decode(content)
+		if e != nil {
+			continue
+		}
+		return &Entry{
+			Type:     c.Type(),
+			TypeName: c.Name(),
+			Content:  content,
+			Value:    value,
+		}
+	}
+	return &Entry{
+		Type:     TypeUnknown,
+		TypeName: "Unknown",
+		Content:  content,
+		Value:    hex.EncodeToString([]byte(content)),
+	}
+}
+
+// Decode all engravings of the diamond, in engraved order
+func DecodeDiamondEngravings(dia *stores.Diamond) []*Entry {
+	list := dia.EngravedContents.Lists
+	entries := make([]*Entry, len(list))
+	for i := 0; i < len(list); i++ {
+		entries[i] = Decode(list[i].Str)
+		entries[i].Index = i
+	}
+	return entries
+}
+
+////////////////////////////////////////////////////////
+
+// Type 0, visible string
+type StringCodec struct{}
+
+func (*StringCodec) Type() uint8 {
+	return TypeString
+}
+
+func (*StringCodec) Name() string {
+	return "String"
+}
+
+func (*StringCodec) Check(content string) error {
+	if len(content) == 0 || len(content) > MaxContentSize {
+		return fmt.Errorf("Engraving content size must between 1 and %d.", MaxContentSize)
+	}
+	if !isVisible(content) {
+		return fmt.Errorf("Engraving content must be visible string.")
+	}
+	return nil
+}
+
+func (*StringCodec) Decode(content string) (interface{}, error) {
+	return content, nil
+}
+
+// Same as fields.IsValidVisibleString
+func isVisible(content string) bool {
+	for i := 0; i < len(content); i++ {
+		if content[i] < 32 || content[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package engraving
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/**
+
+压缩字典格式（类型 1）：
+
+	{k:v,k:v}
+
+. 单字符的键为字典缩写，必须在 DictKeys 中；两个及以上字符的键为原文
+. 键和值中的 \ , : { } 使用 \ 转义
+. DictKeys 只可追加，不可修改
+
+*/
+
+// Abbreviation of common keys
+var DictKeys = map[string]string{
+	"n": "name",
+	"d": "description",
+	"u": "url",
+	"a": "author",
+	"o": "owner",
+	"t": "time",
+	"h": "height",
+	"c": "collection",
+	"i": "id",
+	"s": "serial",
+	"l": "license",
+	"m": "mime",
+	"f": "file",
+	"x": "hash",
+	"v": "version",
+	"r": "rarity",
+	"g": "tag",
+	"p": "price",
+	"e": "edition",
+}
+
+// Key of full name to abbreviation
+var dictKeysReverse = map[string]string{}
+
+func init() {
+	for k, v := range DictKeys {
+		dictKeysReverse[v] = k
+	}
+}
+
+type DictItem struct {
+	Key   string // Full name
+	Value string
+}
+
+func escapeDict(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\', ',', ':', '{', '}':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Encode the items in order, keys in DictKeys are abbreviated
+func EncodeDict(items []DictItem) (string, error) {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		key := item.Key
+		if abbr, ok := dictKeysReverse[key]; ok {
+			key = abbr
+		} else if len(key) < 2 {
+			return "", fmt.Errorf("Dict key '%s' must be in DictKeys or over 1 size.", key)
+		}
+		parts[i] = escapeDict(key) + ":" + escapeDict(item.Value)
+	}
+	content := "{" + strings.Join(parts, ",") + "}"
+	if e := (&DictCodec{}).Check(content); e != nil {
+		return "", e
+	}
+	return content, nil
+}
+
+// Encode the map with sorted keys
+func EncodeDictMap(dict map[string]string) (string, error) {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]DictItem, len(keys))
+	for i, k := range keys {
+		items[i] = DictItem{k, dict[k]}
+	}
+	return EncodeDict(items)
+}
+
+// Split by unescaped separator
+func splitDict(s string, sep byte) ([]string, error) {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' {
+			if i+1 >= len(s) {
+				return nil, fmt.Errorf("Dict escape at the end.")
+			}
+			cur.WriteByte(c)
+			cur.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if c == '{' || c == '}' {
+			return nil, fmt.Errorf("Dict char '%c' must be escaped.", c)
+		}
+		if c == sep {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	parts = append(parts, cur.String())
+	return parts, nil
+}
+
+func unescapeDict(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func DecodeDict(content string) ([]DictItem, error) {
+	n := len(content)
+	if n < 2 || content[0] != '{' || content[n-1] != '}' {
+		return nil, fmt.Errorf("Dict must be wrapped by {}.")
+	}
+	body := content[1 : n-1]
+	if len(body) == 0 {
+		return []DictItem{}, nil
+	}
+	pairs, e := splitDict(body, ',')
+	if e != nil {
+		return nil, e
+	}
+	items := make([]DictItem, len(pairs))
+	for i, pair := range pairs {
+		kv, e := splitDict(pair, ':')
+		if e != nil {
+			return nil, e
+		}
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Dict item '%s' format error.", pair)
+		}
+		key := unescapeDict(kv[0])
+		if len(key) == 0 {
+			return nil, fmt.Errorf("Dict key cannot be empty.")
+		}
+		if len(key) == 1 {
+			full, ok := DictKeys[key]
+			if !ok {
+				return nil, fmt.Errorf("Dict key abbreviation '%s' not find.", key)
+			}
+			key = full
+		}
+		items[i] = DictItem{key, unescapeDict(kv[1])}
+	}
+	return items, nil
+}
+
+////////////////////////////////////////////////////////
+
+// Type 1, compressed dict
+type DictCodec struct{}
+
+func (*DictCodec) Type() uint8 {
+	return TypeCompressedDict
+}
+
+func (*DictCodec) Name() string {
+	return "CompressedDict"
+}
+
+func (c *DictCodec) Check(content string) error {
+	e := (&StringCodec{}).Check(content)
+	if e != nil {
+		return e
+	}
+	// Same as the detection of transactions.CreateOneActionOfHACDEngraved
+	n := len(content)
+	if content[0] != '{' || content[n-1] != '}' || !strings.Contains(content, ":") {
+		return fmt.Errorf("Dict must be wrapped by {} and contains ':'.")
+	}
+	_, e = DecodeDict(content)
+	return e
+}
+
+func (*DictCodec) Decode(content string) (interface{}, error) {
+	return DecodeDict(content)
+}
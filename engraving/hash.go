@@ -0,0 +1,101 @@
+package engraving
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// File hash commitment
+type HashCommitment struct {
+	Algorithm string // MD5 or SHA256
+	Hash      []byte
+}
+
+func (h *HashCommitment) ToHex() string {
+	return hex.EncodeToString(h.Hash)
+}
+
+// Type 51 MD5 and 52 SHA256
+type HashCodec struct {
+	typ uint8
+}
+
+func (c *HashCodec) Type() uint8 {
+	return c.typ
+}
+
+func (c *HashCodec) Name() string {
+	if c.typ == TypeMD5 {
+		return "MD5"
+	}
+	return "SHA256"
+}
+
+func (c *HashCodec) size() int {
+	if c.typ == TypeMD5 {
+		return md5.Size
+	}
+	return sha256.Size
+}
+
+func (c *HashCodec) newHash() hash.Hash {
+	if c.typ == TypeMD5 {
+		return md5.New()
+	}
+	return sha256.New()
+}
+
+func (c *HashCodec) Check(content string) error {
+	if len(content) != c.size() {
+		return fmt.Errorf("%s commitment size must be %d.", c.Name(), c.size())
+	}
+	// A visible content is treated as string, same as transactions.CreateOneActionOfHACDEngraved
+	if isVisible(content) {
+		return fmt.Errorf("%s commitment cannot be visible string.", c.Name())
+	}
+	return nil
+}
+
+func (c *HashCodec) Decode(content string) (interface{}, error) {
+	return &HashCommitment{
+		Algorithm: c.Name(),
+		Hash:      []byte(content),
+	}, nil
+}
+
+// Create the engraving content committing to the file
+func CreateFileCommitment(typ uint8, file io.Reader) (string, error) {
+	codec, ok := GetCodec(typ).(*HashCodec)
+	if !ok {
+		return "", fmt.Errorf("Engraving type %d is not hash commitment.", typ)
+	}
+	h := codec.newHash()
+	_, e := io.Copy(h, file)
+	if e != nil {
+		return "", e
+	}
+	content := string(h.Sum(nil))
+	if e := codec.Check(content); e != nil {
+		return "", e
+	}
+	return content, nil
+}
+
+// Verify the file against the engraving content
+func VerifyFileCommitment(content string, file io.Reader) (bool, error) {
+	codec, ok := GetCodec(DetectType(content)).(*HashCodec)
+	if !ok {
+		return false, fmt.Errorf("Engraving content is not hash commitment.")
+	}
+	h := codec.newHash()
+	_, e := io.Copy(h, file)
+	if e != nil {
+		return false, e
+	}
+	return bytes.Equal(h.Sum(nil), []byte(content)), nil
+}
@@ -63,6 +63,29 @@ type ChainStateOperation interface {
 	ChaswapUpdate(fields.HashHalfChecker, *stores.Chaswap) error
 	ChaswapDelete(fields.HashHalfChecker) error
 
+	GrantLockblsCreate(fields.LockblsId, *stores.GrantLockbls) error
+	GrantLockblsUpdate(fields.LockblsId, *stores.GrantLockbls) error
+	GrantLockblsDelete(fields.LockblsId) error
+
+	RecurringPaymentCreate(fields.RecurringPaymentId, *stores.RecurringPayment) error
+	RecurringPaymentUpdate(fields.RecurringPaymentId, *stores.RecurringPayment) error
+	RecurringPaymentDelete(fields.RecurringPaymentId) error
+
+	EscrowCreate(fields.EscrowId, *stores.Escrow) error
+	EscrowUpdate(fields.EscrowId, *stores.Escrow) error
+	EscrowDelete(fields.EscrowId) error
+
+	DiamondSwapRecordCreate(fields.Hash, *stores.DiamondSwapRecord) error
+	DiamondSwapRecordDelete(fields.Hash) error
+
+	TimeLockTransferCreate(fields.TimeLockTransferId, *stores.TimeLockTransfer) error
+	TimeLockTransferUpdate(fields.TimeLockTransferId, *stores.TimeLockTransfer) error
+	TimeLockTransferDelete(fields.TimeLockTransferId) error
+
+	DiamondVaultCreate(fields.DiamondVaultId, *stores.DiamondVault) error
+	DiamondVaultUpdate(fields.DiamondVaultId, *stores.DiamondVault) error
+	DiamondVaultDelete(fields.DiamondVaultId) error
+
 	// movebtc
 	SaveMoveBTCBelongTxHash(trsno uint32, txhash []byte) error
 	//ReadMoveBTCTxHashByNumber(trsno uint32) ([]byte, error)
@@ -41,6 +41,12 @@ type ChainStateOperationRead interface {
 	BitcoinSystemLending(fields.BitcoinSyslendId) (*stores.BitcoinSystemLending, error)
 	UserLending(fields.UserLendingId) (*stores.UserLending, error)
 	Chaswap(fields.HashHalfChecker) (*stores.Chaswap, error)
+	GrantLockbls(fields.LockblsId) (*stores.GrantLockbls, error)
+	RecurringPayment(fields.RecurringPaymentId) (*stores.RecurringPayment, error)
+	Escrow(fields.EscrowId) (*stores.Escrow, error)
+	DiamondSwapRecord(fields.Hash) (*stores.DiamondSwapRecord, error)
+	TimeLockTransfer(fields.TimeLockTransferId) (*stores.TimeLockTransfer, error)
+	DiamondVault(fields.DiamondVaultId) (*stores.DiamondVault, error)
 
 	// movebtc
 	ReadMoveBTCTxHashByTrsNo(trsno uint32) ([]byte, error)
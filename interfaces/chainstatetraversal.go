@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+)
+
+// Traverse all items of the lending stores
+// The callback returns false to stop the traversal
+
+type LendingStoreTraversal interface {
+	TraversalDiamondSystemLending(func(fields.DiamondSyslendId, *stores.DiamondSystemLending) bool) error
+	TraversalBitcoinSystemLending(func(fields.BitcoinSyslendId, *stores.BitcoinSystemLending) bool) error
+	TraversalUserLending(func(fields.UserLendingId, *stores.UserLending) bool) error
+}
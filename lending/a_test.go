@@ -0,0 +1,347 @@
+package lending
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/memstate"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+	"github.com/hacash/core/transactions"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+
+	dmdlend := stores.NewDiamondSystemLending(acc1.Address)
+	dmdlend.CreateBlockHeight = 100
+	dmdlend.LoanTotalAmountMei = 10
+	dmdlend.BorrowPeriod = 2
+
+	calc := NewDiamondSystemLendingCalculator(dmdlend)
+	points, e := CalculateSchedule(calc, 100, 100+20000*7, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, p := range points {
+		fmt.Println(p.Height, p.Position.Stage, p.Position.RedeemAmount.ToFinString(), p.Position.IsPublicRedeemable())
+	}
+	if len(points) != 8 || points[len(points)-1].Position.Stage != StageAuctionEnd {
+		t.Fatal("diamond lending schedule error")
+	}
+
+	// Bitcoin
+	btclend := stores.NewBitcoinSystemLending(acc1.Address)
+	btclend.CreateBlockHeight = 100
+	btclend.LoanTotalAmount = *fields.NewAmountNumSmallCoin(100)
+
+	calc2 := NewBitcoinSystemLendingCalculator(btclend)
+	pos, _ := calc2.Position(100 + 200000 + 500000)
+	fmt.Println(pos.Stage, pos.RedeemAmount.ToFinString())
+	if pos.Stage != StageAuction || pos.RedeemAmount.ToMei() != 50 {
+		t.Fatal("bitcoin lending auction amount error")
+	}
+
+}
+
+func Test2(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	usrlend := &stores.UserLending{
+		CreateBlockHeight:      100,
+		ExpireBlockHeight:      500,
+		MortgagorAddress:       acc1.Address,
+		LenderAddress:          acc2.Address,
+		AgreedRedemptionAmount: *fields.NewAmountNumSmallCoin(12),
+	}
+
+	calc := NewUserLendingCalculator(usrlend)
+	pos, _ := calc.Position(500)
+	ok1, _ := pos.CanRedeem(acc1.Address)
+	ok2, _ := pos.CanRedeem(acc2.Address)
+	if pos.Stage != StagePrivate || !ok1 || ok2 {
+		t.Fatal("user lending private stage error")
+	}
+
+	pos, _ = calc.Position(501)
+	ok1, _ = pos.CanRedeem(acc1.Address)
+	ok2, amt := pos.CanRedeem(acc2.Address)
+	if pos.Stage != StageOverdue || ok1 || !ok2 || amt.IsNotEmpty() {
+		t.Fatal("user lending overdue stage error")
+	}
+
+	usrlend.IsPublicRedeemable.Set(true)
+	pos, _ = calc.Position(501)
+	if pos.Stage != StagePublic || !pos.IsPublicRedeemable() {
+		t.Fatal("user lending public stage error")
+	}
+
+}
+
+func Test3(t *testing.T) {
+
+	// Ransom actions are only enabled in local development
+	oldmark := sys.TestDebugLocalDevelopmentMark
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = oldmark }()
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	state := memstate.NewMemoryChainState(1)
+	state.BalanceSet(acc2.Address, stores.NewBalanceWithAmount(fields.NewAmountNumSmallCoin(100)))
+
+	btclend := stores.NewBitcoinSystemLending(acc1.Address)
+	btclend.CreateBlockHeight = 1
+	btclend.MortgageBitcoinPortion = 1
+	btclend.LoanTotalAmount = *fields.NewAmountNumSmallCoin(10)
+	lendid := fields.BitcoinSyslendId([]byte("bitcoinlending1"))
+	state.BitcoinLendingCreate(lendid, btclend)
+
+	// Private stage
+	opps, e := ScanPubliclyRedeemable(state, 11)
+	if e != nil || len(opps) != 0 {
+		t.Fatal("scan error", e)
+	}
+
+	// Auction stage
+	opps, e = ScanPubliclyRedeemable(state, 71)
+	if e != nil || len(opps) != 1 {
+		t.Fatal("scan error", e)
+	}
+	fmt.Println(opps[0].Position.Stage, opps[0].Position.RedeemAmount.ToFinString(), opps[0].MortgageSatoshi)
+
+	trs, e := CreateOneTxOfLendingRansom(acc2, opps[0], fields.NewAmountSmall(1, 244), 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	state.SetPendingBlockHeight(71)
+	act := trs.GetActionList()[0]
+	act.SetBelongTrs(trs)
+	e = act.WriteInChainState(state)
+	if e != nil {
+		t.Fatal(e)
+	}
+	bls, _ := state.Balance(acc2.Address)
+	fmt.Println(bls.Hacash.ToFinString(), bls.Satoshi)
+
+	opps, _ = ScanPubliclyRedeemable(state, 72)
+	if len(opps) != 0 {
+		t.Fatal("lending has been redeemed")
+	}
+
+}
+
+func Test4(t *testing.T) {
+
+	oldmark := sys.TestDebugLocalDevelopmentMark
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = oldmark }()
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	state := memstate.NewMemoryChainState(10)
+	bls1 := stores.NewBalanceWithAmount(fields.NewAmountNumSmallCoin(1))
+	bls1.Satoshi = 100000000
+	state.BalanceSet(acc1.Address, bls1)
+	state.BalanceSet(acc2.Address, stores.NewBalanceWithAmount(fields.NewAmountNumSmallCoin(200)))
+
+	offer1 := &LendingOffer{
+		Side:                     fields.VarUint1(OfferSideMortgagor),
+		Address:                  acc1.Address,
+		ValidBeforeHeight:        100,
+		AgreedExpireBlockHeight:  50,
+		MortgageBitcoin:          fields.NewSatoshiVariation(100000000),
+		LoanTotalAmount:          *fields.NewAmountNumSmallCoin(100),
+		AgreedRedemptionAmount:   *fields.NewAmountNumSmallCoin(110),
+		PreBurningInterestAmount: *fields.NewEmptyAmount(),
+	}
+	offer2 := &LendingOffer{
+		Side:                     fields.VarUint1(OfferSideLender),
+		Address:                  acc2.Address,
+		ValidBeforeHeight:        100,
+		AgreedExpireBlockHeight:  60,
+		MortgageBitcoin:          fields.NewSatoshiVariation(50000000),
+		LoanTotalAmount:          *fields.NewAmountNumSmallCoin(100),
+		AgreedRedemptionAmount:   *fields.NewAmountNumSmallCoin(105),
+		PreBurningInterestAmount: *fields.NewAmountNumSmallCoin(1),
+	}
+	offer1.FillSign(acc1)
+	offer2.FillSign(acc2)
+
+	// Serialize
+	bts, _ := offer2.Serialize()
+	offer3 := &LendingOffer{}
+	offer3.Parse(bts, 0)
+	if offer3.VerifySignature() != nil || offer3.Hash().Equal(offer2.Hash()) == false {
+		t.Fatal("offer parse error")
+	}
+
+	book := NewOfferBook()
+	if e := book.Add(offer2); e != nil {
+		t.Fatal(e)
+	}
+	if e := offer1.CheckWithState(state); e != nil {
+		t.Fatal(e)
+	}
+	matchs := book.Match(offer1, 10)
+	if len(matchs) != 1 {
+		t.Fatal("offer match error")
+	}
+
+	trs, e := CreateOneTxOfUsersLendingByOffers(offer1, matchs[0], 10, fields.NewAmountSmall(1, 244), 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = trs.FillNeedSigns(map[string][]byte{
+		string(acc1.Address): acc1.PrivateKey,
+		string(acc2.Address): acc2.PrivateKey,
+	}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	act := trs.GetActionList()[0]
+	act.SetBelongTrs(trs)
+	e = act.WriteInChainState(state)
+	if e != nil {
+		t.Fatal(e)
+	}
+	bls, _ := state.Balance(acc1.Address)
+	fmt.Println(bls.Hacash.ToFinString(), bls.Satoshi)
+
+}
+
+func Test5(t *testing.T) {
+
+	oldmark := sys.TestDebugLocalDevelopmentMark
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = oldmark }()
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	state := memstate.NewMemoryChainState(20)
+	state.BalanceSet(acc1.Address, stores.NewBalanceWithAmount(fields.NewAmountNumSmallCoin(100)))
+
+	lendid := fields.UserLendingId([]byte("userlendingid0001"))
+	state.UserLendingCreate(lendid, &stores.UserLending{
+		CreateBlockHeight:      10,
+		ExpireBlockHeight:      50,
+		MortgagorAddress:       acc1.Address,
+		LenderAddress:          acc2.Address,
+		MortgageBitcoin:        fields.NewSatoshiVariation(100000000),
+		LoanTotalAmount:        *fields.NewAmountNumSmallCoin(100),
+		AgreedRedemptionAmount: *fields.NewAmountNumSmallCoin(110),
+	})
+
+	var execute = func(act interfaces.Action, signers ...*account.Account) error {
+		trs, _ := transactions.NewEmptyTransaction_2_Simple(signers[0].Address)
+		trs.AddAction(act)
+		prikeys := map[string][]byte{}
+		for _, acc := range signers {
+			prikeys[string(acc.Address)] = acc.PrivateKey
+		}
+		trs.FillNeedSigns(prikeys, []fields.Address{acc1.Address, acc2.Address})
+		act.SetBelongTrs(trs)
+		return act.WriteInChainState(state)
+	}
+
+	// Release too much
+	e := execute(&actions.Action_37_UsersLendingPartialRepay{
+		LendingID:      lendid,
+		RepayAmount:    *fields.NewAmountNumSmallCoin(55),
+		ReleaseBitcoin: fields.NewSatoshiVariation(60000000),
+	}, acc1)
+	if e == nil {
+		t.Fatal("release proportion check error")
+	}
+	e = execute(&actions.Action_37_UsersLendingPartialRepay{
+		LendingID:      lendid,
+		RepayAmount:    *fields.NewAmountNumSmallCoin(55),
+		ReleaseBitcoin: fields.NewSatoshiVariation(50000000),
+	}, acc1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	usrlend, _ := state.UserLending(lendid)
+	fmt.Println(usrlend.AgreedRedemptionAmount.ToFinString(), usrlend.RepaidAmount.ToFinString(), usrlend.MortgageBitcoin.ValueSAT)
+	if usrlend.AgreedRedemptionAmount.ToMei() != 55 || usrlend.MortgageBitcoin.ValueSAT != 50000000 {
+		t.Fatal("partial repay error")
+	}
+	if usrlend.RepaidAmount.ToMei() != 55 || usrlend.ExtendDataVersion != stores.UserLendingExtendDataVersion1 {
+		t.Fatal("repaid amount store error")
+	}
+
+	// Adjust need both signatures
+	adjust := &actions.Action_38_UsersLendingAdjust{
+		LendingID:                 lendid,
+		NewExpireBlockHeight:      100,
+		NewAgreedRedemptionAmount: *fields.NewAmountNumSmallCoin(60),
+	}
+	if execute(adjust, acc1) == nil {
+		t.Fatal("adjust signature check error")
+	}
+	e = execute(adjust, acc1, acc2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	usrlend, _ = state.UserLending(lendid)
+	if usrlend.ExpireBlockHeight != 100 || usrlend.AgreedRedemptionAmount.ToMei() != 60 {
+		t.Fatal("adjust error")
+	}
+	// Close to expire, lower the amount only without extending
+	state.SetPendingBlockHeight(95)
+	e = execute(&actions.Action_38_UsersLendingAdjust{
+		LendingID:                 lendid,
+		NewExpireBlockHeight:      100,
+		NewAgreedRedemptionAmount: *fields.NewAmountNumSmallCoin(58),
+	}, acc1, acc2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if execute(&actions.Action_38_UsersLendingAdjust{
+		LendingID:                 lendid,
+		NewExpireBlockHeight:      101,
+		NewAgreedRedemptionAmount: *fields.NewAmountNumSmallCoin(58),
+	}, acc1, acc2) == nil {
+		t.Fatal("changed expire height must keep the minimum period")
+	}
+	usrlend, _ = state.UserLending(lendid)
+	if usrlend.AgreedRedemptionAmount.ToMei() != 58 || usrlend.LastAdjustBlockHeight != 95 {
+		t.Fatal("adjust amount only error")
+	}
+	// Stored with extend data
+	bts, _ := usrlend.Serialize()
+	parsed := &stores.UserLending{}
+	if _, e := parsed.Parse(bts, 0); e != nil || parsed.RepaidAmount.ToMei() != 55 || parsed.LastAdjustBlockHeight != 95 {
+		t.Fatal("extend data store error")
+	}
+
+	// Records without extend data keep the old stored format
+	oldlend := &stores.UserLending{
+		MortgagorAddress:       acc1.Address,
+		LenderAddress:          acc2.Address,
+		MortgageBitcoin:        fields.NewSatoshiVariation(1),
+		LoanTotalAmount:        *fields.NewAmountNumSmallCoin(1),
+		AgreedRedemptionAmount: *fields.NewAmountNumSmallCoin(2),
+	}
+	oldlend.SetRansomedStatus(30, fields.NewAmountNumSmallCoin(2), acc1.Address)
+	oldlend.RepaidAmount = *fields.NewAmountNumSmallCoin(1) // Not written without version
+	oldbts, _ := oldlend.Serialize()
+	if uint32(len(oldbts)) != oldlend.Size() || oldlend.ExtendDataVersion != 0 {
+		t.Fatal("old format size error")
+	}
+	parselend := &stores.UserLending{}
+	seek, e := parselend.Parse(oldbts, 0)
+	if e != nil || seek != uint32(len(oldbts)) || parselend.RepaidAmount.IsNotEmpty() || !parselend.RansomAddress.Equal(acc1.Address) {
+		t.Fatal("old format parse error")
+	}
+
+}
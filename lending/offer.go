@@ -0,0 +1,419 @@
+package lending
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+)
+
+/**
+
+用户间借贷报价单（链下签名）
+
+抵押人报价：提供抵押物，要求的最少借款额、最多赎回额、最早到期高度
+贷出人报价：提供借款额，要求的最少抵押物、最少赎回额、最晚到期高度，并支付预先销毁的利息
+
+*/
+
+const (
+	OfferSideMortgagor uint8 = 1
+	OfferSideLender    uint8 = 2
+)
+
+type LendingOffer struct {
+	Side              fields.VarUint1
+	Address           fields.Address     // Offer maker
+	OfferNumber       fields.VarUint8    // Distinguish the offers of the same address
+	ValidBeforeHeight fields.BlockHeight // The offer can only be matched before this height
+
+	IsRedemptionOvertime    fields.Bool        // Must be the same as the counterparty
+	IsPublicRedeemable      fields.Bool        // Must be the same as the counterparty
+	AgreedExpireBlockHeight fields.BlockHeight // Mortgagor: the earliest, lender: the latest
+
+	MortgageBitcoin      fields.SatoshiVariation     // Mortgagor: provided, lender: the least required
+	MortgageDiamondList  fields.DiamondListMaxLen200 // Mortgagor only
+	MortgageDiamondCount fields.VarUint1             // Mortgagor: equal to the list count, lender: the least required
+
+	LoanTotalAmount          fields.Amount // Must be the same as the counterparty
+	AgreedRedemptionAmount   fields.Amount // Mortgagor: the most, lender: the least
+	PreBurningInterestAmount fields.Amount // Lender only, must not less than 1% of the loan amount
+
+	Sign fields.Sign
+}
+
+func (elm *LendingOffer) IsMortgagor() bool {
+	return uint8(elm.Side) == OfferSideMortgagor
+}
+
+func (elm *LendingOffer) IsLender() bool {
+	return uint8(elm.Side) == OfferSideLender
+}
+
+func (elm *LendingOffer) Size() uint32 {
+	return elm.Side.Size() +
+		elm.Address.Size() +
+		elm.OfferNumber.Size() +
+		elm.ValidBeforeHeight.Size() +
+		elm.IsRedemptionOvertime.Size() +
+		elm.IsPublicRedeemable.Size() +
+		elm.AgreedExpireBlockHeight.Size() +
+		elm.MortgageBitcoin.Size() +
+		elm.MortgageDiamondList.Size() +
+		elm.MortgageDiamondCount.Size() +
+		elm.LoanTotalAmount.Size() +
+		elm.AgreedRedemptionAmount.Size() +
+		elm.PreBurningInterestAmount.Size() +
+		elm.Sign.Size()
+}
+
+func (elm *LendingOffer) SerializeForSign() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Side.Serialize()
+	var b2, _ = elm.Address.Serialize()
+	var b3, _ = elm.OfferNumber.Serialize()
+	var b4, _ = elm.ValidBeforeHeight.Serialize()
+	var b5, _ = elm.IsRedemptionOvertime.Serialize()
+	var b6, _ = elm.IsPublicRedeemable.Serialize()
+	var b7, _ = elm.AgreedExpireBlockHeight.Serialize()
+	var b8, _ = elm.MortgageBitcoin.Serialize()
+	var b9, e9 = elm.MortgageDiamondList.Serialize()
+	if e9 != nil {
+		return nil, e9
+	}
+	var b10, _ = elm.MortgageDiamondCount.Serialize()
+	var b11, e11 = elm.LoanTotalAmount.Serialize()
+	if e11 != nil {
+		return nil, e11
+	}
+	var b12, e12 = elm.AgreedRedemptionAmount.Serialize()
+	if e12 != nil {
+		return nil, e12
+	}
+	var b13, e13 = elm.PreBurningInterestAmount.Serialize()
+	if e13 != nil {
+		return nil, e13
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	buffer.Write(b9)
+	buffer.Write(b10)
+	buffer.Write(b11)
+	buffer.Write(b12)
+	buffer.Write(b13)
+	return buffer.Bytes(), nil
+}
+
+func (elm *LendingOffer) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	body, e := elm.SerializeForSign()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(body)
+	var bt, _ = elm.Sign.Serialize()
+	buffer.Write(bt)
+	return buffer.Bytes(), nil
+}
+
+func (elm *LendingOffer) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Side.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Address.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.OfferNumber.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ValidBeforeHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsRedemptionOvertime.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsPublicRedeemable.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AgreedExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MortgageBitcoin.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MortgageDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MortgageDiamondCount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LoanTotalAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AgreedRedemptionAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PreBurningInterestAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Sign.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *LendingOffer) SignStuffHash() fields.Hash {
+	var conbt, _ = elm.SerializeForSign() // Data body
+	return fields.CalculateHash(conbt)
+}
+
+// Identify the offer, include the signature
+func (elm *LendingOffer) Hash() fields.Hash {
+	var conbt, _ = elm.Serialize()
+	return fields.CalculateHash(conbt)
+}
+
+// Fill signature of the offer maker
+func (elm *LendingOffer) FillSign(acc *account.Account) error {
+	if elm.Address.NotEqual(acc.Address) {
+		return fmt.Errorf("Account %s is not the offer maker %s.", acc.AddressReadable, elm.Address.ToReadable())
+	}
+	signdata, e := acc.Private.Sign(elm.SignStuffHash())
+	if e != nil {
+		return e // Signature error
+	}
+	elm.Sign = fields.Sign{
+		PublicKey: acc.PublicKey,
+		Signature: signdata.Serialize64(),
+	}
+	return nil
+}
+
+// Verify signature and the address of public key
+func (elm *LendingOffer) VerifySignature() error {
+	addr := fields.Address(account.NewAddressFromPublicKeyV0(elm.Sign.PublicKey))
+	if addr.NotEqual(elm.Address) {
+		return fmt.Errorf("Offer sign public key not match address %s.", elm.Address.ToReadable())
+	}
+	ok, _ := account.CheckSignByHash32(elm.SignStuffHash(), elm.Sign.PublicKey, elm.Sign.Signature)
+	if !ok {
+		return fmt.Errorf("Offer maker %s verify signature fail.", elm.Address.ToReadable())
+	}
+	return nil
+}
+
+// Check the terms, same as Action_19_UsersLendingCreate
+func (elm *LendingOffer) CheckValidity() error {
+	if !elm.IsMortgagor() && !elm.IsLender() {
+		return fmt.Errorf("Offer side <%d> error.", elm.Side)
+	}
+	if !elm.Address.IsValid() {
+		return fmt.Errorf("Offer address is invalid.")
+	}
+	// Check amount length
+	if len(elm.LoanTotalAmount.Numeral) > 4 {
+		return fmt.Errorf("Amount <%s> byte length is too long.", elm.LoanTotalAmount.ToFinString())
+	}
+	if len(elm.AgreedRedemptionAmount.Numeral) > 4 {
+		return fmt.Errorf("Amount <%s> byte length is too long.", elm.AgreedRedemptionAmount.ToFinString())
+	}
+	if len(elm.PreBurningInterestAmount.Numeral) > 4 {
+		return fmt.Errorf("Amount <%s> byte length is too long.", elm.PreBurningInterestAmount.ToFinString())
+	}
+	if elm.LoanTotalAmount.IsEmpty() || elm.AgreedRedemptionAmount.IsEmpty() {
+		return fmt.Errorf("Amount cannot be empty.")
+	}
+	if int(elm.MortgageDiamondList.Count) != len(elm.MortgageDiamondList.Diamonds) {
+		return fmt.Errorf("Diamonds quantity error")
+	}
+	if elm.IsMortgagor() {
+		if elm.MortgageDiamondCount != elm.MortgageDiamondList.Count {
+			return fmt.Errorf("Diamonds quantity error")
+		}
+		if elm.MortgageBitcoin.NotEmpty.Is(false) && elm.MortgageDiamondList.Count == 0 {
+			return fmt.Errorf("Mortgage diamond and bitcoin cannot be empty at the same time")
+		}
+		if elm.PreBurningInterestAmount.IsNotEmpty() {
+			return fmt.Errorf("PreBurningInterestAmount is paid by the lender.")
+		}
+	} else {
+		if elm.MortgageDiamondList.Count > 0 {
+			return fmt.Errorf("Lender offer cannot specify the diamond list.")
+		}
+		// Destroy at least 1% of the loan quantity
+		mustBurnDesk := elm.LoanTotalAmount.Copy()
+		if mustBurnDesk.Unit > 2 {
+			mustBurnDesk.Unit -= 2
+		}
+		if elm.PreBurningInterestAmount.LessThan(mustBurnDesk) {
+			return fmt.Errorf("PreBurningInterestAmount <%s> can not less than <%s>", elm.PreBurningInterestAmount.ToFinString(), mustBurnDesk.ToFinString())
+		}
+	}
+	if elm.MortgageDiamondCount > 200 {
+		return fmt.Errorf("Diamonds quantity cannot over 200")
+	}
+	return nil
+}
+
+// Check the collateral and balance with chain state
+func (elm *LendingOffer) CheckWithState(state interfaces.ChainStateOperationRead) error {
+	if uint64(elm.ValidBeforeHeight) < state.GetPendingBlockHeight() {
+		return fmt.Errorf("Offer expired at height %d.", elm.ValidBeforeHeight)
+	}
+	bls, e := state.Balance(elm.Address)
+	if e != nil {
+		return e
+	}
+	if bls == nil {
+		bls = stores.NewEmptyBalance()
+	}
+	if elm.IsLender() {
+		need, e := elm.LoanTotalAmount.Add(&elm.PreBurningInterestAmount)
+		if e != nil {
+			return e
+		}
+		if bls.Hacash.LessThan(need) {
+			return fmt.Errorf("Address %s balance %s not enough, need %s.", elm.Address.ToReadable(), bls.Hacash.ToFinString(), need.ToFinString())
+		}
+		return nil
+	}
+	// Mortgagor
+	if elm.MortgageBitcoin.NotEmpty.Check() && bls.Satoshi < elm.MortgageBitcoin.ValueSAT {
+		return fmt.Errorf("Address %s satoshi %d not enough.", elm.Address.ToReadable(), bls.Satoshi)
+	}
+	for _, diamond := range elm.MortgageDiamondList.Diamonds {
+		diaitem, e := state.Diamond(diamond)
+		if e != nil {
+			return e
+		}
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Address.NotEqual(elm.Address) {
+			return fmt.Errorf("Diamond <%s> not belong to address '%s'", string(diamond), elm.Address.ToReadable())
+		}
+		if diaitem.Status != stores.DiamondStatusNormal {
+			return fmt.Errorf("Diamond <%s> has been mortgaged.", string(diamond))
+		}
+	}
+	return nil
+}
+
+/********************************************************/
+
+// Check whether the two offers can be matched at the height
+func MatchLendingOffers(mortgagor, lender *LendingOffer, height uint64) error {
+	if !mortgagor.IsMortgagor() || !lender.IsLender() {
+		return fmt.Errorf("Offer side error.")
+	}
+	if mortgagor.Address.Equal(lender.Address) {
+		return fmt.Errorf("Cannot lending to myself.")
+	}
+	if uint64(mortgagor.ValidBeforeHeight) < height || uint64(lender.ValidBeforeHeight) < height {
+		return fmt.Errorf("Offer expired.")
+	}
+	if mortgagor.IsRedemptionOvertime != lender.IsRedemptionOvertime ||
+		mortgagor.IsPublicRedeemable != lender.IsPublicRedeemable {
+		return fmt.Errorf("Redeem options not match.")
+	}
+	if mortgagor.LoanTotalAmount.NotEqual(&lender.LoanTotalAmount) {
+		return fmt.Errorf("Loan amount %s not match %s.", mortgagor.LoanTotalAmount.ToFinString(), lender.LoanTotalAmount.ToFinString())
+	}
+	if mortgagor.AgreedRedemptionAmount.LessThan(&lender.AgreedRedemptionAmount) {
+		return fmt.Errorf("Redemption amount %s less than %s.", mortgagor.AgreedRedemptionAmount.ToFinString(), lender.AgreedRedemptionAmount.ToFinString())
+	}
+	if mortgagor.AgreedExpireBlockHeight > lender.AgreedExpireBlockHeight {
+		return fmt.Errorf("Expire height %d over than %d.", mortgagor.AgreedExpireBlockHeight, lender.AgreedExpireBlockHeight)
+	}
+	if mortgagor.MortgageDiamondCount < lender.MortgageDiamondCount {
+		return fmt.Errorf("Mortgage diamond count %d less than %d.", mortgagor.MortgageDiamondCount, lender.MortgageDiamondCount)
+	}
+	if mortgagor.MortgageBitcoin.GetRealSatoshi() < lender.MortgageBitcoin.GetRealSatoshi() {
+		return fmt.Errorf("Mortgage satoshi %d less than %d.", mortgagor.MortgageBitcoin.GetRealSatoshi(), lender.MortgageBitcoin.GetRealSatoshi())
+	}
+	return nil
+}
+
+// Lending id of the matched offers
+func CreateUserLendingIdByOffers(mortgagor, lender *LendingOffer) fields.UserLendingId {
+	stuff := bytes.NewBuffer(nil)
+	stuff.Write(mortgagor.Hash())
+	stuff.Write(lender.Hash())
+	hx := fields.CalculateHash(stuff.Bytes())
+	id := append([]byte{}, hx[0:stores.UserLendingIdLength]...)
+	// The first and last byte cannot be zero
+	if id[0] == 0 {
+		id[0] = 1
+	}
+	if id[stores.UserLendingIdLength-1] == 0 {
+		id[stores.UserLendingIdLength-1] = 1
+	}
+	return id
+}
+
+// Create the lending action with the terms of the matched offers
+// The redemption amount is the lender's and the expire height is the mortgagor's
+func CreateUsersLendingCreateActionByOffers(mortgagor, lender *LendingOffer, height uint64) (*actions.Action_19_UsersLendingCreate, error) {
+	e := MatchLendingOffers(mortgagor, lender, height)
+	if e != nil {
+		return nil, e
+	}
+	return &actions.Action_19_UsersLendingCreate{
+		LendingID:                CreateUserLendingIdByOffers(mortgagor, lender),
+		IsRedemptionOvertime:     mortgagor.IsRedemptionOvertime,
+		IsPublicRedeemable:       mortgagor.IsPublicRedeemable,
+		AgreedExpireBlockHeight:  mortgagor.AgreedExpireBlockHeight,
+		MortgagorAddress:         mortgagor.Address,
+		LenderAddress:            lender.Address,
+		MortgageBitcoin:          mortgagor.MortgageBitcoin,
+		MortgageDiamondList:      mortgagor.MortgageDiamondList,
+		LoanTotalAmount:          lender.LoanTotalAmount,
+		AgreedRedemptionAmount:   lender.AgreedRedemptionAmount,
+		PreBurningInterestAmount: lender.PreBurningInterestAmount,
+	}, nil
+}
+
+// Create the lending transaction, the mortgagor pays the fee
+// Both the mortgagor and the lender need to sign it by FillNeedSigns
+func CreateOneTxOfUsersLendingByOffers(mortgagor, lender *LendingOffer, height uint64, fee *fields.Amount, timestamp int64) (*transactions.Transaction_2_Simple, error) {
+	act, e := CreateUsersLendingCreateActionByOffers(mortgagor, lender, height)
+	if e != nil {
+		return nil, e
+	}
+	newTrs, e := transactions.NewEmptyTransaction_2_Simple(mortgagor.Address)
+	if e != nil {
+		return nil, e
+	}
+	newTrs.Timestamp = fields.BlockTxTimestamp(timestamp) // Use timestamp
+	newTrs.Fee = *fee                                     // set fee
+	e = newTrs.AddAction(act)
+	if e != nil {
+		return nil, e
+	}
+	return newTrs, nil
+}
@@ -0,0 +1,109 @@
+package lending
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Order book of the lending offers
+type OfferBook struct {
+	offers map[string]*LendingOffer // key: offer hash
+	mu     sync.RWMutex
+}
+
+func NewOfferBook() *OfferBook {
+	return &OfferBook{
+		offers: make(map[string]*LendingOffer),
+	}
+}
+
+// Add an offer after checking the terms and signature
+func (b *OfferBook) Add(offer *LendingOffer) error {
+	e := offer.CheckValidity()
+	if e != nil {
+		return e
+	}
+	e = offer.VerifySignature()
+	if e != nil {
+		return e
+	}
+	key := string(offer.Hash())
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.offers[key]; ok {
+		return fmt.Errorf("Offer <%s> already exist.", offer.Hash().ToHex())
+	}
+	b.offers[key] = offer
+	return nil
+}
+
+// Remove an offer, such as it has been matched
+func (b *OfferBook) Remove(offer *LendingOffer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.offers, string(offer.Hash()))
+}
+
+// Remove all offers expired before the height
+func (b *OfferBook) PruneExpired(height uint64) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	count := 0
+	for k, v := range b.offers {
+		if uint64(v.ValidBeforeHeight) < height {
+			delete(b.offers, k)
+			count++
+		}
+	}
+	return count
+}
+
+// All offers of the side
+func (b *OfferBook) Offers(side uint8) []*LendingOffer {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	list := make([]*LendingOffer, 0)
+	for _, v := range b.offers {
+		if uint8(v.Side) == side {
+			list = append(list, v)
+		}
+	}
+	sortOffers(list, side)
+	return list
+}
+
+// The counterparty offers which can be matched, the best price first
+// The mortgagor prefers the lowest redemption amount and the lender prefers the highest
+func (b *OfferBook) Match(offer *LendingOffer, height uint64) []*LendingOffer {
+	var side = OfferSideLender
+	if offer.IsLender() {
+		side = OfferSideMortgagor
+	}
+	results := make([]*LendingOffer, 0)
+	for _, v := range b.Offers(side) {
+		var e error
+		if offer.IsMortgagor() {
+			e = MatchLendingOffers(offer, v, height)
+		} else {
+			e = MatchLendingOffers(v, offer, height)
+		}
+		if e == nil {
+			results = append(results, v)
+		}
+	}
+	return results
+}
+
+func sortOffers(list []*LendingOffer, side uint8) {
+	sort.Slice(list, func(i, j int) bool {
+		a1, a2 := &list[i].AgreedRedemptionAmount, &list[j].AgreedRedemptionAmount
+		if a1.Equal(a2) {
+			return list[i].Hash().ToHex() < list[j].Hash().ToHex()
+		}
+		if side == OfferSideLender {
+			return a1.LessThan(a2)
+		}
+		return a1.MoreThan(a2)
+	})
+}
@@ -0,0 +1,295 @@
+package lending
+
+import (
+	"fmt"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/**
+
+借贷仓位计算（钻石系统借贷、比特币系统借贷、用户间借贷）
+
+所有计算与链上共识规则一致
+
+*/
+
+// Redemption stage
+const (
+	StageRansomed   uint8 = 0 // Has been redeemed
+	StagePrivate    uint8 = 1 // 私有期: only the mortgagor can redeem
+	StagePublic     uint8 = 2 // 公共期: anyone can redeem
+	StageAuction    uint8 = 3 // 拍卖期: anyone can redeem, the amount decreases over time
+	StageAuctionEnd uint8 = 4 // Auction deduction reaches the maximum, the amount will not change
+	StageOverdue    uint8 = 5 // User lending expired and not public redeemable
+)
+
+// Role of the redeemer
+const (
+	RoleMortgagor uint8 = 1
+	RoleLender    uint8 = 2
+	RolePublic    uint8 = 3 // Anyone
+)
+
+// Lending type
+const (
+	KindDiamondSystemLending uint8 = 1
+	KindBitcoinSystemLending uint8 = 2
+	KindUserLending          uint8 = 3
+)
+
+// Who may redeem and how much to pay
+type RedeemRight struct {
+	Role    uint8
+	Address fields.Address // nil if role is public
+	Amount  *fields.Amount
+}
+
+// Status of a lending at the pending block height
+type Position struct {
+	Kind         uint8
+	Height       uint64 // Pending block height
+	Stage        uint8
+	RedeemAmount *fields.Amount // Amount the mortgagor or the public should pay, nil if ransomed
+	Rights       []*RedeemRight
+}
+
+// Whether the address can redeem, and the amount to pay
+func (p *Position) CanRedeem(addr fields.Address) (bool, *fields.Amount) {
+	var public *RedeemRight = nil
+	for _, r := range p.Rights {
+		if r.Role == RolePublic {
+			public = r
+			continue
+		}
+		if r.Address.Equal(addr) {
+			return true, r.Amount
+		}
+	}
+	if public != nil {
+		return true, public.Amount
+	}
+	return false, nil
+}
+
+// Anyone can redeem
+func (p *Position) IsPublicRedeemable() bool {
+	for _, r := range p.Rights {
+		if r.Role == RolePublic {
+			return true
+		}
+	}
+	return false
+}
+
+// Calculator of one lending
+type PositionCalculator interface {
+	Position(height uint64) (*Position, error)
+	// The first heights of new stage or new redemption amount
+	ChangeHeights() []uint64
+}
+
+/********************************************************/
+
+// Same as consensus, the test environment uses 10 blocks as cycle
+func DiamondSystemLendingPeriodBlockNumber() uint64 {
+	if sys.TestDebugLocalDevelopmentMark {
+		return 10
+	}
+	return actions.DiamondsSystemLendingBorrowPeriodBlockNumber
+}
+
+func BitcoinSystemLendingRansomBlockNumberBase() uint64 {
+	if sys.TestDebugLocalDevelopmentMark {
+		return 10
+	}
+	return actions.BitcoinsSystemLendingRansomBlockNumberBase
+}
+
+func ransomedPosition(kind uint8, height uint64) *Position {
+	return &Position{
+		Kind:   kind,
+		Height: height,
+		Stage:  StageRansomed,
+		Rights: []*RedeemRight{},
+	}
+}
+
+// Private stage only the mortgagor, or anyone with the same amount
+func systemLendingRights(stage uint8, mainAddr fields.Address, amount *fields.Amount) []*RedeemRight {
+	if stage == StagePrivate {
+		return []*RedeemRight{{Role: RoleMortgagor, Address: mainAddr, Amount: amount}}
+	}
+	return []*RedeemRight{{Role: RolePublic, Amount: amount}}
+}
+
+/********************************************************/
+
+type DiamondSystemLendingCalculator struct {
+	lending *stores.DiamondSystemLending
+	dslbpbn uint64
+}
+
+func NewDiamondSystemLendingCalculator(obj *stores.DiamondSystemLending) *DiamondSystemLendingCalculator {
+	return &DiamondSystemLendingCalculator{
+		lending: obj,
+		dslbpbn: DiamondSystemLendingPeriodBlockNumber(),
+	}
+}
+
+func (c *DiamondSystemLendingCalculator) Position(height uint64) (*Position, error) {
+	obj := c.lending
+	if obj.IsRansomed.Check() {
+		return ransomedPosition(KindDiamondSystemLending, height), nil
+	}
+	// Calculate with the mortgagor address, never fail in private stage
+	stage, amount, e := coinbase.CalculationDiamondSystemLendingRedeemAmount(
+		obj.MainAddress, obj.MainAddress,
+		int64(obj.BorrowPeriod), int64(obj.CreateBlockHeight),
+		int64(obj.LoanTotalAmountMei),
+		int64(c.dslbpbn), int64(height))
+	if e != nil {
+		return nil, e
+	}
+	return &Position{
+		Kind:         KindDiamondSystemLending,
+		Height:       height,
+		Stage:        stage,
+		RedeemAmount: amount,
+		Rights:       systemLendingRights(stage, obj.MainAddress, amount),
+	}, nil
+}
+
+func (c *DiamondSystemLendingCalculator) ChangeHeights() []uint64 {
+	obj := c.lending
+	if obj.IsRansomed.Check() {
+		return []uint64{}
+	}
+	base := uint64(obj.BorrowPeriod) * c.dslbpbn
+	privateHeight := uint64(obj.CreateBlockHeight) + base
+	publicHeight := privateHeight + base
+	heights := []uint64{privateHeight + 1, publicHeight + 1}
+	// Deduct interest every cycle
+	maxsub := uint64(obj.BorrowPeriod) * 2
+	for i := uint64(1); i <= maxsub+1; i++ {
+		heights = append(heights, publicHeight+i*c.dslbpbn)
+	}
+	return heights
+}
+
+/********************************************************/
+
+type BitcoinSystemLendingCalculator struct {
+	lending *stores.BitcoinSystemLending
+	base    uint64
+}
+
+func NewBitcoinSystemLendingCalculator(obj *stores.BitcoinSystemLending) *BitcoinSystemLendingCalculator {
+	return &BitcoinSystemLendingCalculator{
+		lending: obj,
+		base:    BitcoinSystemLendingRansomBlockNumberBase(),
+	}
+}
+
+func (c *BitcoinSystemLendingCalculator) Position(height uint64) (*Position, error) {
+	obj := c.lending
+	if obj.IsRansomed.Check() {
+		return ransomedPosition(KindBitcoinSystemLending, height), nil
+	}
+	stage, amount, e := coinbase.CalculationBitcoinSystemLendingRedeemAmount(
+		obj.MainAddress, obj.MainAddress, &obj.LoanTotalAmount,
+		c.base, uint64(obj.CreateBlockHeight), height)
+	if e != nil {
+		return nil, e
+	}
+	if stage == StageAuction && amount.IsEmpty() {
+		stage = StageAuctionEnd
+	}
+	return &Position{
+		Kind:         KindBitcoinSystemLending,
+		Height:       height,
+		Stage:        stage,
+		RedeemAmount: amount,
+		Rights:       systemLendingRights(stage, obj.MainAddress, amount),
+	}, nil
+}
+
+// The amount decreases every block in auction stage, only the stage heights are returned
+func (c *BitcoinSystemLendingCalculator) ChangeHeights() []uint64 {
+	obj := c.lending
+	if obj.IsRansomed.Check() {
+		return []uint64{}
+	}
+	privateHeight := uint64(obj.CreateBlockHeight) + c.base
+	publicHeight := privateHeight + c.base
+	return []uint64{privateHeight + 1, publicHeight + 1, publicHeight + c.base*10}
+}
+
+/********************************************************/
+
+type UserLendingCalculator struct {
+	lending *stores.UserLending
+}
+
+func NewUserLendingCalculator(obj *stores.UserLending) *UserLendingCalculator {
+	return &UserLendingCalculator{
+		lending: obj,
+	}
+}
+
+// Same rules as Action_20_UsersLendingRansom
+func (c *UserLendingCalculator) Position(height uint64) (*Position, error) {
+	obj := c.lending
+	if obj.IsRansomed.Check() {
+		return ransomedPosition(KindUserLending, height), nil
+	}
+	amount := obj.AgreedRedemptionAmount.Copy()
+	pos := &Position{
+		Kind:         KindUserLending,
+		Height:       height,
+		RedeemAmount: amount,
+	}
+	// Within the mortgage period
+	if height <= uint64(obj.ExpireBlockHeight) {
+		pos.Stage = StagePrivate
+		pos.Rights = []*RedeemRight{{Role: RoleMortgagor, Address: obj.MortgagorAddress, Amount: amount}}
+		return pos, nil
+	}
+	// Beyond the mortgage period, the lender can seize without paying
+	pos.Rights = []*RedeemRight{{Role: RoleLender, Address: obj.LenderAddress, Amount: fields.NewEmptyAmount()}}
+	if obj.IsPublicRedeemable.Check() {
+		pos.Stage = StagePublic
+		pos.Rights = append(pos.Rights, &RedeemRight{Role: RolePublic, Amount: amount})
+	} else {
+		pos.Stage = StageOverdue
+		if obj.IsRedemptionOvertime.Check() {
+			pos.Rights = append(pos.Rights, &RedeemRight{Role: RoleMortgagor, Address: obj.MortgagorAddress, Amount: amount})
+		}
+	}
+	return pos, nil
+}
+
+func (c *UserLendingCalculator) ChangeHeights() []uint64 {
+	if c.lending.IsRansomed.Check() {
+		return []uint64{}
+	}
+	return []uint64{uint64(c.lending.ExpireBlockHeight) + 1}
+}
+
+/********************************************************/
+
+// Calculator of any stored lending
+func NewPositionCalculator(obj interface{}) (PositionCalculator, error) {
+	switch v := obj.(type) {
+	case *stores.DiamondSystemLending:
+		return NewDiamondSystemLendingCalculator(v), nil
+	case *stores.BitcoinSystemLending:
+		return NewBitcoinSystemLendingCalculator(v), nil
+	case *stores.UserLending:
+		return NewUserLendingCalculator(v), nil
+	}
+	return nil, fmt.Errorf("Lending type %T not support.", obj)
+}
@@ -0,0 +1,141 @@
+package lending
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+)
+
+/**
+
+公开赎回（清算）机会扫描
+
+*/
+
+// A lending that anyone can redeem
+type Opportunity struct {
+	LendingId []byte
+	Position  *Position // At the scan height
+
+	MortgageDiamonds fields.DiamondListMaxLen200 // Diamond lending and user lending
+	MortgageSatoshi  fields.Satoshi              // Bitcoin lending and user lending
+}
+
+// Scan all lendings which can be redeemed publicly at the height (usually the next block height)
+func ScanPubliclyRedeemable(state interfaces.LendingStoreTraversal, height uint64) ([]*Opportunity, error) {
+	var err error = nil
+	results := make([]*Opportunity, 0)
+	// Check one lending
+	var check = func(id []byte, calc PositionCalculator) *Opportunity {
+		pos, e := calc.Position(height)
+		if e != nil {
+			err = e
+			return nil
+		}
+		if pos.IsPublicRedeemable() == false {
+			return nil
+		}
+		opp := &Opportunity{
+			LendingId: id,
+			Position:  pos,
+		}
+		results = append(results, opp)
+		return opp
+	}
+	// Diamond
+	e1 := state.TraversalDiamondSystemLending(func(id fields.DiamondSyslendId, obj *stores.DiamondSystemLending) bool {
+		opp := check(id, NewDiamondSystemLendingCalculator(obj))
+		if opp != nil {
+			opp.MortgageDiamonds = obj.MortgageDiamondList
+		}
+		return err == nil
+	})
+	if e1 != nil {
+		return nil, e1
+	}
+	// Bitcoin
+	e2 := state.TraversalBitcoinSystemLending(func(id fields.BitcoinSyslendId, obj *stores.BitcoinSystemLending) bool {
+		opp := check(id, NewBitcoinSystemLendingCalculator(obj))
+		if opp != nil {
+			opp.MortgageSatoshi = fields.Satoshi(uint64(obj.MortgageBitcoinPortion) * 100 * 10000)
+		}
+		return err == nil
+	})
+	if e2 != nil {
+		return nil, e2
+	}
+	// User
+	e3 := state.TraversalUserLending(func(id fields.UserLendingId, obj *stores.UserLending) bool {
+		opp := check(id, NewUserLendingCalculator(obj))
+		if opp != nil {
+			opp.MortgageDiamonds = obj.MortgageDiamondList
+			if obj.MortgageBitcoin.NotEmpty.Check() {
+				opp.MortgageSatoshi = obj.MortgageBitcoin.ValueSAT
+			}
+		}
+		return err == nil
+	})
+	if e3 != nil {
+		return nil, e3
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Create the ransom action of the lending for the redeemer
+func CreateRansomAction(opp *Opportunity, redeemer fields.Address) (interfaces.Action, error) {
+	ok, amount := opp.Position.CanRedeem(redeemer)
+	if !ok {
+		return nil, fmt.Errorf("Address %s cannot redeem the lending at height %d.", redeemer.ToReadable(), opp.Position.Height)
+	}
+	switch opp.Position.Kind {
+	case KindDiamondSystemLending:
+		return &actions.Action_16_DiamondsSystemLendingRansom{
+			LendingID:    opp.LendingId,
+			RansomAmount: *amount,
+		}, nil
+	case KindBitcoinSystemLending:
+		return &actions.Action_18_BitcoinsSystemLendingRansom{
+			LendingID:    opp.LendingId,
+			RansomAmount: *amount,
+		}, nil
+	case KindUserLending:
+		return &actions.Action_20_UsersLendingRansom{
+			LendingID:    opp.LendingId,
+			RansomAmount: *amount,
+		}, nil
+	}
+	return nil, fmt.Errorf("Lending kind <%d> not support.", opp.Position.Kind)
+}
+
+// Create a ransom transaction, the redeemer pays the fee
+func CreateOneTxOfLendingRansom(redeemer *account.Account, opp *Opportunity, fee *fields.Amount, timestamp int64) (*transactions.Transaction_2_Simple, error) {
+	act, e := CreateRansomAction(opp, redeemer.Address)
+	if e != nil {
+		return nil, e
+	}
+	newTrs, e := transactions.NewEmptyTransaction_2_Simple(redeemer.Address)
+	if e != nil {
+		return nil, e
+	}
+	newTrs.Timestamp = fields.BlockTxTimestamp(timestamp) // Use timestamp
+	newTrs.Fee = *fee                                     // set fee
+	e = newTrs.AddAction(act)
+	if e != nil {
+		return nil, e
+	}
+	// Sign private key signature
+	allPrivateKeyBytes := make(map[string][]byte, 1)
+	allPrivateKeyBytes[string(redeemer.Address)] = redeemer.PrivateKey
+	e = newTrs.FillNeedSigns(allPrivateKeyBytes, nil)
+	if e != nil {
+		return nil, e
+	}
+	return newTrs, nil
+}
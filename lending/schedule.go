@@ -0,0 +1,62 @@
+package lending
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Stage and redemption amount from the height
+type SchedulePoint struct {
+	Height   uint64
+	Position *Position
+}
+
+// Schedule of redemption amount between heights [start, end]
+// Points are created at every stage or amount change height and every step blocks (step 0 means no sampling),
+// a point is kept only if the stage or amount is different from the previous one
+func CalculateSchedule(calc PositionCalculator, start, end, step uint64) ([]*SchedulePoint, error) {
+	if end < start {
+		return nil, fmt.Errorf("Schedule end height %d cannot less than start height %d.", end, start)
+	}
+	heightsMap := map[uint64]bool{start: true}
+	for _, h := range calc.ChangeHeights() {
+		if h >= start && h <= end {
+			heightsMap[h] = true
+		}
+	}
+	if step > 0 {
+		for h := start + step; h <= end && h > start; h += step {
+			heightsMap[h] = true
+		}
+	}
+	heights := make([]uint64, 0, len(heightsMap))
+	for h := range heightsMap {
+		heights = append(heights, h)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	// Calculate
+	points := make([]*SchedulePoint, 0)
+	var prev *Position = nil
+	for _, h := range heights {
+		pos, e := calc.Position(h)
+		if e != nil {
+			return nil, e
+		}
+		if prev != nil && prev.Stage == pos.Stage && equalAmount(prev, pos) {
+			continue
+		}
+		points = append(points, &SchedulePoint{
+			Height:   h,
+			Position: pos,
+		})
+		prev = pos
+	}
+	return points, nil
+}
+
+func equalAmount(p1, p2 *Position) bool {
+	if p1.RedeemAmount == nil || p2.RedeemAmount == nil {
+		return p1.RedeemAmount == p2.RedeemAmount
+	}
+	return p1.RedeemAmount.Equal(p2.RedeemAmount)
+}
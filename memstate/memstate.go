@@ -0,0 +1,570 @@
+package memstate
+
+import (
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"sort"
+)
+
+/**
+
+内存状态，用于模拟与测试
+
+*/
+
+const (
+	keyPrefixBalance       = "balance"
+	keyPrefixLockbls       = "lockbls"
+	keyPrefixChannel       = "channel"
+	keyPrefixDiamond       = "diamond"
+	keyPrefixDiamondLend   = "dialend"
+	keyPrefixBitcoinLend   = "btclend"
+	keyPrefixUserLend      = "usrlend"
+	keyPrefixChaswap       = "chaswap"
+	keyPrefixMoveBTCTxHash = "movebtc"
+	keyPrefixGrantLockbls  = "grntlck"
+	keyPrefixRecurringPay  = "rcrpaym"
+	keyPrefixEscrow        = "escrows"
+	keyPrefixDiamondSwap   = "diaswap"
+	keyPrefixTimeLockTrs   = "tmlktrs"
+	keyPrefixDiamondVault  = "diavalt"
+)
+
+// In-memory chain state, all stores are saved as serialized bytes
+// Read returns a new parsed object, the same as the disk state
+type MemoryChainState struct {
+	pendingHeight uint64
+	pendingHash   fields.Hash
+
+	isInTxPool          bool
+	isDatabaseRebuild   bool
+	pending             interfaces.PendingStatus
+	latestStatus        interfaces.LatestStatus
+	latestDiamond       *stores.DiamondSmelt
+	totalSupply         *stores.TotalSupply
+	datas               map[string][]byte
+	txHashs             map[string]fields.BlockHeight
+	blockStoreForAccess interfaces.BlockStore
+}
+
+func NewMemoryChainState(pendingHeight uint64) *MemoryChainState {
+	return &MemoryChainState{
+		pendingHeight: pendingHeight,
+		pendingHash:   make([]byte, 32),
+		totalSupply:   stores.NewTotalSupplyStoreData(),
+		datas:         make(map[string][]byte),
+		txHashs:       make(map[string]fields.BlockHeight),
+	}
+}
+
+// Set pending block height
+func (s *MemoryChainState) SetPendingBlockHeight(height uint64) {
+	s.pendingHeight = height
+}
+
+// Set block store, nil by default
+func (s *MemoryChainState) SetBlockStore(store interfaces.BlockStore) {
+	s.blockStoreForAccess = store
+}
+
+// Copy all data to a new state
+func (s *MemoryChainState) Fork() *MemoryChainState {
+	newstate := NewMemoryChainState(s.pendingHeight)
+	newstate.pendingHash = append([]byte{}, s.pendingHash...)
+	newstate.isInTxPool = s.isInTxPool
+	newstate.isDatabaseRebuild = s.isDatabaseRebuild
+	newstate.pending = s.pending
+	newstate.latestStatus = s.latestStatus
+	newstate.latestDiamond = s.latestDiamond
+	newstate.totalSupply = s.totalSupply.Clone()
+	newstate.blockStoreForAccess = s.blockStoreForAccess
+	for k, v := range s.datas {
+		newstate.datas[k] = v
+	}
+	for k, v := range s.txHashs {
+		newstate.txHashs[k] = v
+	}
+	return newstate
+}
+
+// Same as Fork, for blocks.ForkStateForSimulation
+func (s *MemoryChainState) ForkSubState() interfaces.ChainStateOperation {
+	return s.Fork()
+}
+
+// Traverse all keys of one kind of store in ascending order
+func (s *MemoryChainState) traversalKeys(prefix string, fn func(key []byte) bool) {
+	keys := make([]string, 0)
+	for k := range s.datas {
+		if len(k) > len(prefix) && k[0:len(prefix)] == prefix {
+			keys = append(keys, k[len(prefix):])
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !fn([]byte(k)) {
+			return
+		}
+	}
+}
+
+func (s *MemoryChainState) save(prefix string, key []byte, obj interfaces.Field) error {
+	bts, e := obj.Serialize()
+	if e != nil {
+		return e
+	}
+	s.datas[prefix+string(key)] = bts
+	return nil
+}
+
+func (s *MemoryChainState) load(prefix string, key []byte, obj interfaces.Field) (bool, error) {
+	bts, ok := s.datas[prefix+string(key)]
+	if !ok {
+		return false, nil
+	}
+	_, e := obj.Parse(bts, 0)
+	if e != nil {
+		return false, e
+	}
+	return true, nil
+}
+
+func (s *MemoryChainState) del(prefix string, key []byte) error {
+	delete(s.datas, prefix+string(key))
+	return nil
+}
+
+/***************************** read *****************************/
+
+func (s *MemoryChainState) IsDatabaseVersionRebuildMode() bool {
+	return s.isDatabaseRebuild
+}
+
+func (s *MemoryChainState) IsInTxPool() bool {
+	return s.isInTxPool
+}
+
+func (s *MemoryChainState) GetPendingBlockHeight() uint64 {
+	return s.pendingHeight
+}
+
+func (s *MemoryChainState) GetPendingBlockHash() fields.Hash {
+	return s.pendingHash
+}
+
+func (s *MemoryChainState) ReadLastestDiamond() (*stores.DiamondSmelt, error) {
+	return s.latestDiamond, nil
+}
+
+func (s *MemoryChainState) ReadTotalSupply() (*stores.TotalSupply, error) {
+	return s.totalSupply.Clone(), nil
+}
+
+func (s *MemoryChainState) BlockStoreRead() interfaces.BlockStoreRead {
+	return s.blockStoreForAccess
+}
+
+func (s *MemoryChainState) CheckTxHash(hx fields.Hash) (bool, error) {
+	_, ok := s.txHashs[string(hx)]
+	return ok, nil
+}
+
+func (s *MemoryChainState) ReadTxBelongHeightByHash(hx fields.Hash) (fields.BlockHeight, error) {
+	return s.txHashs[string(hx)], nil
+}
+
+func (s *MemoryChainState) ReadTransactionBytesByHash(hx fields.Hash) (fields.BlockHeight, []byte, error) {
+	return 0, nil, fmt.Errorf("MemoryChainState not support read transaction bytes.")
+}
+
+func (s *MemoryChainState) Balance(addr fields.Address) (*stores.Balance, error) {
+	obj := stores.NewEmptyBalance()
+	ok, e := s.load(keyPrefixBalance, addr, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) Lockbls(id fields.LockblsId) (*stores.Lockbls, error) {
+	obj := &stores.Lockbls{}
+	ok, e := s.load(keyPrefixLockbls, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) Channel(id fields.ChannelId) (*stores.Channel, error) {
+	obj := &stores.Channel{}
+	ok, e := s.load(keyPrefixChannel, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) Diamond(name fields.DiamondName) (*stores.Diamond, error) {
+	obj := &stores.Diamond{}
+	ok, e := s.load(keyPrefixDiamond, name, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) DiamondSystemLending(id fields.DiamondSyslendId) (*stores.DiamondSystemLending, error) {
+	obj := &stores.DiamondSystemLending{}
+	ok, e := s.load(keyPrefixDiamondLend, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) BitcoinSystemLending(id fields.BitcoinSyslendId) (*stores.BitcoinSystemLending, error) {
+	obj := &stores.BitcoinSystemLending{}
+	ok, e := s.load(keyPrefixBitcoinLend, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) UserLending(id fields.UserLendingId) (*stores.UserLending, error) {
+	obj := &stores.UserLending{}
+	ok, e := s.load(keyPrefixUserLend, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) Chaswap(id fields.HashHalfChecker) (*stores.Chaswap, error) {
+	obj := &stores.Chaswap{}
+	ok, e := s.load(keyPrefixChaswap, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) GrantLockbls(id fields.LockblsId) (*stores.GrantLockbls, error) {
+	obj := &stores.GrantLockbls{}
+	ok, e := s.load(keyPrefixGrantLockbls, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) RecurringPayment(id fields.RecurringPaymentId) (*stores.RecurringPayment, error) {
+	obj := &stores.RecurringPayment{}
+	ok, e := s.load(keyPrefixRecurringPay, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) Escrow(id fields.EscrowId) (*stores.Escrow, error) {
+	obj := &stores.Escrow{}
+	ok, e := s.load(keyPrefixEscrow, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) DiamondSwapRecord(offerhx fields.Hash) (*stores.DiamondSwapRecord, error) {
+	obj := &stores.DiamondSwapRecord{}
+	ok, e := s.load(keyPrefixDiamondSwap, offerhx, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) TimeLockTransfer(id fields.TimeLockTransferId) (*stores.TimeLockTransfer, error) {
+	obj := &stores.TimeLockTransfer{}
+	ok, e := s.load(keyPrefixTimeLockTrs, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) DiamondVault(id fields.DiamondVaultId) (*stores.DiamondVault, error) {
+	obj := &stores.DiamondVault{}
+	ok, e := s.load(keyPrefixDiamondVault, id, obj)
+	if !ok || e != nil {
+		return nil, e
+	}
+	return obj, nil
+}
+
+func (s *MemoryChainState) ReadMoveBTCTxHashByTrsNo(trsno uint32) ([]byte, error) {
+	bts, ok := s.datas[keyPrefixMoveBTCTxHash+fmt.Sprintf("%d", trsno)]
+	if !ok {
+		return nil, nil
+	}
+	return bts, nil
+}
+
+/***************************** operate *****************************/
+
+func (s *MemoryChainState) SetDatabaseVersionRebuildMode(set bool) {
+	s.isDatabaseRebuild = set
+}
+
+func (s *MemoryChainState) SetInTxPool(set bool) {
+	s.isInTxPool = set
+}
+
+func (s *MemoryChainState) GetPending() interfaces.PendingStatus {
+	return s.pending
+}
+
+func (s *MemoryChainState) SetPending(pd interfaces.PendingStatus) error {
+	s.pending = pd
+	if pd != nil {
+		s.pendingHeight = pd.GetPendingBlockHeight()
+		s.pendingHash = pd.GetPendingBlockHash()
+	}
+	return nil
+}
+
+func (s *MemoryChainState) LatestStatusRead() (interfaces.LatestStatus, error) {
+	return s.latestStatus, nil
+}
+
+func (s *MemoryChainState) LatestStatusSet(status interfaces.LatestStatus) error {
+	s.latestStatus = status
+	if status != nil {
+		s.latestDiamond = status.ReadLastestDiamond()
+	}
+	return nil
+}
+
+func (s *MemoryChainState) UpdateSetTotalSupply(totalobj *stores.TotalSupply) error {
+	s.totalSupply = totalobj.Clone()
+	return nil
+}
+
+func (s *MemoryChainState) BlockStore() interfaces.BlockStore {
+	return s.blockStoreForAccess
+}
+
+func (s *MemoryChainState) ContainTxHash(hx fields.Hash, height fields.BlockHeight) error {
+	s.txHashs[string(hx)] = height
+	return nil
+}
+
+func (s *MemoryChainState) RemoveTxHash(hx fields.Hash) error {
+	delete(s.txHashs, string(hx))
+	return nil
+}
+
+func (s *MemoryChainState) BalanceSet(addr fields.Address, obj *stores.Balance) error {
+	return s.save(keyPrefixBalance, addr, obj)
+}
+
+func (s *MemoryChainState) BalanceDel(addr fields.Address) error {
+	return s.del(keyPrefixBalance, addr)
+}
+
+func (s *MemoryChainState) LockblsCreate(id fields.LockblsId, obj *stores.Lockbls) error {
+	return s.save(keyPrefixLockbls, id, obj)
+}
+
+func (s *MemoryChainState) LockblsUpdate(id fields.LockblsId, obj *stores.Lockbls) error {
+	return s.save(keyPrefixLockbls, id, obj)
+}
+
+func (s *MemoryChainState) LockblsDelete(id fields.LockblsId) error {
+	return s.del(keyPrefixLockbls, id)
+}
+
+func (s *MemoryChainState) ChannelCreate(id fields.ChannelId, obj *stores.Channel) error {
+	return s.save(keyPrefixChannel, id, obj)
+}
+
+func (s *MemoryChainState) ChannelUpdate(id fields.ChannelId, obj *stores.Channel) error {
+	return s.save(keyPrefixChannel, id, obj)
+}
+
+func (s *MemoryChainState) ChannelDelete(id fields.ChannelId) error {
+	return s.del(keyPrefixChannel, id)
+}
+
+func (s *MemoryChainState) DiamondSet(name fields.DiamondName, obj *stores.Diamond) error {
+	return s.save(keyPrefixDiamond, name, obj)
+}
+
+func (s *MemoryChainState) DiamondDel(name fields.DiamondName) error {
+	return s.del(keyPrefixDiamond, name)
+}
+
+func (s *MemoryChainState) DiamondLendingCreate(id fields.DiamondSyslendId, obj *stores.DiamondSystemLending) error {
+	return s.save(keyPrefixDiamondLend, id, obj)
+}
+
+func (s *MemoryChainState) DiamondLendingUpdate(id fields.DiamondSyslendId, obj *stores.DiamondSystemLending) error {
+	return s.save(keyPrefixDiamondLend, id, obj)
+}
+
+func (s *MemoryChainState) DiamondLendingDelete(id fields.DiamondSyslendId) error {
+	return s.del(keyPrefixDiamondLend, id)
+}
+
+func (s *MemoryChainState) BitcoinLendingCreate(id fields.BitcoinSyslendId, obj *stores.BitcoinSystemLending) error {
+	return s.save(keyPrefixBitcoinLend, id, obj)
+}
+
+func (s *MemoryChainState) BitcoinLendingUpdate(id fields.BitcoinSyslendId, obj *stores.BitcoinSystemLending) error {
+	return s.save(keyPrefixBitcoinLend, id, obj)
+}
+
+func (s *MemoryChainState) BitcoinLendingDelete(id fields.BitcoinSyslendId) error {
+	return s.del(keyPrefixBitcoinLend, id)
+}
+
+func (s *MemoryChainState) UserLendingCreate(id fields.UserLendingId, obj *stores.UserLending) error {
+	return s.save(keyPrefixUserLend, id, obj)
+}
+
+func (s *MemoryChainState) UserLendingUpdate(id fields.UserLendingId, obj *stores.UserLending) error {
+	return s.save(keyPrefixUserLend, id, obj)
+}
+
+func (s *MemoryChainState) UserLendingDelete(id fields.UserLendingId) error {
+	return s.del(keyPrefixUserLend, id)
+}
+
+func (s *MemoryChainState) ChaswapCreate(id fields.HashHalfChecker, obj *stores.Chaswap) error {
+	return s.save(keyPrefixChaswap, id, obj)
+}
+
+func (s *MemoryChainState) ChaswapUpdate(id fields.HashHalfChecker, obj *stores.Chaswap) error {
+	return s.save(keyPrefixChaswap, id, obj)
+}
+
+func (s *MemoryChainState) ChaswapDelete(id fields.HashHalfChecker) error {
+	return s.del(keyPrefixChaswap, id)
+}
+
+func (s *MemoryChainState) GrantLockblsCreate(id fields.LockblsId, obj *stores.GrantLockbls) error {
+	return s.save(keyPrefixGrantLockbls, id, obj)
+}
+
+func (s *MemoryChainState) GrantLockblsUpdate(id fields.LockblsId, obj *stores.GrantLockbls) error {
+	return s.save(keyPrefixGrantLockbls, id, obj)
+}
+
+func (s *MemoryChainState) GrantLockblsDelete(id fields.LockblsId) error {
+	return s.del(keyPrefixGrantLockbls, id)
+}
+
+func (s *MemoryChainState) RecurringPaymentCreate(id fields.RecurringPaymentId, obj *stores.RecurringPayment) error {
+	return s.save(keyPrefixRecurringPay, id, obj)
+}
+
+func (s *MemoryChainState) RecurringPaymentUpdate(id fields.RecurringPaymentId, obj *stores.RecurringPayment) error {
+	return s.save(keyPrefixRecurringPay, id, obj)
+}
+
+func (s *MemoryChainState) RecurringPaymentDelete(id fields.RecurringPaymentId) error {
+	return s.del(keyPrefixRecurringPay, id)
+}
+
+func (s *MemoryChainState) EscrowCreate(id fields.EscrowId, obj *stores.Escrow) error {
+	return s.save(keyPrefixEscrow, id, obj)
+}
+
+func (s *MemoryChainState) EscrowUpdate(id fields.EscrowId, obj *stores.Escrow) error {
+	return s.save(keyPrefixEscrow, id, obj)
+}
+
+func (s *MemoryChainState) EscrowDelete(id fields.EscrowId) error {
+	return s.del(keyPrefixEscrow, id)
+}
+
+func (s *MemoryChainState) DiamondSwapRecordCreate(offerhx fields.Hash, obj *stores.DiamondSwapRecord) error {
+	return s.save(keyPrefixDiamondSwap, offerhx, obj)
+}
+
+func (s *MemoryChainState) DiamondSwapRecordDelete(offerhx fields.Hash) error {
+	return s.del(keyPrefixDiamondSwap, offerhx)
+}
+
+func (s *MemoryChainState) TimeLockTransferCreate(id fields.TimeLockTransferId, obj *stores.TimeLockTransfer) error {
+	return s.save(keyPrefixTimeLockTrs, id, obj)
+}
+
+func (s *MemoryChainState) TimeLockTransferUpdate(id fields.TimeLockTransferId, obj *stores.TimeLockTransfer) error {
+	return s.save(keyPrefixTimeLockTrs, id, obj)
+}
+
+func (s *MemoryChainState) TimeLockTransferDelete(id fields.TimeLockTransferId) error {
+	return s.del(keyPrefixTimeLockTrs, id)
+}
+
+func (s *MemoryChainState) DiamondVaultCreate(id fields.DiamondVaultId, obj *stores.DiamondVault) error {
+	return s.save(keyPrefixDiamondVault, id, obj)
+}
+
+func (s *MemoryChainState) DiamondVaultUpdate(id fields.DiamondVaultId, obj *stores.DiamondVault) error {
+	return s.save(keyPrefixDiamondVault, id, obj)
+}
+
+func (s *MemoryChainState) DiamondVaultDelete(id fields.DiamondVaultId) error {
+	return s.del(keyPrefixDiamondVault, id)
+}
+
+func (s *MemoryChainState) SaveMoveBTCBelongTxHash(trsno uint32, txhash []byte) error {
+	s.datas[keyPrefixMoveBTCTxHash+fmt.Sprintf("%d", trsno)] = append([]byte{}, txhash...)
+	return nil
+}
+
+/***************************** traversal *****************************/
+
+func (s *MemoryChainState) TraversalDiamondSystemLending(fn func(fields.DiamondSyslendId, *stores.DiamondSystemLending) bool) error {
+	var err error = nil
+	s.traversalKeys(keyPrefixDiamondLend, func(key []byte) bool {
+		obj, e := s.DiamondSystemLending(key)
+		if e != nil {
+			err = e
+			return false
+		}
+		return fn(key, obj)
+	})
+	return err
+}
+
+func (s *MemoryChainState) TraversalBitcoinSystemLending(fn func(fields.BitcoinSyslendId, *stores.BitcoinSystemLending) bool) error {
+	var err error = nil
+	s.traversalKeys(keyPrefixBitcoinLend, func(key []byte) bool {
+		obj, e := s.BitcoinSystemLending(key)
+		if e != nil {
+			err = e
+			return false
+		}
+		return fn(key, obj)
+	})
+	return err
+}
+
+func (s *MemoryChainState) TraversalUserLending(fn func(fields.UserLendingId, *stores.UserLending) bool) error {
+	var err error = nil
+	s.traversalKeys(keyPrefixUserLend, func(key []byte) bool {
+		obj, e := s.UserLending(key)
+		if e != nil {
+			err = e
+			return false
+		}
+		return fn(key, obj)
+	})
+	return err
+}
@@ -0,0 +1,133 @@
+package miningpool
+
+import (
+	"bytes"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/blocks"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/memstate"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+	"testing"
+)
+
+func createTestTemplate(t *testing.T, height uint64, miner fields.Address) *blocks.BlockTemplate {
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	state := memstate.NewMemoryChainState(height)
+	state.BalanceSet(acc1.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(10)))
+	txs := make([]interfaces.Transaction, 0)
+	for i := int64(1); i <= 3; i++ {
+		txs = append(txs, transactions.CreateOneTxOfSimpleTransfer(acc1, acc2.Address, fields.NewAmountByUnitMei(1), fields.NewAmountByUnit(i, 244), i))
+	}
+	tpl, e := blocks.CreateBlockTemplate(nil, state, txs, &blocks.BlockTemplateConfig{
+		RewardAddress: miner,
+		Reward:        fields.NewAmountByUnitMei(1),
+		Difficulty:    1000,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	tpl.Block.Timestamp = fields.BlockTxTimestamp(1600000000 + height) // Pin the block hash
+	return tpl
+}
+
+func Test1(t *testing.T) {
+
+	if !bytes.Equal(TargetByLeadingZeroBits(12), append([]byte{0, 0x0f}, bytes.Repeat([]byte{255}, 30)...)) {
+		t.Fatal("target by zero bits error")
+	}
+	target := TargetByLeadingZeroBits(8)
+	if !CheckHashTarget(append([]byte{0}, bytes.Repeat([]byte{255}, 31)...), target) ||
+		CheckHashTarget(append([]byte{1}, make([]byte, 31)...), target) {
+		t.Fatal("check hash target error")
+	}
+	if TargetWeight(target).Cmp(TargetWeight(TargetByLeadingZeroBits(10))) >= 0 {
+		t.Fatal("target weight error")
+	}
+	pool := NewMiningPool(nil, 0)
+	if !pool.ShareTarget.Equal(TargetByLeadingZeroBits(DefaultShareTargetZeroBit)) || pool.PPLNSWindow != DefaultPPLNSWindow {
+		t.Fatal("pool default setting error")
+	}
+
+}
+
+func Test2(t *testing.T) {
+
+	pooladdr := account.CreateAccountByPassword("pool").Address
+	addr1 := account.CreateAccountByPassword("worker1").Address
+	addr2 := account.CreateAccountByPassword("worker2").Address
+
+	// Every hash satisfies the share and block target, accepted shares not depend on the hash
+	maxtarget := fields.Hash(bytes.Repeat([]byte{255}, 32))
+	pool := NewMiningPool(maxtarget, 4)
+	pool.RegisterWorker("w1", addr1)
+	pool.RegisterWorker("w2", addr2)
+	tpl := createTestTemplate(t, 10, pooladdr)
+	job, e := pool.NewJob(tpl, maxtarget)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Different nonce space for each worker
+	work1, _ := pool.CreateWorkUnit("w1")
+	work2, _ := pool.CreateWorkUnit("w2")
+	if work1.JobId != job.Id || bytes.Equal(work1.ExtraNonce1, work2.ExtraNonce1) {
+		t.Fatal("work unit error")
+	}
+	extra := bytes.Repeat([]byte{7}, ExtraNonce2Size)
+	mrkl1, _ := work1.CalculateMrklRoot(extra)
+	mrkl2, _ := work2.CalculateMrklRoot(extra)
+	if mrkl1.Equal(mrkl2) {
+		t.Fatal("merkle root must be different")
+	}
+
+	// Submit and reconstruct full block
+	res, e := pool.SubmitShare(&Share{WorkerName: "w1", JobId: job.Id, ExtraNonce2: extra, Nonce: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if res.Block == nil || res.Block.GetTransactionCount() != 4 || !res.Block.GetMrklRoot().Equal(mrkl1) {
+		t.Fatal("full block error")
+	}
+	cbtx := res.Block.GetTrsList()[0].(*transactions.Transaction_0_Coinbase)
+	if !bytes.Equal(cbtx.MinerNonce, append(append([]byte{}, work1.ExtraNonce1...), extra...)) ||
+		!cbtx.TotalFeeUserPayed.Equal(&tpl.Coinbase.TotalFeeUserPayed) {
+		t.Fatal("coinbase error")
+	}
+	if _, e := pool.SubmitShare(&Share{WorkerName: "w1", JobId: job.Id, ExtraNonce2: extra, Nonce: 1}); e == nil {
+		t.Fatal("duplicate share check error")
+	}
+	if _, e := pool.SubmitShare(&Share{WorkerName: "w1", JobId: job.Id, ExtraNonce2: extra[1:], Nonce: 2}); e == nil {
+		t.Fatal("extra nonce size check error")
+	}
+	pool.SubmitShare(&Share{WorkerName: "w1", JobId: job.Id, ExtraNonce2: extra, Nonce: 2})
+	pool.SubmitShare(&Share{WorkerName: "w2", JobId: job.Id, ExtraNonce2: extra, Nonce: 1})
+
+	// New height makes old job stale
+	job2, _ := pool.NewJob(createTestTemplate(t, 11, pooladdr), job.BlockTarget)
+	if _, e := pool.SubmitShare(&Share{WorkerName: "w1", JobId: job.Id, ExtraNonce2: extra, Nonce: 3}); e == nil {
+		t.Fatal("stale job check error")
+	}
+	pool.SubmitShare(&Share{WorkerName: "w1", JobId: job2.Id, ExtraNonce2: extra, Nonce: 3})
+	pool.SubmitShare(&Share{WorkerName: "w2", JobId: job2.Id, ExtraNonce2: extra, Nonce: 3})
+	wk1 := pool.Worker("w1")
+	if wk1.AcceptedShares != 3 || wk1.RejectedShares != 3 {
+		t.Fatal("worker statistics error")
+	}
+
+	// PPLNS window 4: w1 two shares, w2 two shares
+	payouts, e := pool.PPLNSPayouts(fields.NewAmountByUnitMei(4))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(payouts) != 2 || !payouts[fields.Address(addr1).ToReadable()].Equal(fields.NewAmountByUnitMei(2)) ||
+		!payouts[fields.Address(addr2).ToReadable()].Equal(fields.NewAmountByUnitMei(2)) {
+		t.Fatal("pplns payouts error")
+	}
+	if len(pool.WorkerShareStats()) != 2 {
+		t.Fatal("worker share stats error")
+	}
+
+}
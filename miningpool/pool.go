@@ -0,0 +1,271 @@
+package miningpool
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hacash/core/blocks"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	MaxKeepJobs               = 8
+	DefaultPPLNSWindow        = 10000
+	DefaultShareTargetZeroBit = 16
+)
+
+// Mining job, create from a block template
+type Job struct {
+	Id          uint32
+	Template    *blocks.BlockTemplate
+	MrklBranch  []fields.Hash
+	BlockTarget fields.Hash
+	CreateTime  time.Time
+}
+
+type Worker struct {
+	Name          string
+	RewardAddress fields.Address
+	ExtraNonce1   fields.Bytes8
+	// statistics
+	AcceptedShares uint64
+	RejectedShares uint64
+	FoundBlocks    uint64
+	LastShareTime  time.Time
+}
+
+// Share submitted by a worker
+type Share struct {
+	WorkerName  string
+	JobId       uint32
+	ExtraNonce2 []byte
+	Nonce       uint32
+}
+
+type ShareResult struct {
+	Hash   fields.Hash
+	Weight *big.Int
+	// Not nil if the share satisfy block target
+	Block *blocks.Block_v1
+}
+
+type shareRecord struct {
+	worker *Worker
+	weight *big.Int
+}
+
+type MiningPool struct {
+	ShareTarget fields.Hash
+	PPLNSWindow int // Count of last shares for payout
+
+	jobs         map[uint32]*Job
+	latestJob    *Job
+	jobAutoId    uint32
+	workers      map[string]*Worker
+	extraAutoId  uint64
+	submitted    map[string]bool
+	shares       []*shareRecord
+	shareTotalWt *big.Int
+
+	lock sync.Mutex
+}
+
+func NewMiningPool(sharetarget fields.Hash, pplnswindow int) *MiningPool {
+	if len(sharetarget) != 32 {
+		sharetarget = TargetByLeadingZeroBits(DefaultShareTargetZeroBit)
+	}
+	if pplnswindow <= 0 {
+		pplnswindow = DefaultPPLNSWindow
+	}
+	return &MiningPool{
+		ShareTarget:  sharetarget,
+		PPLNSWindow:  pplnswindow,
+		jobs:         make(map[uint32]*Job),
+		workers:      make(map[string]*Worker),
+		submitted:    make(map[string]bool),
+		shares:       make([]*shareRecord, 0),
+		shareTotalWt: big.NewInt(0),
+	}
+}
+
+// Register or get a worker, each worker has a unique extra nonce 1
+func (p *MiningPool) RegisterWorker(name string, rewardaddr fields.Address) (*Worker, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(rewardaddr) != fields.AddressSize {
+		return nil, fmt.Errorf("worker reward address error")
+	}
+	if wk, ok := p.workers[name]; ok {
+		wk.RewardAddress = rewardaddr
+		return wk, nil
+	}
+	p.extraAutoId++
+	extra := make([]byte, ExtraNonce1Size)
+	binary.BigEndian.PutUint64(extra, p.extraAutoId)
+	wk := &Worker{
+		Name:          name,
+		RewardAddress: rewardaddr,
+		ExtraNonce1:   extra,
+	}
+	p.workers[name] = wk
+	return wk, nil
+}
+
+func (p *MiningPool) Worker(name string) *Worker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.workers[name]
+}
+
+// Create a new job, jobs of old height will be stale
+func (p *MiningPool) NewJob(tpl *blocks.BlockTemplate, blocktarget fields.Hash) (*Job, error) {
+	if tpl == nil || tpl.Block == nil || tpl.Coinbase == nil {
+		return nil, fmt.Errorf("block template is empty")
+	}
+	if len(blocktarget) != 32 {
+		return nil, fmt.Errorf("block target size error")
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.latestJob != nil && p.latestJob.Template.Block.GetHeight() != tpl.Block.GetHeight() {
+		p.jobs = make(map[uint32]*Job) // clear stale
+		p.submitted = make(map[string]bool)
+	}
+	p.jobAutoId++
+	job := &Job{
+		Id:          p.jobAutoId,
+		Template:    tpl,
+		MrklBranch:  blocks.PickMrklListForCoinbaseTxModify(tpl.Block.GetTrsList()),
+		BlockTarget: blocktarget,
+		CreateTime:  time.Now(),
+	}
+	p.jobs[job.Id] = job
+	p.latestJob = job
+	// Drop oldest
+	if len(p.jobs) > MaxKeepJobs {
+		delete(p.jobs, job.Id-MaxKeepJobs)
+	}
+	return job, nil
+}
+
+// Work unit of latest job for a worker
+func (p *MiningPool) CreateWorkUnit(workername string) (*WorkUnit, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	wk, ok := p.workers[workername]
+	if !ok {
+		return nil, fmt.Errorf("worker <%s> not find", workername)
+	}
+	if p.latestJob == nil {
+		return nil, fmt.Errorf("no mining job")
+	}
+	return p.createWorkUnit(p.latestJob, wk), nil
+}
+
+func (p *MiningPool) createWorkUnit(job *Job, wk *Worker) *WorkUnit {
+	block := job.Template.Block
+	return &WorkUnit{
+		JobId:            job.Id,
+		Height:           block.GetHeight(),
+		Timestamp:        block.GetTimestamp(),
+		PrevHash:         block.GetPrevHash(),
+		TransactionCount: block.GetTransactionCount(),
+		Difficulty:       block.GetDifficulty(),
+		WitnessStage:     uint16(block.WitnessStage),
+		Coinbase:         job.Template.Coinbase,
+		ExtraNonce1:      wk.ExtraNonce1,
+		MrklBranch:       job.MrklBranch,
+		ShareTarget:      p.ShareTarget,
+	}
+}
+
+// Validate and record a share
+// Return the full block if the share satisfy block target
+func (p *MiningPool) SubmitShare(share *Share) (*ShareResult, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	wk, ok := p.workers[share.WorkerName]
+	if !ok {
+		return nil, fmt.Errorf("worker <%s> not find", share.WorkerName)
+	}
+	var reject = func(err error) (*ShareResult, error) {
+		wk.RejectedShares++
+		return nil, err
+	}
+	job, ok := p.jobs[share.JobId]
+	if !ok {
+		return reject(fmt.Errorf("job <%d> not find or stale", share.JobId))
+	}
+	if len(share.ExtraNonce2) != ExtraNonce2Size {
+		return reject(fmt.Errorf("extra nonce 2 size must be %d", ExtraNonce2Size))
+	}
+	// Duplicate check
+	subkey := fmt.Sprintf("%d:%x:%x:%d", job.Id, wk.ExtraNonce1, share.ExtraNonce2, share.Nonce)
+	if p.submitted[subkey] {
+		return reject(fmt.Errorf("duplicate share"))
+	}
+	// Check hash
+	work := p.createWorkUnit(job, wk)
+	blockhead, e := work.CreateBlockHead(share.ExtraNonce2, share.Nonce)
+	if e != nil {
+		return reject(e)
+	}
+	hash := blocks.CalculateBlockHash(blockhead)
+	if !CheckHashTarget(hash, p.ShareTarget) {
+		return reject(fmt.Errorf("share hash <%s> not satisfy share target", hash.ToHex()))
+	}
+	// Accept
+	p.submitted[subkey] = true
+	result := &ShareResult{
+		Hash:   hash,
+		Weight: TargetWeight(p.ShareTarget),
+	}
+	p.addShare(wk, result.Weight)
+	wk.AcceptedShares++
+	wk.LastShareTime = time.Now()
+	if CheckHashTarget(hash, job.BlockTarget) {
+		block, e := p.createFullBlock(job, work, share)
+		if e != nil {
+			return nil, e
+		}
+		wk.FoundBlocks++
+		result.Block = block
+	}
+	return result, nil
+}
+
+// Reconstruct the full block with transactions
+func (p *MiningPool) createFullBlock(job *Job, work *WorkUnit, share *Share) (*blocks.Block_v1, error) {
+	coinbase, e := work.CreateCoinbase(share.ExtraNonce2)
+	if e != nil {
+		return nil, e
+	}
+	coinbase.TotalFeeUserPayed = job.Template.Coinbase.TotalFeeUserPayed
+	coinbase.TotalFeeMinerReceived = job.Template.Coinbase.TotalFeeMinerReceived
+	tpblock := job.Template.Block
+	block := blocks.NewEmptyBlockV1()
+	block.Height = tpblock.Height
+	block.Timestamp = tpblock.Timestamp
+	block.PrevHash = tpblock.PrevHash
+	block.Difficulty = tpblock.Difficulty
+	block.WitnessStage = tpblock.WitnessStage
+	block.Nonce = fields.VarUint4(share.Nonce)
+	block.AddTrs(coinbase)
+	for _, tx := range job.Template.Included {
+		block.AddTrs(tx)
+	}
+	block.SetMrklRoot(blocks.CalculateMrklRoot(block.GetTrsList()))
+	// Check merkle root calculated by branch
+	mrklroot, _ := work.CalculateMrklRoot(share.ExtraNonce2)
+	if !block.MrklRoot.Equal(mrklroot) {
+		return nil, fmt.Errorf("merkle root of full block not match")
+	}
+	return block, nil
+}
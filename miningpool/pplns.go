@@ -0,0 +1,100 @@
+package miningpool
+
+import (
+	"math/big"
+
+	"github.com/hacash/core/fields"
+)
+
+/**
+ * PPLNS: pay per last N shares
+ * The block reward is split by the share weight of the last N accepted shares
+ */
+
+const (
+	PayoutMinUnit = 240 // Payout amount round down to 1 zhu
+)
+
+type WorkerShareStat struct {
+	Worker *Worker
+	Weight *big.Int
+}
+
+func (p *MiningPool) addShare(wk *Worker, weight *big.Int) {
+	p.shares = append(p.shares, &shareRecord{
+		worker: wk,
+		weight: weight,
+	})
+	p.shareTotalWt.Add(p.shareTotalWt, weight)
+	// Keep window
+	if drop := len(p.shares) - p.PPLNSWindow; drop > 0 {
+		for _, s := range p.shares[0:drop] {
+			p.shareTotalWt.Sub(p.shareTotalWt, s.weight)
+		}
+		p.shares = append([]*shareRecord{}, p.shares[drop:]...)
+	}
+}
+
+// Share weight of each reward address in the window
+func (p *MiningPool) PPLNSWeights() (map[string]*big.Int, *big.Int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	weights := make(map[string]*big.Int)
+	for _, s := range p.shares {
+		addr := s.worker.RewardAddress.ToReadable()
+		if wt, ok := weights[addr]; ok {
+			wt.Add(wt, s.weight)
+		} else {
+			weights[addr] = new(big.Int).Set(s.weight)
+		}
+	}
+	return weights, new(big.Int).Set(p.shareTotalWt)
+}
+
+// Split reward to reward address by PPLNS weights
+// The remainder of rounding down is not paid out
+func (p *MiningPool) PPLNSPayouts(reward *fields.Amount) (map[string]*fields.Amount, error) {
+	weights, totalwt := p.PPLNSWeights()
+	payouts := make(map[string]*fields.Amount)
+	if totalwt.Sign() == 0 {
+		return payouts, nil
+	}
+	minunit := new(big.Int).Exp(big.NewInt(10), big.NewInt(PayoutMinUnit), nil)
+	rwdnum := reward.GetValue()
+	for addr, wt := range weights {
+		num := new(big.Int).Mul(rwdnum, wt)
+		num.Div(num, totalwt)
+		num.Div(num, minunit) // round down
+		amt, e := fields.NewAmountByBigIntWithUnit(num, PayoutMinUnit)
+		if e != nil {
+			return nil, e
+		}
+		if amt.IsPositive() {
+			payouts[addr] = amt
+		}
+	}
+	return payouts, nil
+}
+
+// Statistics of each worker in the window
+func (p *MiningPool) WorkerShareStats() []*WorkerShareStat {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := make([]*WorkerShareStat, 0)
+	idxs := make(map[*Worker]*WorkerShareStat)
+	for _, s := range p.shares {
+		if st, ok := idxs[s.worker]; ok {
+			st.Weight.Add(st.Weight, s.weight)
+			continue
+		}
+		st := &WorkerShareStat{
+			Worker: s.worker,
+			Weight: new(big.Int).Set(s.weight),
+		}
+		idxs[s.worker] = st
+		stats = append(stats, st)
+	}
+	return stats
+}
@@ -0,0 +1,119 @@
+package miningpool
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/hacash/core/blocks"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/transactions"
+)
+
+/**
+ * Coinbase MinerNonce (32 bytes) is split into two parts:
+ * [0:8]  extra nonce 1, assigned by the pool, unique for each worker
+ * [8:32] extra nonce 2, free nonce space of the worker
+ * The worker changes extra nonce 2 and the block head nonce,
+ * then recalculates the merkle root by the merkle branch of coinbase
+ */
+
+const (
+	ExtraNonce1Size = 8
+	ExtraNonce2Size = 32 - ExtraNonce1Size
+)
+
+// Work unit send to a worker
+type WorkUnit struct {
+	JobId uint32
+	// block head meta
+	Height           uint64
+	Timestamp        uint64
+	PrevHash         fields.Hash
+	TransactionCount uint32
+	Difficulty       uint32
+	WitnessStage     uint16
+	// coinbase template, MinerNonce prefix is extra nonce 1
+	Coinbase    *transactions.Transaction_0_Coinbase
+	ExtraNonce1 fields.Bytes8
+	MrklBranch  []fields.Hash
+	ShareTarget fields.Hash
+}
+
+// Coinbase with the full miner nonce
+func (w *WorkUnit) CreateCoinbase(extranonce2 []byte) (*transactions.Transaction_0_Coinbase, error) {
+	if len(extranonce2) != ExtraNonce2Size {
+		return nil, fmt.Errorf("extra nonce 2 size must be %d", ExtraNonce2Size)
+	}
+	coinbase := w.Coinbase.CopyForMining()
+	coinbase.ExtendDataVersion = 1
+	coinbase.MinerNonce = append(append([]byte{}, w.ExtraNonce1...), extranonce2...)
+	return coinbase, nil
+}
+
+func (w *WorkUnit) CalculateMrklRoot(extranonce2 []byte) (fields.Hash, error) {
+	coinbase, e := w.CreateCoinbase(extranonce2)
+	if e != nil {
+		return nil, e
+	}
+	return blocks.CalculateMrklRootByCoinbaseTxModify(coinbase.Hash(), w.MrklBranch), nil
+}
+
+// Block with head and meta only, no transactions body
+func (w *WorkUnit) CreateBlockHead(extranonce2 []byte, nonce uint32) (*blocks.Block_v1, error) {
+	mrklroot, e := w.CalculateMrklRoot(extranonce2)
+	if e != nil {
+		return nil, e
+	}
+	block := blocks.NewEmptyBlockV1()
+	block.Height = fields.BlockHeight(w.Height)
+	block.Timestamp = fields.BlockTxTimestamp(w.Timestamp)
+	block.PrevHash = w.PrevHash
+	block.MrklRoot = mrklroot
+	block.TransactionCount = fields.VarUint4(w.TransactionCount)
+	block.Nonce = fields.VarUint4(nonce)
+	block.Difficulty = fields.VarUint4(w.Difficulty)
+	block.WitnessStage = fields.VarUint2(w.WitnessStage)
+	return block, nil
+}
+
+func (w *WorkUnit) CalculateBlockHash(extranonce2 []byte, nonce uint32) (fields.Hash, error) {
+	block, e := w.CreateBlockHead(extranonce2, nonce)
+	if e != nil {
+		return nil, e
+	}
+	return blocks.CalculateBlockHash(block), nil
+}
+
+// Hash must be less than or equal to the target
+func CheckHashTarget(hash fields.Hash, target fields.Hash) bool {
+	if len(hash) != 32 || len(target) != 32 {
+		return false
+	}
+	return bytes.Compare(hash, target) <= 0
+}
+
+// Share weight of a target: max hash / target
+func TargetWeight(target fields.Hash) *big.Int {
+	maxhash := new(big.Int).SetBytes(bytes.Repeat([]byte{255}, 32))
+	tarnum := new(big.Int).SetBytes(target)
+	if tarnum.Sign() == 0 {
+		return maxhash
+	}
+	return maxhash.Div(maxhash, tarnum)
+}
+
+// Target with leading zero bits, for share difficulty setting
+func TargetByLeadingZeroBits(bits int) fields.Hash {
+	if bits < 0 {
+		bits = 0
+	}
+	if bits > 255 {
+		bits = 255
+	}
+	tarnum := new(big.Int).Rsh(new(big.Int).SetBytes(bytes.Repeat([]byte{255}, 32)), uint(bits))
+	target := make([]byte, 32)
+	tarbts := tarnum.Bytes()
+	copy(target[32-len(tarbts):], tarbts)
+	return target
+}
@@ -13,10 +13,13 @@ const (
 	DiamondStatusNormal           fields.VarUint1 = 0
 	DiamondStatusLendingSystem    fields.VarUint1 = 1
 	DiamondStatusLendingOtherUser fields.VarUint1 = 2
+	DiamondStatusEscrow           fields.VarUint1 = 3
+	DiamondStatusTimeLocked       fields.VarUint1 = 4
+	DiamondStatusVault            fields.VarUint1 = 5
 )
 
 type Diamond struct {
-	Status  fields.VarUint1 // Status 0 Normally available and transferable 1 Mortgage to system 2 Mortgage to other users
+	Status  fields.VarUint1 // Status 0 Normally available and transferable 1 Mortgage to system 2 Mortgage to other users 3 Locked in escrow 4 Time locked 5 Locked in vault
 	Address fields.Address
 	// engraved info
 	EngravedPrevBlockHeight fields.BlockHeight
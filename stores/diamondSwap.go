@@ -0,0 +1,52 @@
+package stores
+
+import (
+	"bytes"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	DiamondSwapOfferStatusFilled    fields.VarUint1 = 1
+	DiamondSwapOfferStatusCancelled fields.VarUint1 = 2
+)
+
+// Record of a filled or cancelled diamond swap offer, stored by the offer hash to avoid replay
+type DiamondSwapRecord struct {
+	Status        fields.VarUint1
+	SellerAddress fields.Address
+	BlockHeight   fields.BlockHeight // Filled or cancelled at
+}
+
+func (elm *DiamondSwapRecord) Size() uint32 {
+	return elm.Status.Size() +
+		elm.SellerAddress.Size() +
+		elm.BlockHeight.Size()
+}
+
+func (elm *DiamondSwapRecord) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Status.Serialize()
+	var b2, _ = elm.SellerAddress.Serialize()
+	var b3, _ = elm.BlockHeight.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	return buffer.Bytes(), nil
+}
+
+func (elm *DiamondSwapRecord) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.Status.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
@@ -0,0 +1,207 @@
+package stores
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	DiamondVaultIdLength = 16
+
+	DiamondVaultHolderMaxCount = 200
+)
+
+const (
+	DiamondVaultStatusActive    fields.VarUint1 = 0
+	DiamondVaultStatusRedeemed  fields.VarUint1 = 1 // Redeemed by the holder of all shares
+	DiamondVaultStatusBoughtOut fields.VarUint1 = 2 // Bought out at the buyout price
+)
+
+// One share holder
+type DiamondVaultHolder struct {
+	Address fields.Address
+	Shares  fields.VarUint4
+}
+
+func (elm *DiamondVaultHolder) Size() uint32 {
+	return elm.Address.Size() + elm.Shares.Size()
+}
+
+func (elm *DiamondVaultHolder) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Address.Serialize()
+	var b2, _ = elm.Shares.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *DiamondVaultHolder) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.Address.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Shares.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// Diamonds locked in the vault and represented by transferable shares
+type DiamondVault struct {
+	Status fields.VarUint1
+
+	CreatorAddress      fields.Address
+	DiamondList         fields.DiamondListMaxLen200
+	TotalShares         fields.VarUint4
+	BuyoutPricePerShare fields.Amount // Anyone can buy out the other holders at this price
+
+	HolderCount fields.VarUint1
+	Holders     []*DiamondVaultHolder
+
+	CloseBlockHeight fields.BlockHeight // Redeemed or bought out at
+}
+
+func (elm *DiamondVault) IsClosed() bool {
+	return elm.Status != DiamondVaultStatusActive
+}
+
+func (elm *DiamondVault) Size() uint32 {
+	size := elm.Status.Size() +
+		elm.CreatorAddress.Size() +
+		elm.DiamondList.Size() +
+		elm.TotalShares.Size() +
+		elm.BuyoutPricePerShare.Size() +
+		elm.HolderCount.Size() +
+		elm.CloseBlockHeight.Size()
+	for _, v := range elm.Holders {
+		size += v.Size()
+	}
+	return size
+}
+
+func (elm *DiamondVault) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Status.Serialize()
+	var b2, _ = elm.CreatorAddress.Serialize()
+	var b3, e1 = elm.DiamondList.Serialize()
+	if e1 != nil {
+		return nil, e1
+	}
+	var b4, _ = elm.TotalShares.Serialize()
+	var b5, e2 = elm.BuyoutPricePerShare.Serialize()
+	if e2 != nil {
+		return nil, e2
+	}
+	var b6, _ = elm.HolderCount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	for _, v := range elm.Holders {
+		bt, e := v.Serialize()
+		if e != nil {
+			return nil, e
+		}
+		buffer.Write(bt)
+	}
+	var b7, _ = elm.CloseBlockHeight.Serialize()
+	buffer.Write(b7)
+	return buffer.Bytes(), nil
+}
+
+func (elm *DiamondVault) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.Status.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CreatorAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TotalShares.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyoutPricePerShare.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.HolderCount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	elm.Holders = make([]*DiamondVaultHolder, int(elm.HolderCount))
+	for i := 0; i < int(elm.HolderCount); i++ {
+		elm.Holders[i] = &DiamondVaultHolder{}
+		seek, e = elm.Holders[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	seek, e = elm.CloseBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// Find holder by address
+func (elm *DiamondVault) GetHolder(addr fields.Address) (int, *DiamondVaultHolder) {
+	for i, v := range elm.Holders {
+		if v.Address.Equal(addr) {
+			return i, v
+		}
+	}
+	return -1, nil
+}
+
+// Shares held by the address
+func (elm *DiamondVault) SharesOf(addr fields.Address) uint32 {
+	_, holder := elm.GetHolder(addr)
+	if holder == nil {
+		return 0
+	}
+	return uint32(holder.Shares)
+}
+
+func (elm *DiamondVault) AddShares(addr fields.Address, shares uint32) error {
+	_, holder := elm.GetHolder(addr)
+	if holder != nil {
+		holder.Shares += fields.VarUint4(shares)
+		return nil
+	}
+	if len(elm.Holders) >= DiamondVaultHolderMaxCount {
+		return fmt.Errorf("Diamond vault holders cannot over %d.", DiamondVaultHolderMaxCount)
+	}
+	elm.Holders = append(elm.Holders, &DiamondVaultHolder{
+		Address: addr,
+		Shares:  fields.VarUint4(shares),
+	})
+	elm.HolderCount = fields.VarUint1(len(elm.Holders))
+	return nil
+}
+
+// The holder is removed when no shares left
+func (elm *DiamondVault) SubShares(addr fields.Address, shares uint32) error {
+	i, holder := elm.GetHolder(addr)
+	if holder == nil || uint32(holder.Shares) < shares {
+		return fmt.Errorf("Address %s shares not enough.", addr.ToReadable())
+	}
+	holder.Shares -= fields.VarUint4(shares)
+	if holder.Shares == 0 {
+		elm.Holders = append(elm.Holders[:i], elm.Holders[i+1:]...)
+		elm.HolderCount = fields.VarUint1(len(elm.Holders))
+	}
+	return nil
+}
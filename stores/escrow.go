@@ -0,0 +1,118 @@
+package stores
+
+import (
+	"bytes"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	EscrowIdLength = 16
+)
+
+const (
+	EscrowStatusLocked   fields.VarUint1 = 0
+	EscrowStatusReleased fields.VarUint1 = 1 // Released to the seller
+	EscrowStatusRefunded fields.VarUint1 = 2 // Refunded to the buyer
+)
+
+// 2-of-3 escrow, the buyer locks assets for the seller and the arbiter decides when disputed
+type Escrow struct {
+	Status fields.VarUint1
+
+	BuyerAddress       fields.Address
+	SellerAddress      fields.Address
+	ArbiterAddress     fields.Address
+	TimeoutBlockHeight fields.BlockHeight // The buyer can be refunded without other signatures after it
+
+	LockAmount      fields.Amount
+	LockSatoshi     fields.SatoshiVariation
+	LockDiamondList fields.DiamondListMaxLen200
+
+	CloseBlockHeight fields.BlockHeight // Released or refunded at
+}
+
+func (elm *Escrow) IsClosed() bool {
+	return elm.Status != EscrowStatusLocked
+}
+
+func (elm *Escrow) Size() uint32 {
+	return elm.Status.Size() +
+		elm.BuyerAddress.Size() +
+		elm.SellerAddress.Size() +
+		elm.ArbiterAddress.Size() +
+		elm.TimeoutBlockHeight.Size() +
+		elm.LockAmount.Size() +
+		elm.LockSatoshi.Size() +
+		elm.LockDiamondList.Size() +
+		elm.CloseBlockHeight.Size()
+}
+
+func (elm *Escrow) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Status.Serialize()
+	var b2, _ = elm.BuyerAddress.Serialize()
+	var b3, _ = elm.SellerAddress.Serialize()
+	var b4, _ = elm.ArbiterAddress.Serialize()
+	var b5, _ = elm.TimeoutBlockHeight.Serialize()
+	var b6, e1 = elm.LockAmount.Serialize()
+	if e1 != nil {
+		return nil, e1
+	}
+	var b7, _ = elm.LockSatoshi.Serialize()
+	var b8, e2 = elm.LockDiamondList.Serialize()
+	if e2 != nil {
+		return nil, e2
+	}
+	var b9, _ = elm.CloseBlockHeight.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	buffer.Write(b9)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Escrow) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.Status.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ArbiterAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TimeoutBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CloseBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
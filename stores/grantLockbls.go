@@ -0,0 +1,232 @@
+package stores
+
+import (
+	"bytes"
+	"github.com/hacash/core/fields"
+	"math/big"
+)
+
+const (
+	GrantLockblsIdLength = 18
+
+	GrantLockblsProportionBase      = 10000 // Proportion unit: 1/10000
+	GrantLockblsBeneficiaryMaxCount = 20
+	grantLockblsReleaseAmountUnit   = 240 // Share is calculated in this unit, 1 = 0.00000001 HAC
+)
+
+// One beneficiary and the released amount
+type GrantBeneficiary struct {
+	Address        fields.Address
+	Proportion     fields.VarUint2 // Unit: 1/10000
+	ReleasedAmount fields.Amount
+}
+
+func (elm *GrantBeneficiary) Size() uint32 {
+	return elm.Address.Size() + elm.Proportion.Size() + elm.ReleasedAmount.Size()
+}
+
+func (elm *GrantBeneficiary) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Address.Serialize()
+	var b2, _ = elm.Proportion.Serialize()
+	var b3, e = elm.ReleasedAmount.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	return buffer.Bytes(), nil
+}
+
+func (elm *GrantBeneficiary) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.Address.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Proportion.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReleasedAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// Revocable and multi-beneficiary linear lock
+type GrantLockbls struct {
+	PaymentAddress      fields.Address         // The unvested remainder is returned to it when revoked
+	RevokerAddress      fields.OptionalAddress // Who can revoke, cannot be revoked if not exist
+	EffectBlockHeight   fields.BlockHeight     // Effective (start) block
+	LinearBlockNumber   fields.VarUint3        // Number of stepping blocks
+	TotalLockAmount     fields.Amount          // Total deposit limit
+	LinearReleaseAmount fields.Amount          // Limit vested each time
+
+	IsRevoked         fields.Bool
+	RevokeBlockHeight fields.BlockHeight // Vesting stops at this height
+
+	BeneficiaryCount fields.VarUint1
+	Beneficiaries    []*GrantBeneficiary
+}
+
+func (elm *GrantLockbls) Size() uint32 {
+	size := elm.PaymentAddress.Size() +
+		elm.RevokerAddress.Size() +
+		elm.EffectBlockHeight.Size() +
+		elm.LinearBlockNumber.Size() +
+		elm.TotalLockAmount.Size() +
+		elm.LinearReleaseAmount.Size() +
+		elm.IsRevoked.Size() +
+		elm.RevokeBlockHeight.Size() +
+		elm.BeneficiaryCount.Size()
+	for _, v := range elm.Beneficiaries {
+		size += v.Size()
+	}
+	return size
+}
+
+func (elm *GrantLockbls) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.PaymentAddress.Serialize()
+	var b2, _ = elm.RevokerAddress.Serialize()
+	var b3, _ = elm.EffectBlockHeight.Serialize()
+	var b4, _ = elm.LinearBlockNumber.Serialize()
+	var b5, _ = elm.TotalLockAmount.Serialize()
+	var b6, _ = elm.LinearReleaseAmount.Serialize()
+	var b7, _ = elm.IsRevoked.Serialize()
+	var b8, _ = elm.RevokeBlockHeight.Serialize()
+	var b9, _ = elm.BeneficiaryCount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	buffer.Write(b9)
+	for _, v := range elm.Beneficiaries {
+		bt, e := v.Serialize()
+		if e != nil {
+			return nil, e
+		}
+		buffer.Write(bt)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *GrantLockbls) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.PaymentAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RevokerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.EffectBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LinearBlockNumber.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TotalLockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LinearReleaseAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsRevoked.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RevokeBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BeneficiaryCount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	elm.Beneficiaries = make([]*GrantBeneficiary, int(elm.BeneficiaryCount))
+	for i := 0; i < int(elm.BeneficiaryCount); i++ {
+		elm.Beneficiaries[i] = &GrantBeneficiary{}
+		seek, e = elm.Beneficiaries[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+// Find beneficiary by address
+func (elm *GrantLockbls) GetBeneficiary(addr fields.Address) (int, *GrantBeneficiary) {
+	for i, v := range elm.Beneficiaries {
+		if v.Address.Equal(addr) {
+			return i, v
+		}
+	}
+	return -1, nil
+}
+
+// Total vested amount at the height, vesting stops when revoked
+func (elm *GrantLockbls) VestedAmount(height uint64) *big.Int {
+	if elm.IsRevoked.Check() && height > uint64(elm.RevokeBlockHeight) {
+		height = uint64(elm.RevokeBlockHeight)
+	}
+	if height < uint64(elm.EffectBlockHeight) || elm.LinearBlockNumber == 0 {
+		return big.NewInt(0)
+	}
+	rlsnum := (height - uint64(elm.EffectBlockHeight)) / uint64(elm.LinearBlockNumber)
+	vested := new(big.Int).Mul(elm.LinearReleaseAmount.GetValue(), new(big.Int).SetUint64(rlsnum))
+	if total := elm.TotalLockAmount.GetValue(); vested.Cmp(total) > 0 {
+		vested = total
+	}
+	return vested
+}
+
+// Vested share of the beneficiary at the height
+// Shares are rounded down, the last beneficiary takes the remainder when fully vested
+func (elm *GrantLockbls) VestedShare(index int, height uint64) *big.Int {
+	vested := elm.VestedAmount(height)
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(grantLockblsReleaseAmountUnit), nil)
+	share := func(i int) *big.Int {
+		num := new(big.Int).Mul(vested, big.NewInt(int64(elm.Beneficiaries[i].Proportion)))
+		num.Quo(num, big.NewInt(GrantLockblsProportionBase))
+		num.Quo(num, unit)
+		return num.Mul(num, unit)
+	}
+	isLast := index == len(elm.Beneficiaries)-1
+	if !isLast {
+		return share(index)
+	}
+	// Fully vested or revoked, all vested amount is distributed
+	isFinal := vested.Cmp(elm.TotalLockAmount.GetValue()) == 0 ||
+		(elm.IsRevoked.Check() && height >= uint64(elm.RevokeBlockHeight))
+	if !isFinal {
+		return share(index)
+	}
+	remain := new(big.Int).Set(vested)
+	for i := 0; i < index; i++ {
+		remain.Sub(remain, share(i))
+	}
+	return remain
+}
+
+// Claimable amount of the beneficiary at the height
+func (elm *GrantLockbls) ClaimableAmount(index int, height uint64) *big.Int {
+	claimable := elm.VestedShare(index, height)
+	claimable.Sub(claimable, elm.Beneficiaries[index].ReleasedAmount.GetValue())
+	if claimable.Sign() < 0 {
+		return big.NewInt(0)
+	}
+	return claimable
+}
@@ -0,0 +1,143 @@
+package stores
+
+import (
+	"bytes"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	RecurringPaymentIdLength = 16
+)
+
+// Standing order: the payee can pull up to a fixed amount from the payer every period
+type RecurringPayment struct {
+	PayerAddress      fields.Address     // Authorizer, pay from
+	PayeeAddress      fields.Address     // Pull to
+	StartBlockHeight  fields.BlockHeight // The first period starts at
+	EndBlockHeight    fields.BlockHeight // Cannot pull at and after it, 0 means no end
+	PeriodBlockNumber fields.VarUint3    // Number of blocks per period
+	AmountPerPeriod   fields.Amount      // Pull limit of each period
+
+	CurrentPeriodIndex  fields.VarUint4 // Period of the last pull
+	CurrentPulledAmount fields.Amount   // Pulled amount in the period of the last pull
+	TotalPulledAmount   fields.Amount
+
+	IsCancelled fields.Bool
+}
+
+// Period index at the height
+func (elm *RecurringPayment) PeriodIndex(height uint64) uint64 {
+	if height < uint64(elm.StartBlockHeight) || elm.PeriodBlockNumber == 0 {
+		return 0
+	}
+	return (height - uint64(elm.StartBlockHeight)) / uint64(elm.PeriodBlockNumber)
+}
+
+// Whether the payee can pull at the height
+func (elm *RecurringPayment) IsActive(height uint64) bool {
+	if elm.IsCancelled.Check() || height < uint64(elm.StartBlockHeight) {
+		return false
+	}
+	if elm.EndBlockHeight > 0 && height >= uint64(elm.EndBlockHeight) {
+		return false
+	}
+	return true
+}
+
+// Remaining pull limit of the period at the height
+func (elm *RecurringPayment) PullableAmount(height uint64) (*fields.Amount, error) {
+	if !elm.IsActive(height) {
+		return fields.NewEmptyAmount(), nil
+	}
+	if elm.PeriodIndex(height) != uint64(elm.CurrentPeriodIndex) {
+		// A new period, limit is reset
+		return elm.AmountPerPeriod.Copy(), nil
+	}
+	if !elm.AmountPerPeriod.MoreThan(&elm.CurrentPulledAmount) {
+		return fields.NewEmptyAmount(), nil
+	}
+	return elm.AmountPerPeriod.Sub(&elm.CurrentPulledAmount)
+}
+
+func (elm *RecurringPayment) Size() uint32 {
+	return elm.PayerAddress.Size() +
+		elm.PayeeAddress.Size() +
+		elm.StartBlockHeight.Size() +
+		elm.EndBlockHeight.Size() +
+		elm.PeriodBlockNumber.Size() +
+		elm.AmountPerPeriod.Size() +
+		elm.CurrentPeriodIndex.Size() +
+		elm.CurrentPulledAmount.Size() +
+		elm.TotalPulledAmount.Size() +
+		elm.IsCancelled.Size()
+}
+
+func (elm *RecurringPayment) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.PayerAddress.Serialize()
+	var b2, _ = elm.PayeeAddress.Serialize()
+	var b3, _ = elm.StartBlockHeight.Serialize()
+	var b4, _ = elm.EndBlockHeight.Serialize()
+	var b5, _ = elm.PeriodBlockNumber.Serialize()
+	var b6, _ = elm.AmountPerPeriod.Serialize()
+	var b7, _ = elm.CurrentPeriodIndex.Serialize()
+	var b8, _ = elm.CurrentPulledAmount.Serialize()
+	var b9, _ = elm.TotalPulledAmount.Serialize()
+	var b10, _ = elm.IsCancelled.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	buffer.Write(b9)
+	buffer.Write(b10)
+	return buffer.Bytes(), nil
+}
+
+func (elm *RecurringPayment) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.PayerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PayeeAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.StartBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.EndBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PeriodBlockNumber.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AmountPerPeriod.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CurrentPeriodIndex.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CurrentPulledAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TotalPulledAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsCancelled.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
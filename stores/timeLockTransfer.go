@@ -0,0 +1,106 @@
+package stores
+
+import (
+	"bytes"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	TimeLockTransferIdLength = 16
+)
+
+const (
+	TimeLockTransferStatusLocked  fields.VarUint1 = 0
+	TimeLockTransferStatusClaimed fields.VarUint1 = 1
+)
+
+// Transfer credited to the recipient but unspendable until the unlock height
+// The assets are not in the balance of recipient before claimed
+type TimeLockTransfer struct {
+	Status fields.VarUint1
+
+	FromAddress       fields.Address
+	ToAddress         fields.Address
+	UnlockBlockHeight fields.BlockHeight
+
+	Amount      fields.Amount
+	Satoshi     fields.SatoshiVariation
+	DiamondList fields.DiamondListMaxLen200
+
+	ClaimBlockHeight fields.BlockHeight
+}
+
+func (elm *TimeLockTransfer) Size() uint32 {
+	return elm.Status.Size() +
+		elm.FromAddress.Size() +
+		elm.ToAddress.Size() +
+		elm.UnlockBlockHeight.Size() +
+		elm.Amount.Size() +
+		elm.Satoshi.Size() +
+		elm.DiamondList.Size() +
+		elm.ClaimBlockHeight.Size()
+}
+
+func (elm *TimeLockTransfer) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Status.Serialize()
+	var b2, _ = elm.FromAddress.Serialize()
+	var b3, _ = elm.ToAddress.Serialize()
+	var b4, _ = elm.UnlockBlockHeight.Serialize()
+	var b5, e1 = elm.Amount.Serialize()
+	if e1 != nil {
+		return nil, e1
+	}
+	var b6, _ = elm.Satoshi.Serialize()
+	var b7, e2 = elm.DiamondList.Serialize()
+	if e2 != nil {
+		return nil, e2
+	}
+	var b8, _ = elm.ClaimBlockHeight.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	return buffer.Bytes(), nil
+}
+
+func (elm *TimeLockTransfer) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.Status.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.FromAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ToAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.UnlockBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Amount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Satoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ClaimBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
@@ -10,7 +10,7 @@ import (
 
 const (
 	typeSizeMax   int = 32
-	typeSizeValid int = 22 // Currently available
+	typeSizeValid int = 24 // Currently available
 	// Diamonds
 	TotalSupplyStoreTypeOfDiamond uint8 = 0 // Number of diamonds excavated
 	// BTC
@@ -42,6 +42,8 @@ const (
 	TotalSupplyStoreTypeOfDiamondBidBurningZhu                           uint8 = 20 // Diamond bidding fee burning part (unit:zhu)
 	TotalSupplyStoreTypeOfDiamondEngravedBurning                         uint8 = 21 // Diamond Engraved burning
 	TotalSupplyStoreTypeOfDiamondEngravedOperateCount                    uint8 = 22 // Diamond Engraved to do count
+	TotalSupplyStoreTypeOfUsersLendingCumulationRepayHacAmount           uint8 = 23 // Cumulative partial repayment HAC of inter user loan
+	TotalSupplyStoreTypeOfUsersLendingAdjustCount                        uint8 = 24 // Number of inter user loan partial repayment and adjustment
 
 	// TotalSupplyStoreTypeOfUsersLendingLendersInterestHacAmountCumulation uint8 = ... // 用户间借贷贷出方赚取的利息流水累计
 
@@ -32,6 +32,22 @@ type UserLending struct {
 	RansomBlockHeight fields.BlockHeight // Block height at redemption
 	RansomAmount      fields.Amount      // Redemption amount
 	RansomAddress     fields.Address     // Address of the Redeemer
+
+	// Extend data, appended at the end to keep the stored format of old records
+	// Written only if ExtendDataVersion is set by partial repayment or adjustment, the version byte is before it
+	// Old records end after the ransom data and are parsed with empty extend data
+	ExtendDataVersion     fields.VarUint1
+	RepaidAmount          fields.Amount      // Cumulative partial repayment amount
+	LastAdjustBlockHeight fields.BlockHeight // Block height of the last partial repayment or adjustment
+}
+
+const (
+	UserLendingExtendDataVersion1 = 1
+)
+
+// Whether the extend data need to be written
+func (elm *UserLending) hasExtendData() bool {
+	return elm.ExtendDataVersion > 0
 }
 
 func (elm *UserLending) Size() uint32 {
@@ -53,6 +69,12 @@ func (elm *UserLending) Size() uint32 {
 			elm.RansomAmount.Size() +
 			elm.RansomAddress.Size()
 	}
+	// Extend data
+	if elm.hasExtendData() {
+		sz += elm.ExtendDataVersion.Size() +
+			elm.RepaidAmount.Size() +
+			elm.LastAdjustBlockHeight.Size()
+	}
 	return sz
 }
 
@@ -91,6 +113,15 @@ func (elm *UserLending) Serialize() ([]byte, error) {
 		buffer.Write(b1)
 		buffer.Write(b2)
 	}
+	// Extend data
+	if elm.hasExtendData() {
+		var b0, _ = elm.ExtendDataVersion.Serialize()
+		var b1, _ = elm.RepaidAmount.Serialize()
+		var b2, _ = elm.LastAdjustBlockHeight.Serialize()
+		buffer.Write(b0)
+		buffer.Write(b1)
+		buffer.Write(b2)
+	}
 	return buffer.Bytes(), nil
 }
 
@@ -159,6 +190,26 @@ func (elm *UserLending) Parse(buf []byte, seek uint32) (uint32, error) {
 			return 0, e
 		}
 	}
+	// Extend data, old records have no more bytes
+	elm.ExtendDataVersion = 0
+	elm.RepaidAmount = fields.NewEmptyAmountValue()
+	elm.LastAdjustBlockHeight = 0
+	if int(seek) < len(buf) {
+		seek, e = elm.ExtendDataVersion.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+		if elm.ExtendDataVersion >= UserLendingExtendDataVersion1 {
+			seek, e = elm.RepaidAmount.Parse(buf, seek)
+			if e != nil {
+				return 0, e
+			}
+			seek, e = elm.LastAdjustBlockHeight.Parse(buf, seek)
+			if e != nil {
+				return 0, e
+			}
+		}
+	}
 	return seek, nil
 }
 
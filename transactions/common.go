@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/hacash/core/account"
 	"github.com/hacash/core/actions"
+	"github.com/hacash/core/diamondname"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/interfacev2"
@@ -141,9 +142,11 @@ func CreateOneActionOfHACDEngraved(diamonds *fields.DiamondListMaxLen200, conten
 			eng_type = 51 // MD5
 		} else if eng_len == 32 {
 			eng_type = 52 // SHA256
+		} else if eng_len == 64 {
+			eng_type = 53 // Signature
 		}
 	}
-	if eng_len == -1 {
+	if eng_type == -1 {
 		return nil, fmt.Errorf("Unsupported inscription content")
 	}
 	//
@@ -222,3 +225,101 @@ func CreateOneTxOfHACDEngravedRecovery(mainacc *account.Account, hacdlistsplitco
 	}
 	return newTrs, nil
 }
+
+// Create a recurring payment pull transaction, signed by the payee
+func CreateOneTxOfRecurringPaymentPull(payeeacc *account.Account, paymentid fields.RecurringPaymentId, amount *fields.Amount, fee *fields.Amount, timestamp int64) (*Transaction_2_Simple, error) {
+
+	// Create transaction
+	newTrs, _ := NewEmptyTransaction_2_Simple(payeeacc.Address)
+	newTrs.Timestamp = fields.BlockTxTimestamp(timestamp) // Use timestamp
+	newTrs.Fee = *fee                                     // set fee
+	tranact := &actions.Action_43_RecurringPaymentPull{
+		PaymentId:  paymentid,
+		PullAmount: *amount,
+	}
+	e9 := newTrs.AppendAction(tranact)
+	if e9 != nil {
+		return nil, e9
+	}
+	// Sign private key signature
+	allPrivateKeyBytes := make(map[string][]byte, 1)
+	allPrivateKeyBytes[string(payeeacc.Address)] = payeeacc.PrivateKey
+	e9 = newTrs.FillNeedSigns(allPrivateKeyBytes, nil)
+	if e9 != nil {
+		return nil, e9
+	}
+	return newTrs, nil
+}
+
+// Create a diamond swap transaction by the offer signed by seller, paid and signed by the buyer
+func CreateOneTxOfDiamondSwap(buyeracc *account.Account, offer *actions.DiamondSwapOffer, fee *fields.Amount, timestamp int64) (*Transaction_2_Simple, error) {
+
+	// Check offer
+	e0 := offer.CheckSellerSign()
+	if e0 != nil {
+		return nil, e0
+	}
+
+	// Create transaction
+	newTrs, _ := NewEmptyTransaction_2_Simple(buyeracc.Address)
+	newTrs.Timestamp = fields.BlockTxTimestamp(timestamp) // Use timestamp
+	newTrs.Fee = *fee                                     // set fee
+	tranact := &actions.Action_48_DiamondSwap{
+		Offer:        *offer,
+		BuyerAddress: buyeracc.Address,
+	}
+	e9 := newTrs.AppendAction(tranact)
+	if e9 != nil {
+		return nil, e9
+	}
+	// Sign private key signature
+	allPrivateKeyBytes := make(map[string][]byte, 1)
+	allPrivateKeyBytes[string(buyeracc.Address)] = buyeracc.PrivateKey
+	e9 = newTrs.FillNeedSigns(allPrivateKeyBytes, nil)
+	if e9 != nil {
+		return nil, e9
+	}
+	return newTrs, nil
+}
+
+// Create a diamond create transaction, the fee is the bid for the diamond
+func CreateOneTxOfDiamondCreate(feeacc *account.Account, act *actions.Action_4_DiamondCreate, fee *fields.Amount, timestamp int64) (*Transaction_2_Simple, error) {
+
+	// Create transaction
+	newTrs, _ := NewEmptyTransaction_2_Simple(feeacc.Address)
+	newTrs.Timestamp = fields.BlockTxTimestamp(timestamp) // Use timestamp
+	newTrs.Fee = *fee                                     // set fee
+	// Transaction can only contain one and only one action
+	e9 := newTrs.AppendAction(act)
+	if e9 != nil {
+		return nil, e9
+	}
+	// Sign private key signature
+	allPrivateKeyBytes := make(map[string][]byte, 1)
+	allPrivateKeyBytes[string(feeacc.Address)] = feeacc.PrivateKey
+	e9 = newTrs.FillNeedSigns(allPrivateKeyBytes, nil)
+	if e9 != nil {
+		return nil, e9
+	}
+	return newTrs, nil
+}
+
+// Create the diamond name record engraving transaction of the owner
+// Only one engraving of the diamond can be confirmed every 1000 blocks
+func CreateOneTxOfDiamondNameRecord(owneracc *account.Account, diamond string, key string, value string, insfee *fields.Amount, fee *fields.Amount, timestamp int64) (*Transaction_2_Simple, error) {
+
+	dianame, e := diamondname.ParseName(diamond)
+	if e != nil {
+		return nil, e
+	}
+	content, e := diamondname.CreateRecordContent(key, value)
+	if e != nil {
+		return nil, e
+	}
+	return CreateOneTxOfHACDEngraved(owneracc, string(dianame), content, insfee, fee, timestamp)
+}
+
+// Create the diamond name pay address record transaction
+func CreateOneTxOfDiamondNamePayAddress(owneracc *account.Account, diamond string, payaddr fields.Address, insfee *fields.Amount, fee *fields.Amount, timestamp int64) (*Transaction_2_Simple, error) {
+	return CreateOneTxOfDiamondNameRecord(owneracc, diamond, diamondname.RecordKeyPay, payaddr.ToReadable(), insfee, fee, timestamp)
+}
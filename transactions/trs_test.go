@@ -5,7 +5,12 @@ import (
 	"fmt"
 	"github.com/hacash/core/account"
 	"github.com/hacash/core/actions"
+	"github.com/hacash/core/diamondindexer"
 	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/memstate"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
 	"testing"
 	"time"
 )
@@ -143,3 +148,502 @@ func Test_coinbaseCopy(t *testing.T) {
 	fmt.Println(clonetrs.Serialize())
 
 }
+
+// Memory state in developer mode for action tests, the mark is restored after the test
+func newDevState(t *testing.T) *memstate.MemoryChainState {
+	oldmark := sys.TestDebugLocalDevelopmentMark
+	sys.TestDebugLocalDevelopmentMark = true
+	t.Cleanup(func() { sys.TestDebugLocalDevelopmentMark = oldmark })
+	return memstate.NewMemoryChainState(1)
+}
+
+// Execute the first action of a signed transaction
+func execTxAction(state interfaces.ChainStateOperation, tx interfaces.Transaction) error {
+	act := tx.GetActionList()[0]
+	act.SetBelongTrs(tx)
+	return act.WriteInChainState(state)
+}
+
+// Execute an action in a transaction signed by all signers, the first signer pays the fee
+func execAction(state interfaces.ChainStateOperation, act interfaces.Action, signers ...*account.Account) error {
+	tx, _ := NewEmptyTransaction_2_Simple(signers[0].Address)
+	tx.Fee = *fields.NewAmountSmall(1, 244)
+	tx.AddAction(act)
+	prikeys := map[string][]byte{}
+	addrs := []fields.Address{}
+	for _, acc := range signers {
+		prikeys[string(acc.Address)] = acc.PrivateKey
+		addrs = append(addrs, acc.Address)
+	}
+	tx.FillNeedSigns(prikeys, addrs)
+	return execTxAction(state, tx)
+}
+
+// Recurring payment create, pull and cancel
+func Test_recurring_payment(t *testing.T) {
+
+	payer := account.CreateAccountByPassword("123456")
+	payee := account.CreateAccountByPassword("654321")
+	fee := fields.NewAmountSmall(1, 244)
+
+	state := newDevState(t)
+	state.BalanceSet(payer.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(100)))
+	state.BalanceSet(payee.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(1)))
+
+	// 10 HAC per 288 blocks
+	paymentid := fields.RecurringPaymentId([]byte("recurringpay0001"))
+	e := execAction(state, &actions.Action_42_RecurringPaymentCreate{
+		PaymentId:         paymentid,
+		PayerAddress:      payer.Address,
+		PayeeAddress:      payee.Address,
+		StartBlockHeight:  10,
+		EndBlockHeight:    10 + 288*3,
+		PeriodBlockNumber: 288,
+		AmountPerPeriod:   *fields.NewAmountByUnitMei(10),
+	}, payer)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// First period
+	state.SetPendingBlockHeight(20)
+	tx, _ := CreateOneTxOfRecurringPaymentPull(payee, paymentid, fields.NewAmountByUnitMei(6), fee, 1)
+	if e := execTxAction(state, tx); e != nil {
+		t.Fatal(e)
+	}
+	tx, _ = CreateOneTxOfRecurringPaymentPull(payee, paymentid, fields.NewAmountByUnitMei(6), fee, 2)
+	if execTxAction(state, tx) == nil {
+		t.Fatal("period limit check error")
+	}
+	// The payer cannot pull
+	tx, _ = CreateOneTxOfRecurringPaymentPull(payer, paymentid, fields.NewAmountByUnitMei(1), fee, 3)
+	if execTxAction(state, tx) == nil {
+		t.Fatal("payee signature check error")
+	}
+
+	// Next period, limit is reset
+	state.SetPendingBlockHeight(10 + 288 + 1)
+	tx, _ = CreateOneTxOfRecurringPaymentPull(payee, paymentid, fields.NewAmountByUnitMei(10), fee, 4)
+	if e := execTxAction(state, tx); e != nil {
+		t.Fatal(e)
+	}
+
+	// Cancel by payer
+	if e := execAction(state, &actions.Action_44_RecurringPaymentCancel{PaymentId: paymentid}, payer); e != nil {
+		t.Fatal(e)
+	}
+	state.SetPendingBlockHeight(10 + 288*2 + 1)
+	tx, _ = CreateOneTxOfRecurringPaymentPull(payee, paymentid, fields.NewAmountByUnitMei(1), fee, 5)
+	if execTxAction(state, tx) == nil {
+		t.Fatal("cancelled payment pull check error")
+	}
+
+	bls1, _ := state.Balance(payer.Address)
+	bls2, _ := state.Balance(payee.Address)
+	payment, _ := state.RecurringPayment(paymentid)
+	fmt.Println(bls1.Hacash.ToFinString(), bls2.Hacash.ToFinString(), payment.TotalPulledAmount.ToFinString())
+	if !bls2.Hacash.Equal(fields.NewAmountByUnitMei(17)) {
+		t.Fatal("recurring payment pull amount error")
+	}
+
+}
+
+// Escrow 2-of-3 release and timeout refund
+func Test_escrow(t *testing.T) {
+
+	buyer := account.CreateAccountByPassword("123456")
+	seller := account.CreateAccountByPassword("654321")
+	arbiter := account.CreateAccountByPassword("abcdef")
+
+	state := newDevState(t)
+	bls := stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(100))
+	bls.Diamond = 1
+	state.BalanceSet(buyer.Address, bls)
+	diamond := fields.DiamondName("WTYUIA")
+	state.DiamondSet(diamond, stores.NewDiamond(buyer.Address))
+
+	var create = func(id fields.EscrowId, amt int64, dias []fields.DiamondName) error {
+		return execAction(state, &actions.Action_45_EscrowCreate{
+			EscrowId:           id,
+			BuyerAddress:       buyer.Address,
+			SellerAddress:      seller.Address,
+			ArbiterAddress:     arbiter.Address,
+			TimeoutBlockHeight: 100,
+			LockAmount:         *fields.NewAmountByUnitMei(amt),
+			LockSatoshi:        fields.NewEmptySatoshiVariation(),
+			LockDiamondList:    fields.DiamondListMaxLen200{Count: fields.VarUint1(len(dias)), Diamonds: dias},
+		}, buyer)
+	}
+
+	// Lock 10 HAC and one diamond
+	escrowid1 := fields.EscrowId([]byte("escrowid00000001"))
+	if e := create(escrowid1, 10, []fields.DiamondName{diamond}); e != nil {
+		t.Fatal(e)
+	}
+	diaitem, _ := state.Diamond(diamond)
+	if diaitem.Status != stores.DiamondStatusEscrow {
+		t.Fatal("diamond escrow status error")
+	}
+
+	// One signature is not enough
+	if execAction(state, &actions.Action_46_EscrowRelease{EscrowId: escrowid1}, seller) == nil {
+		t.Fatal("escrow signature check error")
+	}
+	if e := execAction(state, &actions.Action_46_EscrowRelease{EscrowId: escrowid1}, seller, arbiter); e != nil {
+		t.Fatal(e)
+	}
+	diaitem, _ = state.Diamond(diamond)
+	blsseller, _ := state.Balance(seller.Address)
+	if diaitem.Status != stores.DiamondStatusNormal || diaitem.Address.NotEqual(seller.Address) ||
+		blsseller.Diamond != 1 || !blsseller.Hacash.Equal(fields.NewAmountByUnitMei(10)) {
+		t.Fatal("escrow release error")
+	}
+	if execAction(state, &actions.Action_47_EscrowRefund{EscrowId: escrowid1}, buyer, arbiter) == nil {
+		t.Fatal("closed escrow check error")
+	}
+
+	// Refund by anyone after timeout
+	escrowid2 := fields.EscrowId([]byte("escrowid00000002"))
+	if e := create(escrowid2, 20, nil); e != nil {
+		t.Fatal(e)
+	}
+	if execAction(state, &actions.Action_47_EscrowRefund{EscrowId: escrowid2}, seller) == nil {
+		t.Fatal("escrow timeout check error")
+	}
+	state.SetPendingBlockHeight(100)
+	if e := execAction(state, &actions.Action_47_EscrowRefund{EscrowId: escrowid2}, seller); e != nil {
+		t.Fatal(e)
+	}
+	blsbuyer, _ := state.Balance(buyer.Address)
+	fmt.Println(blsbuyer.Hacash.ToFinString(), blsseller.Hacash.ToFinString())
+	if !blsbuyer.Hacash.Equal(fields.NewAmountByUnitMei(90)) {
+		t.Fatal("escrow refund error")
+	}
+
+}
+
+// Diamond swap by signed offer
+func Test_diamond_swap(t *testing.T) {
+
+	seller := account.CreateAccountByPassword("123456")
+	buyer := account.CreateAccountByPassword("654321")
+	other := account.CreateAccountByPassword("abcdef")
+	fee := fields.NewAmountSmall(1, 244)
+
+	state := newDevState(t)
+	blsseller := stores.NewEmptyBalance()
+	blsseller.Diamond = 2
+	state.BalanceSet(seller.Address, blsseller)
+	state.BalanceSet(buyer.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(100)))
+	state.BalanceSet(other.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(100)))
+	dias := []fields.DiamondName{fields.DiamondName("WTYUIA"), fields.DiamondName("HYXYHY")}
+	for _, dia := range dias {
+		state.DiamondSet(dia, stores.NewDiamond(seller.Address))
+	}
+
+	// Offer two diamonds for 30 HAC, only for buyer
+	offer := &actions.DiamondSwapOffer{
+		SellerAddress:     seller.Address,
+		DiamondList:       fields.DiamondListMaxLen200{Count: 2, Diamonds: dias},
+		Price:             *fields.NewAmountByUnitMei(30),
+		BuyerAddress:      fields.OptionalAddress{Exist: fields.CreateBool(true), Addr: buyer.Address},
+		ExpireBlockHeight: 100,
+		Nonce:             1,
+	}
+	if e := offer.FillSign(seller); e != nil {
+		t.Fatal(e)
+	}
+	// Serialize
+	bts, _ := offer.Serialize()
+	offer2 := &actions.DiamondSwapOffer{}
+	offer2.Parse(bts, 0)
+	if offer2.CheckSellerSign() != nil {
+		t.Fatal("offer parse error")
+	}
+
+	tx, _ := CreateOneTxOfDiamondSwap(other, offer2, fee, 1)
+	if execTxAction(state, tx) == nil {
+		t.Fatal("offer buyer check error")
+	}
+	tx, _ = CreateOneTxOfDiamondSwap(buyer, offer2, fee, 2)
+	if e := execTxAction(state, tx); e != nil {
+		t.Fatal(e)
+	}
+	blsbuyer, _ := state.Balance(buyer.Address)
+	blsseller, _ = state.Balance(seller.Address)
+	fmt.Println(blsbuyer.Hacash.ToFinString(), blsbuyer.Diamond, blsseller.Hacash.ToFinString(), blsseller.Diamond)
+	if blsbuyer.Diamond != 2 || !blsseller.Hacash.Equal(fields.NewAmountByUnitMei(30)) {
+		t.Fatal("diamond swap error")
+	}
+	// Replay
+	tx, _ = CreateOneTxOfDiamondSwap(buyer, offer2, fee, 3)
+	if execTxAction(state, tx) == nil {
+		t.Fatal("offer replay check error")
+	}
+
+	// Cancel an offer by the buyer (now the owner) and it cannot be filled
+	offer3 := &actions.DiamondSwapOffer{
+		SellerAddress:     buyer.Address,
+		DiamondList:       fields.DiamondListMaxLen200{Count: 1, Diamonds: dias[0:1]},
+		Price:             *fields.NewAmountByUnitMei(20),
+		BuyerAddress:      fields.NewEmptyOptionalAddress(),
+		ExpireBlockHeight: 100,
+	}
+	offer3.FillSign(buyer)
+	if e := execAction(state, &actions.Action_49_DiamondSwapCancel{Offer: *offer3}, buyer); e != nil {
+		t.Fatal(e)
+	}
+	tx, _ = CreateOneTxOfDiamondSwap(other, offer3, fee, 4)
+	if execTxAction(state, tx) == nil {
+		t.Fatal("cancelled offer check error")
+	}
+
+}
+
+// Time lock transfer create and claim
+func Test_time_lock_transfer(t *testing.T) {
+
+	payer := account.CreateAccountByPassword("123456")
+	payee := account.CreateAccountByPassword("654321")
+
+	state := newDevState(t)
+	bls := stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(100))
+	bls.Diamond = 1
+	bls.Satoshi = 5000
+	state.BalanceSet(payer.Address, bls)
+	diamond := fields.DiamondName("WTYUIA")
+	state.DiamondSet(diamond, stores.NewDiamond(payer.Address))
+
+	// Post-dated salary
+	trsid := fields.TimeLockTransferId([]byte("timelocktrs00001"))
+	e := execAction(state, &actions.Action_50_TimeLockTransferCreate{
+		TransferId:        trsid,
+		FromAddress:       payer.Address,
+		ToAddress:         payee.Address,
+		UnlockBlockHeight: 50,
+		Amount:            *fields.NewAmountByUnitMei(10),
+		Satoshi:           fields.NewSatoshiVariation(3000),
+		DiamondList:       fields.DiamondListMaxLen200{Count: 1, Diamonds: []fields.DiamondName{diamond}},
+	}, payer)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// Not spendable before unlock
+	diaitem, _ := state.Diamond(diamond)
+	blspayee, _ := state.Balance(payee.Address)
+	if diaitem.Status != stores.DiamondStatusTimeLocked || diaitem.Address.NotEqual(payee.Address) || blspayee != nil {
+		t.Fatal("time lock transfer create error")
+	}
+	if execAction(state, &actions.Action_51_TimeLockTransferClaim{TransferId: trsid}, payer) == nil {
+		t.Fatal("unlock height check error")
+	}
+
+	// Claim by anyone after unlock
+	state.SetPendingBlockHeight(50)
+	if e := execAction(state, &actions.Action_51_TimeLockTransferClaim{TransferId: trsid}, payer); e != nil {
+		t.Fatal(e)
+	}
+	blspayee, _ = state.Balance(payee.Address)
+	fmt.Println(blspayee.Hacash.ToFinString(), blspayee.Satoshi, blspayee.Diamond)
+	if !blspayee.Hacash.Equal(fields.NewAmountByUnitMei(10)) || blspayee.Satoshi != 3000 || blspayee.Diamond != 1 {
+		t.Fatal("time lock transfer claim error")
+	}
+	if execAction(state, &actions.Action_51_TimeLockTransferClaim{TransferId: trsid}, payer) == nil {
+		t.Fatal("claimed check error")
+	}
+
+}
+
+// Block store reading diamond smelts only
+type testDiamondStore struct {
+	interfaces.BlockStore
+	smelts map[string]*stores.DiamondSmelt
+}
+
+func (s *testDiamondStore) ReadDiamond(diamond fields.DiamondName) (*stores.DiamondSmelt, error) {
+	return s.smelts[string(diamond)], nil
+}
+
+// Block head for the indexer, the blocks package imports transactions
+type testBlockHead struct {
+	interfaces.BlockHeadMetaRead
+	height uint64
+}
+
+func (b *testBlockHead) GetHeight() uint64 {
+	return b.height
+}
+
+func (b *testBlockHead) Hash() fields.Hash {
+	return make([]byte, 32)
+}
+
+// Diamond name record
+func Test_diamond_name_record(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	acc3 := account.CreateAccountByPassword("abcdef")
+	insfee := fields.NewEmptyAmount()
+	fee := fields.NewAmountSmall(1, 244)
+	diamond := fields.DiamondName("WTYUIA")
+
+	state := newDevState(t)
+	state.SetBlockStore(&testDiamondStore{smelts: map[string]*stores.DiamondSmelt{
+		"WTYUIA": {Diamond: diamond, Number: 2, AverageBidBurnPrice: 10},
+	}})
+	bls := stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(1))
+	bls.Diamond = 1
+	state.BalanceSet(acc1.Address, bls)
+	state.DiamondSet(diamond, stores.NewDiamond(acc1.Address))
+
+	// Confirm the tx in the chain state and the indexer
+	idx := diamondindexer.NewDiamondIndexer()
+	var confirm = func(height uint64, tx interfaces.Transaction) error {
+		state.SetPendingBlockHeight(height)
+		if e := execTxAction(state, tx); e != nil {
+			return e
+		}
+		idx.ScanTx(&testBlockHead{height: height}, tx)
+		return nil
+	}
+	minttx, _ := NewEmptyTransaction_2_Simple(acc1.Address)
+	minttx.AddAction(&actions.Action_4_DiamondCreate{Diamond: diamond, Number: 2, Address: acc1.Address})
+	idx.ScanTx(&testBlockHead{}, minttx)
+
+	// One engraving is one record
+	rcdtx, e := CreateOneTxOfDiamondNamePayAddress(acc1, "@WTYUIA", acc2.Address, insfee, fee, 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	act := rcdtx.GetActionList()[0].(*actions.Action_32_DiamondsEngraved)
+	fmt.Println(act.EngravedType, act.EngravedContent.Str)
+	if act.EngravedType != 1 || string(act.DiamondList.Diamonds[0]) != "WTYUIA" {
+		t.Fatal("diamond name record action error")
+	}
+	if e := confirm(20, rcdtx); e != nil {
+		t.Fatal(e)
+	}
+	res, e := idx.ResolveName("@WTYUIA")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !res.PayAddress.Equal(acc2.Address) || res.OwnerSince != 0 {
+		t.Fatal("diamond name resolve error")
+	}
+	// One inscription every 10 blocks in development
+	urltx, _ := CreateOneTxOfDiamondNameRecord(acc1, "WTYUIA", "url", "https://hacash.org", insfee, fee, 2)
+	if confirm(25, urltx) == nil {
+		t.Fatal("engrave interval check error")
+	}
+	if e := confirm(30, urltx); e != nil {
+		t.Fatal(e)
+	}
+	res, _ = idx.ResolveName("WTYUIA")
+	if !res.PayAddress.Equal(acc2.Address) || res.Records["url"] != "https://hacash.org" {
+		t.Fatal("diamond name resolve error")
+	}
+
+	// Transferred, the records of acc1 are stale even if not cleared
+	trstx, _ := NewEmptyTransaction_2_Simple(acc1.Address)
+	trstx.AddAction(&actions.Action_5_DiamondTransfer{Diamond: diamond, ToAddress: acc3.Address})
+	if e := confirm(35, trstx); e != nil {
+		t.Fatal(e)
+	}
+	if dia, _ := state.Diamond(diamond); dia.EngravedContents.Count != 2 {
+		t.Fatal("engravings should be kept after transfer")
+	}
+	res, _ = idx.ResolveName("WTYUIA")
+	if !res.PayAddress.Equal(acc3.Address) || len(res.Records) != 0 || res.OwnerSince != 35 {
+		t.Fatal("stale records not ignored")
+	}
+	// The previous owner cannot engrave any more
+	faketx, _ := CreateOneTxOfDiamondNamePayAddress(acc1, "WTYUIA", acc1.Address, insfee, fee, 3)
+	if confirm(40, faketx) == nil {
+		t.Fatal("diamond belong check error")
+	}
+	paytx, _ := CreateOneTxOfDiamondNamePayAddress(acc3, "WTYUIA", acc2.Address, insfee, fee, 4)
+	if e := confirm(40, paytx); e != nil {
+		t.Fatal(e)
+	}
+	res, _ = idx.ResolveName("WTYUIA")
+	if !res.PayAddress.Equal(acc2.Address) || len(res.Records) != 1 {
+		t.Fatal("diamond name resolve error")
+	}
+
+	if _, e := CreateOneTxOfDiamondNameRecord(acc1, "WTYUI0", "url", "https://hacash.org", insfee, fee, 5); e == nil {
+		t.Fatal("diamond name check error")
+	}
+
+}
+
+// Fractional diamond vault
+func Test_diamond_vault(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	acc3 := account.CreateAccountByPassword("abcdef")
+
+	state := newDevState(t)
+	bls := stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(10))
+	bls.Diamond = 2
+	state.BalanceSet(acc1.Address, bls)
+	state.BalanceSet(acc3.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(100)))
+	dias := []fields.DiamondName{fields.DiamondName("WTYUIA"), fields.DiamondName("NHMYYM")}
+	for _, d := range dias {
+		state.DiamondSet(d, stores.NewDiamond(acc1.Address))
+	}
+
+	// 100 shares, buyout price 0.5 HAC each
+	vaultid := fields.DiamondVaultId([]byte("diamondvault0001"))
+	e := execAction(state, &actions.Action_52_DiamondVaultCreate{
+		VaultId:             vaultid,
+		CreatorAddress:      acc1.Address,
+		DiamondList:         fields.DiamondListMaxLen200{Count: 2, Diamonds: dias},
+		TotalShares:         100,
+		BuyoutPricePerShare: *fields.NewAmountByUnit(5, 247),
+	}, acc1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	diaitem, _ := state.Diamond(dias[0])
+	bls1, _ := state.Balance(acc1.Address)
+	if diaitem.Status != stores.DiamondStatusVault || bls1.Diamond != 0 {
+		t.Fatal("diamond vault lock error")
+	}
+
+	// Transfer 30 shares and redeem need all shares
+	if e := execAction(state, &actions.Action_53_DiamondVaultShareTransfer{VaultId: vaultid, FromAddress: acc1.Address, ToAddress: acc2.Address, Shares: 30}, acc1); e != nil {
+		t.Fatal(e)
+	}
+	if execAction(state, &actions.Action_53_DiamondVaultShareTransfer{VaultId: vaultid, FromAddress: acc2.Address, ToAddress: acc3.Address, Shares: 31}, acc2) == nil {
+		t.Fatal("shares check error")
+	}
+	if execAction(state, &actions.Action_54_DiamondVaultRedeem{VaultId: vaultid, RedeemAddress: acc1.Address}, acc1) == nil {
+		t.Fatal("redeem all shares check error")
+	}
+	if e := execAction(state, &actions.Action_53_DiamondVaultShareTransfer{VaultId: vaultid, FromAddress: acc2.Address, ToAddress: acc3.Address, Shares: 10}, acc2); e != nil {
+		t.Fatal(e)
+	}
+
+	// acc3 holds 10 shares and buys out the other 90
+	if e := execAction(state, &actions.Action_55_DiamondVaultBuyout{VaultId: vaultid, BuyerAddress: acc3.Address}, acc3); e != nil {
+		t.Fatal(e)
+	}
+	bls1, _ = state.Balance(acc1.Address)
+	bls2, _ := state.Balance(acc2.Address)
+	bls3, _ := state.Balance(acc3.Address)
+	fmt.Println(bls1.Hacash.ToFinString(), bls2.Hacash.ToFinString(), bls3.Hacash.ToFinString(), bls3.Diamond)
+	if !bls1.Hacash.Equal(fields.NewAmountByUnitMei(45)) || !bls2.Hacash.Equal(fields.NewAmountByUnitMei(10)) ||
+		!bls3.Hacash.Equal(fields.NewAmountByUnitMei(55)) || bls3.Diamond != 2 {
+		t.Fatal("diamond vault buyout error")
+	}
+	diaitem, _ = state.Diamond(dias[1])
+	if diaitem.Status != stores.DiamondStatusNormal || diaitem.Address.NotEqual(acc3.Address) {
+		t.Fatal("diamond vault unlock error")
+	}
+	if execAction(state, &actions.Action_54_DiamondVaultRedeem{VaultId: vaultid, RedeemAddress: acc3.Address}, acc3) == nil {
+		t.Fatal("closed vault check error")
+	}
+
+}
@@ -0,0 +1,208 @@
+package vesting
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/memstate"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+	"github.com/hacash/core/transactions"
+	"math/big"
+	"testing"
+)
+
+func Test1(t *testing.T) {
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+
+	// 1 year cliff, 48 monthly tranches
+	schedule := &Schedule{
+		StartHeight:        1000,
+		CliffBlockNumber:   8640 * 12,
+		TrancheBlockNumber: 8640,
+		TrancheCount:       48,
+		TotalAmount:        fields.NewAmountByUnitMei(10000),
+	}
+	plans, e := schedule.Compile()
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, p := range plans {
+		fmt.Println(p.EffectBlockHeight, p.LinearBlockNumber, p.TotalStockAmount.ToFinString(), p.LinearReleaseAmount.ToFinString())
+	}
+
+	state := memstate.NewMemoryChainState(1)
+	state.BalanceSet(acc1.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(20000)))
+	trs, e := CreateOneTxOfVestingLockbls(acc1, acc2.Address, schedule, fields.NewAmountSmall(1, 244), 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, act := range trs.GetActionList() {
+		act.SetBelongTrs(trs)
+		e = act.WriteInChainState(state)
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	// Claim all released at every height, the total must be equal to the vested
+	claimed := big.NewInt(0)
+	for _, height := range []uint64{1000, 1000 + 8640*12 - 1, 1000 + 8640*12, 1000 + 8640*13 + 5, 1000 + 8640*30, 1000 + 8640*60} {
+		state.SetPendingBlockHeight(height)
+		for i := range plans {
+			id := CreateLockblsId(acc1.Address, acc2.Address, schedule, i)
+			lockbls, _ := state.Lockbls(id)
+			act, e := CreateMaxReleaseAction(id, lockbls, height)
+			if e != nil {
+				continue
+			}
+			act.SetBelongTrs(trs)
+			e = act.WriteInChainState(state)
+			if e != nil {
+				t.Fatal(e)
+			}
+			claimed.Add(claimed, act.ReleaseAmount.GetValue())
+		}
+		vested, _ := schedule.VestedAmount(height)
+		fmt.Println(height, vested.ToFinString())
+		if vested.GetValue().Cmp(claimed) != 0 {
+			t.Fatal("claimed amount not equal to vested", height)
+		}
+	}
+
+}
+
+func Test2(t *testing.T) {
+
+	// No cliff with remainder
+	schedule := &Schedule{
+		StartHeight:        0,
+		TrancheBlockNumber: 288,
+		TrancheCount:       3,
+		TotalAmount:        fields.NewAmountByUnitMei(10),
+	}
+	plans, e := schedule.Compile()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(plans) != 2 {
+		t.Fatal("compile error")
+	}
+	for _, p := range plans {
+		fmt.Println(p.EffectBlockHeight, p.LinearBlockNumber, p.TotalStockAmount.ToFinString(), p.LinearReleaseAmount.ToFinString())
+	}
+
+	// Divisible
+	schedule.TotalAmount = fields.NewAmountByUnitMei(9)
+	plans, _ = schedule.Compile()
+	if len(plans) != 1 {
+		t.Fatal("compile error")
+	}
+
+}
+
+func Test3(t *testing.T) {
+
+	oldmark := sys.TestDebugLocalDevelopmentMark
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = oldmark }()
+
+	acc1 := account.CreateAccountByPassword("123456")
+	acc2 := account.CreateAccountByPassword("654321")
+	acc3 := account.CreateAccountByPassword("abcdef")
+	acc4 := account.CreateAccountByPassword("fedcba")
+
+	state := memstate.NewMemoryChainState(1)
+	state.BalanceSet(acc1.Address, stores.NewBalanceWithAmount(fields.NewAmountByUnitMei(100)))
+
+	var execute = func(act interfaces.Action, signer *account.Account) error {
+		trs, _ := transactions.NewEmptyTransaction_2_Simple(signer.Address)
+		trs.AddAction(act)
+		trs.FillNeedSigns(map[string][]byte{string(signer.Address): signer.PrivateKey}, nil)
+		act.SetBelongTrs(trs)
+		return act.WriteInChainState(state)
+	}
+
+	// 30 HAC each 288 blocks, split 1/3 and 2/3, acc4 can revoke
+	lockid := fields.LockblsId([]byte("grantlockbls000001"))
+	e := execute(&actions.Action_39_GrantLockblsCreate{
+		LockblsId:              lockid,
+		PaymentAddress:         acc1.Address,
+		RevokerAddress:         fields.OptionalAddress{Exist: fields.CreateBool(true), Addr: acc4.Address},
+		EffectBlockHeight:      10,
+		LinearBlockNumber:      288,
+		TotalStockAmount:       *fields.NewAmountByUnitMei(90),
+		LinearReleaseAmount:    *fields.NewAmountByUnitMei(30),
+		BeneficiaryCount:       2,
+		BeneficiaryAddresses:   []fields.Address{acc2.Address, acc3.Address},
+		BeneficiaryProportions: []fields.VarUint2{3333, 6667},
+	}, acc1)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Release the first step
+	state.SetPendingBlockHeight(10 + 288)
+	grantlock, _ := state.GrantLockbls(lockid)
+	for i, b := range grantlock.Beneficiaries {
+		amt, _ := fields.NewAmountByBigInt(grantlock.ClaimableAmount(i, 10+288))
+		fmt.Println(b.Address.ToReadable(), amt.ToFinString())
+	}
+	if execute(&actions.Action_40_GrantLockblsRelease{
+		LockblsId:          lockid,
+		BeneficiaryAddress: acc2.Address,
+		ReleaseAmount:      *fields.NewAmountByUnitMei(11),
+	}, acc1) == nil {
+		t.Fatal("release amount check error")
+	}
+	e = execute(&actions.Action_40_GrantLockblsRelease{
+		LockblsId:          lockid,
+		BeneficiaryAddress: acc2.Address,
+		ReleaseAmount:      *fields.NewAmountByUnitMei(9),
+	}, acc1)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Revoke need the signature of revoker
+	state.SetPendingBlockHeight(10 + 288*2 + 5)
+	if execute(&actions.Action_41_GrantLockblsRevoke{LockblsId: lockid}, acc1) == nil {
+		t.Fatal("revoke signature check error")
+	}
+	e = execute(&actions.Action_41_GrantLockblsRevoke{LockblsId: lockid}, acc4)
+	if e != nil {
+		t.Fatal(e)
+	}
+	bls1, _ := state.Balance(acc1.Address)
+	fmt.Println(bls1.Hacash.ToFinString())
+	if bls1.Hacash.ToMei() != 40 {
+		t.Fatal("unvested remainder return error")
+	}
+
+	// Vesting stopped, the last beneficiary takes the remainder
+	state.SetPendingBlockHeight(10 + 288*3)
+	grantlock, _ = state.GrantLockbls(lockid)
+	for i, b := range grantlock.Beneficiaries {
+		claimable := grantlock.ClaimableAmount(i, 10+288*3)
+		amt, _ := fields.NewAmountByBigInt(claimable)
+		e = execute(&actions.Action_40_GrantLockblsRelease{
+			LockblsId:          lockid,
+			BeneficiaryAddress: b.Address,
+			ReleaseAmount:      *amt,
+		}, acc1)
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	bls2, _ := state.Balance(acc2.Address)
+	bls3, _ := state.Balance(acc3.Address)
+	fmt.Println(bls2.Hacash.ToFinString(), bls3.Hacash.ToFinString())
+	if sum, _ := bls2.Hacash.Add(&bls3.Hacash); !sum.Equal(fields.NewAmountByUnitMei(60)) {
+		t.Fatal("grant release total error")
+	}
+
+}
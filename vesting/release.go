@@ -0,0 +1,77 @@
+package vesting
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+	"math/big"
+)
+
+// Claimable amount of the lockbls at the pending block height, same as Action_10_LockblsRelease
+func ClaimableAmount(lockbls *stores.Lockbls, height uint64) (*fields.Amount, error) {
+	if height < uint64(lockbls.EffectBlockHeight) || lockbls.LinearBlockNumber == 0 {
+		return fields.NewEmptyAmount(), nil
+	}
+	rlsnum := (height - uint64(lockbls.EffectBlockHeight)) / uint64(lockbls.LinearBlockNumber)
+	if rlsnum == 0 {
+		return fields.NewEmptyAmount(), nil
+	}
+	maxrls := new(big.Int).Mul(lockbls.LinearReleaseAmount.GetValue(), new(big.Int).SetUint64(rlsnum))
+	// The withdrawable balance shall be deducted from the withdrawn balance
+	extracted := new(big.Int).Sub(lockbls.TotalLockAmount.GetValue(), lockbls.BalanceAmount.GetValue())
+	maxrls.Sub(maxrls, extracted)
+	if balance := lockbls.BalanceAmount.GetValue(); maxrls.Cmp(balance) > 0 {
+		maxrls = balance
+	}
+	if maxrls.Sign() <= 0 {
+		return fields.NewEmptyAmount(), nil
+	}
+	return fields.NewAmountByBigInt(maxrls)
+}
+
+// Create the release action for the maximum claimable amount
+func CreateMaxReleaseAction(id fields.LockblsId, lockbls *stores.Lockbls, height uint64) (*actions.Action_10_LockblsRelease, error) {
+	amt, e := ClaimableAmount(lockbls, height)
+	if e != nil {
+		return nil, e
+	}
+	if amt.IsEmpty() {
+		return nil, fmt.Errorf("Lockbls <%s> nothing to release at height %d.", id.ToHex(), height)
+	}
+	return &actions.Action_10_LockblsRelease{
+		LockblsId:     id,
+		ReleaseAmount: *amt,
+	}, nil
+}
+
+// Create a transaction with all lockbls creations of the schedule, signed by the payment account
+func CreateOneTxOfVestingLockbls(payacc *account.Account, master fields.Address, s *Schedule,
+	fee *fields.Amount, timestamp int64) (*transactions.Transaction_2_Simple, error) {
+	acts, e := CreateLockblsActions(payacc.Address, master, s)
+	if e != nil {
+		return nil, e
+	}
+	newTrs, e := transactions.NewEmptyTransaction_2_Simple(payacc.Address)
+	if e != nil {
+		return nil, e
+	}
+	newTrs.Timestamp = fields.BlockTxTimestamp(timestamp) // Use timestamp
+	newTrs.Fee = *fee                                     // set fee
+	for _, act := range acts {
+		e = newTrs.AddAction(act)
+		if e != nil {
+			return nil, e
+		}
+	}
+	// Sign private key signature
+	allPrivateKeyBytes := make(map[string][]byte, 1)
+	allPrivateKeyBytes[string(payacc.Address)] = payacc.PrivateKey
+	e = newTrs.FillNeedSigns(allPrivateKeyBytes, nil)
+	if e != nil {
+		return nil, e
+	}
+	return newTrs, nil
+}
@@ -0,0 +1,221 @@
+package vesting
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"math/big"
+)
+
+/**
+
+归属计划：将悬崖期 + 按期发放的计划编译为线性锁仓（lockbls）
+
+*/
+
+const (
+	// Same as Action_9_LockblsCreate
+	MinLinearBlockNumber uint64 = 288
+	MaxLinearBlockNumber uint64 = 1600 * 10000
+
+	// Tranche amount is calculated in this unit, 1 = 0.00000001 HAC
+	trancheAmountUnit = 240
+)
+
+// A human vesting schedule
+// The total is split into equal tranches, one tranche vests every TrancheBlockNumber from StartHeight
+// Tranches before the cliff vest together at the cliff, the indivisible remainder vests with the first release
+type Schedule struct {
+	StartHeight        uint64
+	CliffBlockNumber   uint64 // 0 means no cliff
+	TrancheBlockNumber uint64 // such as 8640 blocks about one month
+	TrancheCount       uint64
+	TotalAmount        *fields.Amount
+}
+
+// One linear lock compiled from the schedule
+type LockPlan struct {
+	EffectBlockHeight   uint64
+	LinearBlockNumber   uint64
+	TotalStockAmount    *fields.Amount
+	LinearReleaseAmount *fields.Amount
+}
+
+func (s *Schedule) Check() error {
+	if s.TotalAmount == nil || !s.TotalAmount.IsPositive() {
+		return fmt.Errorf("Vesting total amount must be positive.")
+	}
+	if s.TrancheCount == 0 {
+		return fmt.Errorf("Vesting tranche count cannot be zero.")
+	}
+	if s.TrancheBlockNumber < MinLinearBlockNumber || s.TrancheBlockNumber > MaxLinearBlockNumber {
+		return fmt.Errorf("Vesting tranche block number must between %d and %d.", MinLinearBlockNumber, MaxLinearBlockNumber)
+	}
+	if s.CliffBlockNumber > MaxLinearBlockNumber {
+		return fmt.Errorf("Vesting cliff block number cannot over %d.", MaxLinearBlockNumber)
+	}
+	return nil
+}
+
+// Split total amount, return each tranche and the remainder
+func (s *Schedule) splitTranche() (*big.Int, *big.Int, error) {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(trancheAmountUnit), nil)
+	total, rem := new(big.Int).QuoRem(s.TotalAmount.GetValue(), unit, new(big.Int))
+	if rem.Sign() != 0 {
+		return nil, nil, fmt.Errorf("Vesting total amount %s is too precise.", s.TotalAmount.ToFinString())
+	}
+	per, remainder := new(big.Int).QuoRem(total, new(big.Int).SetUint64(s.TrancheCount), new(big.Int))
+	if per.Sign() == 0 {
+		return nil, nil, fmt.Errorf("Vesting tranche amount is too small.")
+	}
+	return per, remainder, nil
+}
+
+func amountInUnit(num *big.Int) (*fields.Amount, error) {
+	return fields.NewAmountByBigIntWithUnit(new(big.Int).Set(num), trancheAmountUnit)
+}
+
+// Number of tranches vest at the first release, and the height of first release
+func (s *Schedule) firstRelease(remainder *big.Int) (uint64, uint64) {
+	lumpnum := s.CliffBlockNumber / s.TrancheBlockNumber
+	if lumpnum > s.TrancheCount {
+		lumpnum = s.TrancheCount
+	}
+	if lumpnum == 0 {
+		if remainder.Sign() == 0 {
+			return 0, 0
+		}
+		// The remainder vests with the first tranche
+		return 1, s.StartHeight + s.TrancheBlockNumber
+	}
+	return lumpnum, s.StartHeight + s.CliffBlockNumber
+}
+
+// Compile the schedule into the minimal set of linear locks
+func (s *Schedule) Compile() ([]*LockPlan, error) {
+	e := s.Check()
+	if e != nil {
+		return nil, e
+	}
+	per, remainder, e := s.splitTranche()
+	if e != nil {
+		return nil, e
+	}
+	plans := make([]*LockPlan, 0, 2)
+	lumpnum, lumpheight := s.firstRelease(remainder)
+	// Release all at once at the first release
+	if lumpnum > 0 {
+		lump := new(big.Int).Mul(per, new(big.Int).SetUint64(lumpnum))
+		lump.Add(lump, remainder)
+		amt, e := amountInUnit(lump)
+		if e != nil {
+			return nil, e
+		}
+		plans = append(plans, &LockPlan{
+			EffectBlockHeight:   s.StartHeight,
+			LinearBlockNumber:   lumpheight - s.StartHeight,
+			TotalStockAmount:    amt,
+			LinearReleaseAmount: amt,
+		})
+	}
+	// Release by tranche
+	if lumpnum < s.TrancheCount {
+		stock := new(big.Int).Mul(per, new(big.Int).SetUint64(s.TrancheCount-lumpnum))
+		stockamt, e := amountInUnit(stock)
+		if e != nil {
+			return nil, e
+		}
+		stepamt, e := amountInUnit(per)
+		if e != nil {
+			return nil, e
+		}
+		plans = append(plans, &LockPlan{
+			EffectBlockHeight:   s.StartHeight + lumpnum*s.TrancheBlockNumber,
+			LinearBlockNumber:   s.TrancheBlockNumber,
+			TotalStockAmount:    stockamt,
+			LinearReleaseAmount: stepamt,
+		})
+	}
+	return plans, nil
+}
+
+// Vested amount of the schedule at the height
+func (s *Schedule) VestedAmount(height uint64) (*fields.Amount, error) {
+	e := s.Check()
+	if e != nil {
+		return nil, e
+	}
+	per, remainder, e := s.splitTranche()
+	if e != nil {
+		return nil, e
+	}
+	if height <= s.StartHeight {
+		return fields.NewEmptyAmount(), nil
+	}
+	lumpnum, lumpheight := s.firstRelease(remainder)
+	if lumpnum > 0 && height < lumpheight {
+		return fields.NewEmptyAmount(), nil
+	}
+	num := (height - s.StartHeight) / s.TrancheBlockNumber
+	if num > s.TrancheCount {
+		num = s.TrancheCount
+	}
+	if num < lumpnum {
+		num = lumpnum
+	}
+	vested := new(big.Int).Mul(per, new(big.Int).SetUint64(num))
+	if num > 0 {
+		vested.Add(vested, remainder)
+	}
+	return amountInUnit(vested)
+}
+
+// Create lockbls id by the payment, master address, schedule and index
+func CreateLockblsId(payment, master fields.Address, s *Schedule, index int) fields.LockblsId {
+	stuff := bytes.NewBuffer(nil)
+	stuff.Write(payment)
+	stuff.Write(master)
+	var nums = make([]byte, 8*5)
+	binary.BigEndian.PutUint64(nums[0:8], s.StartHeight)
+	binary.BigEndian.PutUint64(nums[8:16], s.CliffBlockNumber)
+	binary.BigEndian.PutUint64(nums[16:24], s.TrancheBlockNumber)
+	binary.BigEndian.PutUint64(nums[24:32], s.TrancheCount)
+	binary.BigEndian.PutUint64(nums[32:40], uint64(index))
+	stuff.Write(nums)
+	amtbts, _ := s.TotalAmount.Serialize()
+	stuff.Write(amtbts)
+	hx := fields.CalculateHash(stuff.Bytes())
+	id := append([]byte{}, hx[0:stores.LockblsIdLength]...)
+	// The first and last digits of the lock ID created by the user cannot be zero
+	if id[0] == 0 {
+		id[0] = 1
+	}
+	if id[stores.LockblsIdLength-1] == 0 {
+		id[stores.LockblsIdLength-1] = 1
+	}
+	return id
+}
+
+// Create all lockbls creation actions of the schedule
+func CreateLockblsActions(payment, master fields.Address, s *Schedule) ([]*actions.Action_9_LockblsCreate, error) {
+	plans, e := s.Compile()
+	if e != nil {
+		return nil, e
+	}
+	acts := make([]*actions.Action_9_LockblsCreate, 0, len(plans))
+	for i, p := range plans {
+		acts = append(acts, &actions.Action_9_LockblsCreate{
+			LockblsId:           CreateLockblsId(payment, master, s, i),
+			PaymentAddress:      payment,
+			MasterAddress:       master,
+			EffectBlockHeight:   fields.BlockHeight(p.EffectBlockHeight),
+			LinearBlockNumber:   fields.VarUint3(p.LinearBlockNumber),
+			TotalStockAmount:    *p.TotalStockAmount,
+			LinearReleaseAmount: *p.LinearReleaseAmount,
+		})
+	}
+	return acts, nil
+}